@@ -0,0 +1,136 @@
+package shareddiscovery
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"strings"
+)
+
+// AuthorizationError is returned by AdminGetAPIToken when a validly signed
+// query is not allowed by the policy of its admin key. Field is the query
+// field or app attribute that was refused, or "key" for a key that is not
+// one of the AdminKeys.
+type AuthorizationError struct {
+	KeyID string
+	Field string
+	Value string
+}
+
+func (err *AuthorizationError) Error() string {
+	if err.Field == "key" {
+		return "admin key is not registered"
+	}
+	return fmt.Sprintf("admin key %s is not allowed %s %q", err.KeyID, err.Field, err.Value)
+}
+
+// AdminPolicy restricts the apps an admin key may retrieve tokens for. An
+// empty list allows any value, and values are compared case-insensitively.
+type AdminPolicy struct {
+	Brands       []string `json:"brands,omitempty" yaml:"brands,omitempty"`
+	Environments []string `json:"environments,omitempty" yaml:"environments,omitempty"`
+	Countries    []string `json:"countries,omitempty" yaml:"countries,omitempty"`
+	Apps         []string `json:"apps,omitempty" yaml:"apps,omitempty"`
+}
+
+// AdminKey is an admin secret key and the policy restricting it. ID names
+// the key in errors and the audit trail.
+type AdminKey struct {
+	ID     string
+	Secret string
+	Policy AdminPolicy
+}
+
+// adminKey returns the AdminKeys entry for secretKey. Every key is compared
+// in constant time so the lookup does not reveal how much of a secret
+// matched.
+func (service SharedDiscovery) adminKey(secretKey string) (AdminKey, bool) {
+	var found AdminKey
+	var ok bool
+	for _, key := range service.AdminKeys {
+		if subtle.ConstantTimeCompare([]byte(key.Secret), []byte(secretKey)) == 1 && !ok {
+			found, ok = key, true
+		}
+	}
+	return found, ok
+}
+
+// authorizeQuery checks the fields of query that are set against the policy
+// of secretKey, before anything is read. Without AdminKeys every key may
+// retrieve every token.
+func (service SharedDiscovery) authorizeQuery(secretKey string, query QueryInput) error {
+	if len(service.AdminKeys) == 0 {
+		return nil
+	}
+	key, ok := service.adminKey(secretKey)
+	if !ok {
+		return &AuthorizationError{Field: "key"}
+	}
+	return key.Policy.check(key.ID, false, map[string]string{
+		"brand":       query.Brand,
+		"environment": query.Environment,
+		"countryCode": query.Country,
+		"appName":     query.AppName,
+	})
+}
+
+// authorizeApp checks the app a query matched against the policy of
+// secretKey. It is the check that counts, since a query by appName matches
+// apps of any brand.
+func (service SharedDiscovery) authorizeApp(secretKey string, app map[string]interface{}) error {
+	if len(service.AdminKeys) == 0 {
+		return nil
+	}
+	key, ok := service.adminKey(secretKey)
+	if !ok {
+		return &AuthorizationError{Field: "key"}
+	}
+	attribute := func(name string) string {
+		value, _ := app[name].(string)
+		return value
+	}
+	return key.Policy.check(key.ID, true, map[string]string{
+		"brand":       attribute("brandName"),
+		"environment": attribute("environment"),
+		"countryCode": attribute("countryCode"),
+		"appName":     attribute("appName"),
+	})
+}
+
+// check refuses the first value not allowed by policy. Empty values are
+// query fields that were not sent and are skipped, unless required, as an
+// app missing a restricted attribute cannot be shown to be allowed.
+func (policy AdminPolicy) check(keyID string, required bool, values map[string]string) error {
+	for _, field := range []string{"brand", "environment", "countryCode", "appName"} {
+		value := values[field]
+		if value == "" && !required {
+			continue
+		}
+		if !policy.allows(field, value) {
+			return &AuthorizationError{KeyID: keyID, Field: field, Value: value}
+		}
+	}
+	return nil
+}
+
+func (policy AdminPolicy) allows(field, value string) bool {
+	allowed := map[string][]string{
+		"brand":       policy.Brands,
+		"environment": policy.Environments,
+		"countryCode": policy.Countries,
+		"appName":     policy.Apps,
+	}[field]
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, candidate := range allowed {
+		if field == "countryCode" {
+			if normalized, err := NormalizeCountry(candidate); err == nil {
+				candidate = normalized
+			}
+		}
+		if strings.EqualFold(candidate, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+package shareddiscovery
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/pgdevelopers/shareddiscovery/v2/discoverytest"
+)
+
+// newScopedDiscovery returns a SharedDiscovery on an in-memory discovery_app
+// holding an oralb app and a gillette app, and admin keys scoped to oralb
+// and to US qa apps.
+func newScopedDiscovery(t *testing.T) SharedDiscovery {
+	db := discoverytest.NewDynamoDB()
+	db.Seed(t, DiscoveryAppTable.CreateTableInput("discovery_app"),
+		map[string]string{"apiToken": "oralb-token", "appName": "sonos", "brandName": "oralb", "countryCode": "US", "environment": "prod"},
+		map[string]string{"apiToken": "gillette-token", "appName": "razor", "brandName": "gillette", "countryCode": "US", "environment": "prod"},
+	)
+	self := New(db)
+	self.AdminKeys = []AdminKey{
+		{ID: "oralb-support", Secret: "oralbSecret", Policy: AdminPolicy{Brands: []string{"OralB"}}},
+		{ID: "us-qa", Secret: "qaSecret", Policy: AdminPolicy{Environments: []string{"qa"}, Countries: []string{"usa"}}},
+	}
+	return self
+}
+
+func signedQuery(secretKey string, query QueryInput) QueryInput {
+	query.Workspace = "discovery_app"
+	query.QueryString = map[string]string{
+		"appName":     query.AppName,
+		"brand":       query.Brand,
+		"countryCode": query.Country,
+		"environment": query.Environment,
+	}
+	query.Signature = Sign(secretKey, query)
+	return query
+}
+
+func TestAdminGetAPIToken_AdminKeys(t *testing.T) {
+	self := newScopedDiscovery(t)
+	tests := []struct {
+		name      string
+		secretKey string
+		query     QueryInput
+		token     string
+		field     string
+	}{
+		{"allowed", "oralbSecret", QueryInput{Brand: "oralb", Environment: "prod", Country: "US"}, "oralb-token", ""},
+		{"allowed by app name", "oralbSecret", QueryInput{AppName: "sonos", Environment: "prod", Country: "US"}, "oralb-token", ""},
+		{"brand denied", "oralbSecret", QueryInput{Brand: "gillette", Environment: "prod", Country: "US"}, "", "brand"},
+		// the query names no brand, so only the app found can be refused
+		{"app of another brand", "oralbSecret", QueryInput{AppName: "razor", Environment: "prod", Country: "US"}, "", "brand"},
+		{"environment denied", "qaSecret", QueryInput{Brand: "oralb", Environment: "prod", Country: "US"}, "", "environment"},
+		{"unregistered key", "otherSecret", QueryInput{Brand: "oralb", Environment: "prod", Country: "US"}, "", "key"},
+	}
+
+	for _, test := range tests {
+		token, err := self.AdminGetAPIToken(context.TODO(), test.secretKey, signedQuery(test.secretKey, test.query))
+		var authErr *AuthorizationError
+		switch {
+		case test.field == "" && (token != test.token || err != nil):
+			t.Errorf("%s: AdminGetAPIToken(ctx, %q, query) == %q, %v, want %q", test.name, test.secretKey, token, err, test.token)
+		case test.field != "" && (token != "" || !errors.As(err, &authErr) || authErr.Field != test.field):
+			t.Errorf("%s: AdminGetAPIToken(ctx, %q, query) == %q, %v, want an AuthorizationError on %s", test.name, test.secretKey, token, err, test.field)
+		}
+	}
+}
+
+func TestAdminGetAPIToken_AdminKeysAudit(t *testing.T) {
+	self := newScopedDiscovery(t)
+	sink := &MemoryAuditSink{}
+	self.Audit = sink
+
+	query := signedQuery("oralbSecret", QueryInput{AppName: "razor", Environment: "prod", Country: "US"})
+	_, err := self.AdminGetAPIToken(context.TODO(), "oralbSecret", query)
+	if class := ErrorClass(err); class != "forbidden" {
+		t.Errorf("ErrorClass(%v) == %q, want %q", err, class, "forbidden")
+	}
+
+	events, _ := sink.Query(context.TODO(), AuditFilter{})
+	// the refused app is recorded, as it is what a review needs to see
+	if len(events) != 1 || events[0].KeyID != "oralb-support" || events[0].Error != "forbidden" || events[0].App != "razor" {
+		t.Errorf("audit events == %+v, want one forbidden event by oralb-support for razor", events)
+	}
+}
+
+func TestAdminPolicy_MissingAttribute(t *testing.T) {
+	policy := AdminPolicy{Brands: []string{"oralb"}}
+
+	if err := policy.check("support", false, map[string]string{"environment": "prod"}); err != nil {
+		t.Errorf("check(query without brand) == %v, want nil", err)
+	}
+	if err := policy.check("support", true, map[string]string{"environment": "prod"}); err == nil {
+		t.Errorf("check(app without brandName) == nil, want an AuthorizationError")
+	}
+}
@@ -0,0 +1,311 @@
+package shareddiscovery
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// Caller identifies who is making a call, for the audit trail. KeyID names
+// the admin secret key they signed with; when empty, a fingerprint of the
+// key keyed by AuditKey is recorded instead.
+type Caller struct {
+	Identity string
+	KeyID    string
+}
+
+type callerKey struct{}
+
+// WithCaller returns a copy of ctx carrying caller.
+func WithCaller(ctx context.Context, caller Caller) context.Context {
+	return context.WithValue(ctx, callerKey{}, caller)
+}
+
+// CallerFrom returns the Caller carried by ctx, if any.
+func CallerFrom(ctx context.Context) (Caller, bool) {
+	caller, ok := ctx.Value(callerKey{}).(Caller)
+	return caller, ok
+}
+
+// AuditEvent records one admin token retrieval. It never holds the token or
+// the secret key.
+type AuditEvent struct {
+	Time        time.Time `json:"time" dynamodbav:"time"`
+	Operation   string    `json:"operation" dynamodbav:"operation"`
+	Caller      string    `json:"caller,omitempty" dynamodbav:"caller,omitempty"`
+	KeyID       string    `json:"keyId,omitempty" dynamodbav:"keyId,omitempty"`
+	Workspace   string    `json:"workspace" dynamodbav:"workspace"`
+	AppName     string    `json:"appName,omitempty" dynamodbav:"appName,omitempty"`
+	Brand       string    `json:"brand,omitempty" dynamodbav:"brand,omitempty"`
+	Country     string    `json:"countryCode,omitempty" dynamodbav:"countryCode,omitempty"`
+	Environment string    `json:"environment,omitempty" dynamodbav:"environment,omitempty"`
+
+	// App is the appName of the row whose token was returned, or refused
+	// by the admin key's policy.
+	App string `json:"app,omitempty" dynamodbav:"app,omitempty"`
+
+	// Outcome is "success" or "error", and Error the ErrorClass of a failure.
+	Outcome string `json:"outcome" dynamodbav:"outcome"`
+	Error   string `json:"error,omitempty" dynamodbav:"error,omitempty"`
+}
+
+// AuditFilter selects audit events. Empty fields match everything, Since is
+// inclusive and Until exclusive. Events come oldest first, up to Limit when
+// it is positive.
+type AuditFilter struct {
+	Workspace string
+	Caller    string
+	Since     time.Time
+	Until     time.Time
+	Limit     int
+}
+
+func (filter AuditFilter) match(event AuditEvent) bool {
+	return (filter.Workspace == "" || event.Workspace == filter.Workspace) &&
+		(filter.Caller == "" || event.Caller == filter.Caller) &&
+		(filter.Since.IsZero() || !event.Time.Before(filter.Since)) &&
+		(filter.Until.IsZero() || event.Time.Before(filter.Until))
+}
+
+// apply returns the events matching filter, oldest first.
+func (filter AuditFilter) apply(events []AuditEvent) []AuditEvent {
+	var matched []AuditEvent
+	for _, event := range events {
+		if filter.match(event) {
+			matched = append(matched, event)
+		}
+	}
+	sort.SliceStable(matched, func(i, j int) bool { return matched[i].Time.Before(matched[j].Time) })
+	if filter.Limit > 0 && len(matched) > filter.Limit {
+		matched = matched[:filter.Limit]
+	}
+	return matched
+}
+
+// AuditSink keeps the audit trail of AdminGetAPIToken.
+type AuditSink interface {
+	Record(ctx context.Context, event AuditEvent) error
+	Query(ctx context.Context, filter AuditFilter) ([]AuditEvent, error)
+}
+
+// MemoryAuditSink keeps audit events in process, for tests and local
+// stand-ins.
+type MemoryAuditSink struct {
+	mu     sync.Mutex
+	events []AuditEvent
+}
+
+// Record appends event.
+func (sink *MemoryAuditSink) Record(ctx context.Context, event AuditEvent) error {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	sink.events = append(sink.events, event)
+	return nil
+}
+
+// Query returns the recorded events matching filter.
+func (sink *MemoryAuditSink) Query(ctx context.Context, filter AuditFilter) ([]AuditEvent, error) {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	return filter.apply(sink.events), nil
+}
+
+// FileAuditSink appends audit events to the file at Path as JSON Lines,
+// creating it when missing.
+type FileAuditSink struct {
+	Path string
+
+	mu sync.Mutex
+}
+
+// Record appends event as a line of JSON.
+func (sink *FileAuditSink) Record(ctx context.Context, event AuditEvent) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	file, err := os.OpenFile(sink.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Query reads the whole file and returns the events matching filter. A
+// missing file has no events.
+func (sink *FileAuditSink) Query(ctx context.Context, filter AuditFilter) ([]AuditEvent, error) {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	file, err := os.Open(sink.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var events []AuditEvent
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var event AuditEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return filter.apply(events), nil
+}
+
+// auditTimeFormat sorts lexically in time order.
+const auditTimeFormat = "2006-01-02T15:04:05.000000000Z"
+
+// DynamoDBAuditSink keeps audit events in a DynamoDB table with the string
+// hash key "workspace" and the string range key "id", which starts with the
+// event time so a workspace's events are read in order.
+type DynamoDBAuditSink struct {
+	DynamoDB dynamodbiface.DynamoDBAPI
+	Table    string
+}
+
+// Record puts event, refusing to overwrite an existing event.
+func (sink DynamoDBAuditSink) Record(ctx context.Context, event AuditEvent) error {
+	item, err := dynamodbattribute.MarshalMap(event)
+	if err != nil {
+		return err
+	}
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+	item["id"] = &dynamodb.AttributeValue{S: aws.String(event.Time.UTC().Format(auditTimeFormat) + "#" + hex.EncodeToString(suffix))}
+	_, err = sink.DynamoDB.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName:                aws.String(sink.Table),
+		Item:                     item,
+		ConditionExpression:      aws.String("attribute_not_exists(#id)"),
+		ExpressionAttributeNames: map[string]*string{"#id": aws.String("id")},
+	})
+	return err
+}
+
+// Query reads the events of filter.Workspace in its time range, or scans the
+// whole table when no workspace is given.
+func (sink DynamoDBAuditSink) Query(ctx context.Context, filter AuditFilter) ([]AuditEvent, error) {
+	var items []map[string]*dynamodb.AttributeValue
+	collect := func(page []map[string]*dynamodb.AttributeValue) {
+		items = append(items, page...)
+	}
+
+	var err error
+	if filter.Workspace == "" {
+		err = sink.DynamoDB.ScanPagesWithContext(ctx, &dynamodb.ScanInput{TableName: aws.String(sink.Table)}, func(output *dynamodb.ScanOutput, _ bool) bool {
+			collect(output.Items)
+			return true
+		})
+	} else {
+		// ids start with the time, and "~" sorts after the "#" that follows it
+		from, to := "0", "~"
+		if !filter.Since.IsZero() {
+			from = filter.Since.UTC().Format(auditTimeFormat)
+		}
+		if !filter.Until.IsZero() {
+			to = filter.Until.UTC().Format(auditTimeFormat)
+		}
+		err = sink.DynamoDB.QueryPagesWithContext(ctx, &dynamodb.QueryInput{
+			TableName:                aws.String(sink.Table),
+			KeyConditionExpression:   aws.String("#workspace = :w AND #id BETWEEN :from AND :to"),
+			ExpressionAttributeNames: map[string]*string{"#workspace": aws.String("workspace"), "#id": aws.String("id")},
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+				":w":    {S: aws.String(filter.Workspace)},
+				":from": {S: aws.String(from)},
+				":to":   {S: aws.String(to)},
+			},
+		}, func(output *dynamodb.QueryOutput, _ bool) bool {
+			collect(output.Items)
+			return true
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]AuditEvent, len(items))
+	for i, item := range items {
+		if err := dynamodbattribute.UnmarshalMap(item, &events[i]); err != nil {
+			return nil, err
+		}
+	}
+	return filter.apply(events), nil
+}
+
+// keyFingerprint identifies secretKey without revealing it. It is keyed
+// by AuditKey, so a leaked audit trail cannot be used to guess weak secrets
+// offline, and is empty without one.
+func (service SharedDiscovery) keyFingerprint(secretKey string) string {
+	if len(service.AuditKey) == 0 {
+		return ""
+	}
+	mac := hmac.New(sha256.New, service.AuditKey)
+	mac.Write([]byte(secretKey))
+	return "hmac:" + hex.EncodeToString(mac.Sum(nil)[:8])
+}
+
+// audit records a call to operation in the Audit sink when the service has
+// one.
+func (service SharedDiscovery) audit(ctx context.Context, span Span, operation, secretKey string, query QueryInput, app string, callErr error) error {
+	if service.Audit == nil {
+		return nil
+	}
+	caller, _ := CallerFrom(ctx)
+	event := AuditEvent{
+		Time:        time.Now().UTC(),
+		Operation:   operation,
+		Caller:      caller.Identity,
+		KeyID:       caller.KeyID,
+		Workspace:   query.Workspace,
+		AppName:     query.AppName,
+		Brand:       query.Brand,
+		Country:     query.Country,
+		Environment: query.Environment,
+		App:         app,
+		Outcome:     "success",
+	}
+	if key, ok := service.adminKey(secretKey); ok {
+		event.KeyID = key.ID
+	} else if event.KeyID == "" {
+		event.KeyID = service.keyFingerprint(secretKey)
+	}
+	if callErr != nil {
+		event.Outcome = "error"
+		event.Error = ErrorClass(callErr)
+	}
+	if err := service.Audit.Record(ctx, event); err != nil {
+		span.AddField("audit.error", err.Error())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package shareddiscovery
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pgdevelopers/shareddiscovery/v2/discoverytest"
+)
+
+// newAuditDiscovery returns a SharedDiscovery on an in-memory discovery_app
+// workspace holding the sonos app, recording to a MemoryAuditSink.
+func newAuditDiscovery(t *testing.T) (SharedDiscovery, *MemoryAuditSink) {
+	db := discoverytest.NewDynamoDB()
+	db.Seed(t, DiscoveryAppTable.CreateTableInput("discovery_app"),
+		map[string]string{"apiToken": "qa-token", "appName": "sonos", "countryCode": "US", "environment": "qa"})
+	self := New(db)
+	sink := &MemoryAuditSink{}
+	self.Audit = sink
+	return self, sink
+}
+
+// failingAuditSink is an AuditSink that cannot record.
+type failingAuditSink struct {
+	MemoryAuditSink
+}
+
+func (*failingAuditSink) Record(ctx context.Context, event AuditEvent) error {
+	return errors.New("audit table unavailable")
+}
+
+func TestAdminGetAPIToken_Audit(t *testing.T) {
+	var (
+		self, sink = newAuditDiscovery(t)
+		ctx        = WithCaller(context.TODO(), Caller{Identity: "ci@pg.com", KeyID: "ops-2024"})
+		query      = generateQueryWithAppName()
+	)
+	self.AuditKey = []byte("auditKey")
+
+	if token, err := self.AdminGetAPIToken(ctx, "secretKey", query); token != "qa-token" || err != nil {
+		t.Fatalf("AdminGetAPIToken(ctx, %q, query) == %q, %v, want qa-token", "secretKey", token, err)
+	}
+	forged := query
+	forged.Signature = "00"
+	if _, err := self.AdminGetAPIToken(context.TODO(), "secretKey", forged); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("AdminGetAPIToken(ctx, %q, forged) == %v, want ErrInvalidSignature", "secretKey", err)
+	}
+
+	events, _ := sink.Query(context.TODO(), AuditFilter{})
+	if len(events) != 2 {
+		t.Fatalf("recorded %d events, want 2", len(events))
+	}
+	success, failure := events[0], events[1]
+	if success.Caller != "ci@pg.com" || success.KeyID != "ops-2024" || success.App != "sonos" || success.Outcome != "success" ||
+		success.Workspace != "discovery_app" || success.Brand != "oralb" || success.Country != "US" || success.Time.IsZero() {
+		t.Errorf("success event == %+v, want the caller, query and app", success)
+	}
+	if failure.Outcome != "error" || failure.Error != "invalid_signature" || failure.KeyID != self.keyFingerprint("secretKey") || failure.KeyID == "" || failure.App != "" {
+		t.Errorf("failure event == %+v, want an invalid_signature error with the key fingerprint", failure)
+	}
+	for _, event := range events {
+		data, _ := json.Marshal(event)
+		if strings.Contains(string(data), "qa-token") || strings.Contains(string(data), "secretKey") {
+			t.Errorf("event %s contains the token or secret key", data)
+		}
+	}
+}
+
+func TestKeyFingerprint(t *testing.T) {
+	self := New(nil)
+	if fingerprint := self.keyFingerprint("secretKey"); fingerprint != "" {
+		t.Errorf("keyFingerprint(%q) without AuditKey == %q, want none", "secretKey", fingerprint)
+	}
+
+	self.AuditKey = []byte("auditKey")
+	other := self
+	other.AuditKey = []byte("otherKey")
+	if first, second := self.keyFingerprint("secretKey"), other.keyFingerprint("secretKey"); first == second || first != self.keyFingerprint("secretKey") {
+		t.Errorf("keyFingerprint(%q) == %q and %q under another key, want stable and keyed", "secretKey", first, second)
+	}
+}
+
+func TestAdminGetAPIToken_AuditFailure(t *testing.T) {
+	self, _ := newAuditDiscovery(t)
+	self.Audit = &failingAuditSink{}
+
+	// a token is not handed out unless its retrieval is recorded
+	token, err := self.AdminGetAPIToken(context.TODO(), "secretKey", generateQueryWithAppName())
+	if token != "" || err == nil {
+		t.Errorf("AdminGetAPIToken(ctx, %q, query) == %q, %v, want an error", "secretKey", token, err)
+	}
+}
+
+func auditEvents() []AuditEvent {
+	start := time.Date(2021, 10, 1, 12, 0, 0, 0, time.UTC)
+	return []AuditEvent{
+		{Time: start, Operation: "AdminGetAPIToken", Caller: "alice", Workspace: "apps", Outcome: "success"},
+		{Time: start.Add(time.Minute), Operation: "AdminGetAPIToken", Caller: "bob", Workspace: "apps", Outcome: "error", Error: "not_found"},
+		{Time: start.Add(2 * time.Minute), Operation: "AdminGetAPIToken", Caller: "alice", Workspace: "other", Outcome: "success"},
+		{Time: start.Add(3 * time.Minute), Operation: "AdminGetAPIToken", Caller: "alice", Workspace: "apps", Outcome: "success"},
+	}
+}
+
+// testAuditSink records auditEvents in sink and checks filters select them.
+func testAuditSink(t *testing.T, sink AuditSink) {
+	t.Helper()
+	ctx := context.TODO()
+	events := auditEvents()
+	for _, event := range events {
+		if err := sink.Record(ctx, event); err != nil {
+			t.Fatalf("Record(ctx, %v) == %v", event, err)
+		}
+	}
+
+	tests := []struct {
+		filter AuditFilter
+		want   []AuditEvent
+	}{
+		{AuditFilter{}, events},
+		{AuditFilter{Workspace: "apps"}, []AuditEvent{events[0], events[1], events[3]}},
+		{AuditFilter{Workspace: "apps", Caller: "alice"}, []AuditEvent{events[0], events[3]}},
+		{AuditFilter{Workspace: "apps", Since: events[1].Time, Until: events[3].Time}, []AuditEvent{events[1]}},
+		{AuditFilter{Since: events[1].Time, Limit: 2}, []AuditEvent{events[1], events[2]}},
+	}
+	for _, test := range tests {
+		got, err := sink.Query(ctx, test.filter)
+		if err != nil {
+			t.Errorf("Query(ctx, %+v) == %v", test.filter, err)
+			continue
+		}
+		if len(got) != len(test.want) {
+			t.Errorf("Query(ctx, %+v) == %d events, want %d", test.filter, len(got), len(test.want))
+			continue
+		}
+		for i := range got {
+			if !got[i].Time.Equal(test.want[i].Time) || got[i].Caller != test.want[i].Caller || got[i].Workspace != test.want[i].Workspace {
+				t.Errorf("Query(ctx, %+v)[%d] == %+v, want %+v", test.filter, i, got[i], test.want[i])
+			}
+		}
+	}
+}
+
+func TestMemoryAuditSink(t *testing.T) {
+	testAuditSink(t, &MemoryAuditSink{})
+}
+
+func TestFileAuditSink(t *testing.T) {
+	sink := &FileAuditSink{Path: filepath.Join(t.TempDir(), "audit.jsonl")}
+	if events, err := sink.Query(context.TODO(), AuditFilter{}); events != nil || err != nil {
+		t.Errorf("Query(ctx) on a missing file == %v, %v, want no events", events, err)
+	}
+	testAuditSink(t, sink)
+}
+
+func TestDynamoDBAuditSink(t *testing.T) {
+	db := discoverytest.NewDynamoDB()
+	db.Seed(t, TableDefinition{HashKey: "workspace", RangeKey: "id"}.CreateTableInput("audit"))
+	testAuditSink(t, DynamoDBAuditSink{DynamoDB: db, Table: "audit"})
+}
@@ -0,0 +1,263 @@
+package shareddiscovery
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// maxBatchGetKeys is the most keys DynamoDB accepts in a single BatchGetItem call.
+const maxBatchGetKeys = 100
+
+var (
+	// batchMaxAttempts bounds how many times UnprocessedKeys are retried.
+	batchMaxAttempts = 6
+	// batchBaseDelay is the first backoff delay, doubled on every attempt
+	// up to batchMaxDelay.
+	batchBaseDelay = 50 * time.Millisecond
+	batchMaxDelay  = 2 * time.Second
+
+	// ErrUnprocessed is returned for keys DynamoDB still had not processed
+	// after all retries.
+	ErrUnprocessed = errors.New("shareddiscovery: key was not processed")
+)
+
+// ConfigResult is the outcome of retrieving a single ConfigKey. As with
+// GetConfig, a key without a matching row has a nil Config and a nil Err.
+type ConfigResult struct {
+	Key    ConfigKey
+	Config map[string]interface{}
+	Err    error
+}
+
+// BatchGetConfig retrieves the configs of many keys, possibly across several
+// workspaces, using BatchGetItem. Keys are sent in chunks of 100 per workspace and keys
+// DynamoDB leaves unprocessed are retried with exponential backoff.
+//
+// Countries are normalized as in GetConfig, and a key whose country is
+// refused has a *ValidationError as its Err. A result is returned for every
+// key, in the same order and carrying the key as given. A failure only
+// affects the keys it applies to, so the rest of the batch still succeeds.
+func (service SharedDiscovery) BatchGetConfig(ctx context.Context, keys []ConfigKey, opts ...CallOption) []ConfigResult {
+	ctx, batchSpan := service.startSpan(ctx, "BatchGetConfig")
+	var batchErr error
+	defer func() { finishSpan(batchSpan, batchErr) }()
+	batchSpan.AddField("keys.count", len(keys))
+	options := newCallOptions(opts)
+	ctx, cancel := options.context(ctx)
+	defer cancel()
+
+	rowOptions, masked := service.rowOptions(options)
+	found := map[ConfigKey]ConfigResult{}
+	// pending keys are grouped by workspace so each table has its own breaker
+	pending := map[string][]ConfigKey{}
+	var workspaces []string
+	normalized := make([]ConfigKey, len(keys))
+	for i, key := range keys {
+		key, err := service.normalizeKey(key)
+		normalized[i] = key
+		if err != nil {
+			found[key] = ConfigResult{Key: key, Err: err}
+			batchErr = err
+			batchSpan.AddField("error.message", err.Error())
+			continue
+		}
+		if _, seen := found[key]; seen {
+			continue
+		}
+		if service.Cache != nil && options.readCache() {
+			cached, ok := service.Cache.Get(key)
+			service.observeCache(ctx, "BatchGetConfig", key.Workspace, ok)
+			if ok {
+				found[key] = ConfigResult{Key: key, Config: rowOptions.mask(cached)}
+				continue
+			}
+		}
+		found[key] = ConfigResult{Key: key}
+		if _, ok := pending[key.Workspace]; !ok {
+			workspaces = append(workspaces, key.Workspace)
+		}
+		pending[key.Workspace] = append(pending[key.Workspace], key)
+	}
+
+	for _, workspace := range workspaces {
+		began := time.Now()
+		var failed error
+		keys := pending[workspace]
+		for start := 0; start < len(keys); start += maxBatchGetKeys {
+			end := start + maxBatchGetKeys
+			if end > len(keys) {
+				end = len(keys)
+			}
+			for _, result := range service.batchGetChunk(ctx, batchSpan, workspace, keys[start:end], rowOptions) {
+				if result.Err != nil {
+					failed = result.Err
+					batchErr = result.Err
+					batchSpan.AddField("error.message", result.Err.Error())
+				} else if service.Cache != nil && result.Config != nil && options.writeCache() {
+					service.Cache.Set(result.Key, result.Config)
+				}
+				found[result.Key] = result
+			}
+		}
+		service.observe(ctx, "BatchGetConfig", workspace, began, failed)
+	}
+
+	results := make([]ConfigResult, len(keys))
+	for i, key := range keys {
+		results[i] = found[normalized[i]]
+		results[i].Key = key
+		if results[i].Err != nil {
+			continue
+		}
+		row, config := results[i].Config, results[i].Config
+		if masked {
+			config = options.mask(row)
+		}
+		if results[i].Config, results[i].Err = service.resolveSecrets(ctx, batchSpan, row, config); results[i].Err != nil {
+			batchErr = results[i].Err
+		}
+	}
+	return results
+}
+
+func (service SharedDiscovery) batchGetChunk(ctx context.Context, span Span, workspace string, keys []ConfigKey, options callOptions) []ConfigResult {
+	results := map[ConfigKey]*ConfigResult{}
+	// the keys are projected too so items can be matched to their ConfigKey
+	projection, names, err := options.projection("apiToken", "countryCode")
+	if err != nil {
+		for _, key := range keys {
+			results[key] = &ConfigResult{Key: key, Err: err}
+		}
+		return collectBatchResults(results)
+	}
+	request := &dynamodb.KeysAndAttributes{
+		ConsistentRead:           options.consistent(),
+		ProjectionExpression:     projection,
+		ExpressionAttributeNames: names,
+	}
+	for _, key := range keys {
+		results[key] = &ConfigResult{Key: key}
+		request.Keys = append(request.Keys, keyAttributes(key))
+	}
+	requests := map[string]*dynamodb.KeysAndAttributes{workspace: request}
+
+	for attempt := 0; len(requests) > 0; attempt++ {
+		if attempt > 0 {
+			if attempt >= batchMaxAttempts {
+				failBatchKeys(results, requests, ErrUnprocessed)
+				break
+			}
+			if err := sleepWithContext(ctx, backoffDelay(batchBaseDelay, batchMaxDelay, attempt)); err != nil {
+				failBatchKeys(results, requests, err)
+				break
+			}
+		}
+
+		batch, err := service.read(ctx, span, "BatchGetConfig", workspace, func(ctx context.Context, db dynamodbiface.DynamoDBAPI) (interface{}, error) {
+			return db.BatchGetItemWithContext(ctx, &dynamodb.BatchGetItemInput{RequestItems: requests, ReturnConsumedCapacity: options.returnCapacity()})
+		})
+		if err != nil {
+			failBatchKeys(results, requests, err)
+			break
+		}
+		output := batch.(*dynamodb.BatchGetItemOutput)
+		service.observeCapacity(ctx, "BatchGetConfig", output.ConsumedCapacity...)
+		options.addCapacity(output.ConsumedCapacity...)
+
+		for workspace, items := range output.Responses {
+			for _, item := range items {
+				key := keyFromItem(workspace, item)
+				result, ok := results[key]
+				if !ok {
+					// workspaces keyed by apiToken alone may still store a countryCode
+					key.Country = ""
+					result, ok = results[key]
+				}
+				if !ok {
+					continue
+				}
+				item, err := service.decryptItem(ctx, key, item)
+				if err != nil {
+					result.Err = err
+					continue
+				}
+				if err := dynamodbattribute.UnmarshalMap(item, &result.Config); err != nil {
+					result.Err = err
+				}
+				result.Config = options.mask(result.Config)
+			}
+		}
+		requests = output.UnprocessedKeys
+	}
+	return collectBatchResults(results)
+}
+
+func collectBatchResults(results map[ConfigKey]*ConfigResult) []ConfigResult {
+	chunk := make([]ConfigResult, 0, len(results))
+	for _, result := range results {
+		chunk = append(chunk, *result)
+	}
+	return chunk
+}
+
+func keyAttributes(key ConfigKey) map[string]*dynamodb.AttributeValue {
+	return addNeededSearchAttributes(map[string]*dynamodb.AttributeValue{
+		"apiToken": {S: aws.String(key.APIToken)},
+	}, QueryInput{Country: key.Country})
+}
+
+func keyFromItem(workspace string, item map[string]*dynamodb.AttributeValue) ConfigKey {
+	key := ConfigKey{Workspace: workspace}
+	if token, ok := item["apiToken"]; ok {
+		key.APIToken = aws.StringValue(token.S)
+	}
+	if country, ok := item["countryCode"]; ok {
+		key.Country = aws.StringValue(country.S)
+	}
+	return key
+}
+
+func failBatchKeys(results map[ConfigKey]*ConfigResult, requests map[string]*dynamodb.KeysAndAttributes, err error) {
+	for workspace, request := range requests {
+		for _, item := range request.Keys {
+			if result, ok := results[keyFromItem(workspace, item)]; ok {
+				result.Err = err
+			}
+		}
+	}
+}
+
+// backoffDelay returns a jittered exponential delay for the given attempt,
+// between half and all of base * 2^(attempt-1) capped at max. The delay is
+// doubled step by step so that many attempts cannot overflow it.
+func backoffDelay(base, max time.Duration, attempt int) time.Duration {
+	delay := base
+	for i := 1; i < attempt && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1)) // #nosec G404 -- jitter does not need a secure source
+}
+
+func sleepWithContext(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
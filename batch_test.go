@@ -0,0 +1,156 @@
+package shareddiscovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/golang/mock/gomock"
+	"github.com/pgdevelopers/shareddiscovery/v2/mocks/mock_dynamodbiface"
+)
+
+func TestBatchGetConfig_ChunksKeys(t *testing.T) {
+	var (
+		ctx          = context.TODO()
+		mockDynamoDB = mock_dynamodbiface.NewMockDynamoDBAPI(gomock.NewController(t))
+		self         = New(mockDynamoDB)
+		keys         = make([]ConfigKey, 150)
+	)
+	for i := range keys {
+		keys[i] = ConfigKey{Workspace: "apps", APIToken: fmt.Sprintf("token%d", i), Country: "US"}
+	}
+
+	mockDynamoDB.
+		EXPECT().
+		BatchGetItemWithContext(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ aws.Context, input *dynamodb.BatchGetItemInput, _ ...interface{}) (*dynamodb.BatchGetItemOutput, error) {
+			if n := len(input.RequestItems["apps"].Keys); n > maxBatchGetKeys {
+				t.Errorf("BatchGetItem called with %d keys, want at most %d", n, maxBatchGetKeys)
+			}
+			return &dynamodb.BatchGetItemOutput{Responses: map[string][]map[string]*dynamodb.AttributeValue{
+				"apps": input.RequestItems["apps"].Keys,
+			}}, nil
+		}).
+		Times(2)
+
+	results := self.BatchGetConfig(ctx, keys)
+	if len(results) != len(keys) {
+		t.Fatalf("BatchGetConfig(ctx, keys) returned %d results, want %d", len(results), len(keys))
+	}
+	for i, result := range results {
+		if result.Key != keys[i] || result.Err != nil || result.Config["apiToken"] != keys[i].APIToken {
+			t.Errorf("BatchGetConfig(ctx, keys)[%d] == %+v, want config for %v", i, result, keys[i])
+		}
+	}
+}
+
+func TestBatchGetConfig_RetriesUnprocessedKeys(t *testing.T) {
+	var (
+		ctx          = context.TODO()
+		mockDynamoDB = mock_dynamodbiface.NewMockDynamoDBAPI(gomock.NewController(t))
+		self         = New(mockDynamoDB)
+		processed    = ConfigKey{Workspace: "apps", APIToken: "token1"}
+		unprocessed  = ConfigKey{Workspace: "apps", APIToken: "token2"}
+	)
+	defer setBatchBaseDelay(time.Millisecond)()
+
+	gomock.InOrder(
+		mockDynamoDB.
+			EXPECT().
+			BatchGetItemWithContext(gomock.Any(), gomock.Any()).
+			Return(&dynamodb.BatchGetItemOutput{
+				Responses: map[string][]map[string]*dynamodb.AttributeValue{"apps": {keyAttributes(processed)}},
+				UnprocessedKeys: map[string]*dynamodb.KeysAndAttributes{
+					"apps": {Keys: []map[string]*dynamodb.AttributeValue{keyAttributes(unprocessed)}},
+				},
+			}, nil),
+		mockDynamoDB.
+			EXPECT().
+			BatchGetItemWithContext(gomock.Any(), batchGetInput(unprocessed)).
+			Return(&dynamodb.BatchGetItemOutput{
+				Responses: map[string][]map[string]*dynamodb.AttributeValue{"apps": {keyAttributes(unprocessed)}},
+			}, nil),
+	)
+
+	for _, result := range self.BatchGetConfig(ctx, []ConfigKey{processed, unprocessed}) {
+		if result.Err != nil || result.Config == nil {
+			t.Errorf("BatchGetConfig(ctx, keys) result %+v, want a config", result)
+		}
+	}
+}
+
+func TestBatchGetConfig_PerKeyErrors(t *testing.T) {
+	var (
+		ctx          = context.TODO()
+		mockDynamoDB = mock_dynamodbiface.NewMockDynamoDBAPI(gomock.NewController(t))
+		self         = New(mockDynamoDB)
+		good         = ConfigKey{Workspace: "apps", APIToken: "token1"}
+		bad          = ConfigKey{Workspace: "firmware", APIToken: "token2"}
+		want         = errors.New("something bad")
+	)
+
+	mockDynamoDB.
+		EXPECT().
+		BatchGetItemWithContext(gomock.Any(), batchGetInput(good)).
+		Return(&dynamodb.BatchGetItemOutput{
+			Responses: map[string][]map[string]*dynamodb.AttributeValue{"apps": {keyAttributes(good)}},
+		}, nil)
+	mockDynamoDB.
+		EXPECT().
+		BatchGetItemWithContext(gomock.Any(), batchGetInput(bad)).
+		Return(nil, want)
+
+	results := self.BatchGetConfig(ctx, []ConfigKey{good, bad})
+	if results[0].Err != nil || results[0].Config == nil {
+		t.Errorf("BatchGetConfig(ctx, keys)[0] == %+v, want a config", results[0])
+	}
+	if !errors.Is(results[1].Err, want) {
+		t.Errorf("BatchGetConfig(ctx, keys)[1].Err == %v, want %q", results[1].Err, want)
+	}
+}
+
+func TestBatchGetConfig_NormalizesCountry(t *testing.T) {
+	var (
+		ctx          = context.TODO()
+		mockDynamoDB = mock_dynamodbiface.NewMockDynamoDBAPI(gomock.NewController(t))
+		self         = New(mockDynamoDB)
+		key          = ConfigKey{Workspace: "apps", APIToken: "token1", Country: "de-at"}
+		normalized   = ConfigKey{Workspace: "apps", APIToken: "token1", Country: "AT"}
+		invalid      = ConfigKey{Workspace: "apps", APIToken: "token1", Country: "America"}
+	)
+
+	mockDynamoDB.
+		EXPECT().
+		BatchGetItemWithContext(gomock.Any(), batchGetInput(normalized)).
+		Return(&dynamodb.BatchGetItemOutput{
+			Responses: map[string][]map[string]*dynamodb.AttributeValue{"apps": {keyAttributes(normalized)}},
+		}, nil)
+
+	results := self.BatchGetConfig(ctx, []ConfigKey{key, invalid})
+	if results[0].Err != nil || results[0].Config == nil {
+		t.Errorf("BatchGetConfig(ctx, keys)[0] == %+v, want a config", results[0])
+	}
+	if results[0].Key != key {
+		t.Errorf("BatchGetConfig(ctx, keys)[0].Key == %v, want %v", results[0].Key, key)
+	}
+	var validationErr *ValidationError
+	if !errors.As(results[1].Err, &validationErr) {
+		t.Errorf("BatchGetConfig(ctx, keys)[1].Err == %v, want a ValidationError", results[1].Err)
+	}
+}
+
+func batchGetInput(key ConfigKey) *dynamodb.BatchGetItemInput {
+	return &dynamodb.BatchGetItemInput{RequestItems: map[string]*dynamodb.KeysAndAttributes{
+		key.Workspace: {Keys: []map[string]*dynamodb.AttributeValue{keyAttributes(key)}},
+	}}
+}
+
+func setBatchBaseDelay(delay time.Duration) func() {
+	previous := batchBaseDelay
+	batchBaseDelay = delay
+	return func() { batchBaseDelay = previous }
+}
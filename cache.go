@@ -0,0 +1,104 @@
+package shareddiscovery
+
+import (
+	"sync"
+	"time"
+)
+
+// ConfigKey identifies a single config row in a workspace.
+// Country is optional and only used for workspaces keyed by
+// apiToken and countryCode.
+type ConfigKey struct {
+	Workspace string `json:"workspace" yaml:"workspace"`
+	APIToken  string `json:"apiToken" yaml:"apiToken"`
+	Country   string `json:"countryCode,omitempty" yaml:"countryCode,omitempty"`
+}
+
+func configKeyFor(apiToken string, query QueryInput) ConfigKey {
+	return ConfigKey{Workspace: query.Workspace, APIToken: apiToken, Country: query.Country}
+}
+
+// Cache describes a caching layer that GetConfig reads through when it is
+// set on a SharedDiscovery. Watch invalidates entries on the configured
+// Cache as changes arrive, so any implementation can be kept fresh.
+type Cache interface {
+	Get(key ConfigKey) (map[string]interface{}, bool)
+	Set(key ConfigKey, config map[string]interface{})
+	Invalidate(key ConfigKey)
+}
+
+// AliasCache is a Cache that can evict entries along with another key.
+// GetConfig aliases a config served from a country fallback to the row it
+// was read from, so Watch invalidating that row evicts the config too.
+type AliasCache interface {
+	Cache
+	// Alias makes invalidating target also invalidate key.
+	Alias(key, target ConfigKey)
+}
+
+// MemoryCache is an in-process Cache with a fixed time to live. It is an
+// AliasCache.
+type MemoryCache struct {
+	ttl     time.Duration
+	mu      sync.RWMutex
+	entries map[ConfigKey]cacheEntry
+	aliases map[ConfigKey]map[ConfigKey]bool
+}
+
+type cacheEntry struct {
+	config  map[string]interface{}
+	expires time.Time
+}
+
+// NewMemoryCache returns a MemoryCache that keeps entries for ttl.
+// A ttl of zero keeps entries until they are invalidated.
+func NewMemoryCache(ttl time.Duration) *MemoryCache {
+	return &MemoryCache{ttl: ttl, entries: map[ConfigKey]cacheEntry{}, aliases: map[ConfigKey]map[ConfigKey]bool{}}
+}
+
+// Get returns the cached config for key if it exists and has not expired.
+func (cache *MemoryCache) Get(key ConfigKey) (map[string]interface{}, bool) {
+	cache.mu.RLock()
+	entry, ok := cache.entries[key]
+	cache.mu.RUnlock()
+	if !ok {
+		return nil, false
+	}
+	if !entry.expires.IsZero() && time.Now().After(entry.expires) {
+		cache.Invalidate(key)
+		return nil, false
+	}
+	return entry.config, true
+}
+
+// Set stores config under key.
+func (cache *MemoryCache) Set(key ConfigKey, config map[string]interface{}) {
+	entry := cacheEntry{config: config}
+	if cache.ttl > 0 {
+		entry.expires = time.Now().Add(cache.ttl)
+	}
+	cache.mu.Lock()
+	cache.entries[key] = entry
+	cache.mu.Unlock()
+}
+
+// Invalidate removes key and the keys aliased to it from the cache.
+func (cache *MemoryCache) Invalidate(key ConfigKey) {
+	cache.mu.Lock()
+	delete(cache.entries, key)
+	for alias := range cache.aliases[key] {
+		delete(cache.entries, alias)
+	}
+	delete(cache.aliases, key)
+	cache.mu.Unlock()
+}
+
+// Alias makes invalidating target also invalidate key.
+func (cache *MemoryCache) Alias(key, target ConfigKey) {
+	cache.mu.Lock()
+	if cache.aliases[target] == nil {
+		cache.aliases[target] = map[ConfigKey]bool{}
+	}
+	cache.aliases[target][key] = true
+	cache.mu.Unlock()
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/pgdevelopers/shareddiscovery/v2"
+	"github.com/pgdevelopers/shareddiscovery/v2/discoveryhttp"
+)
+
+func audit(flags *flag.FlagSet) runFunc {
+	table := flags.String("table", "", "DynamoDB audit `table`")
+	file := flags.String("file", "", "JSON Lines audit `file`, instead of -table")
+	var filter shareddiscovery.AuditFilter
+	flags.StringVar(&filter.Workspace, "workspace", "", "only events for this discovery table")
+	flags.StringVar(&filter.Caller, "caller", "", "only events by this caller")
+	since := flags.Duration("since", 0, "only events in the last `duration`, such as 24h")
+	flags.IntVar(&filter.Limit, "limit", 0, "print at most `n` events")
+	return func(ctx context.Context, discovery shareddiscovery.SharedDiscovery, out io.Writer) (interface{}, error) {
+		var sink shareddiscovery.AuditSink
+		switch {
+		case *file != "":
+			sink = &shareddiscovery.FileAuditSink{Path: *file}
+		case *table != "":
+			sink = shareddiscovery.DynamoDBAuditSink{DynamoDB: discovery.DynamodbSvc, Table: *table}
+		default:
+			return nil, fmt.Errorf("%w: -table or -file", discoveryhttp.ErrMissingParameter)
+		}
+		if *since > 0 {
+			filter.Since = time.Now().Add(-*since)
+		}
+		events, err := sink.Query(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		if events == nil {
+			events = []shareddiscovery.AuditEvent{}
+		}
+		return events, nil
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/pgdevelopers/shareddiscovery/v2"
+	"github.com/pgdevelopers/shareddiscovery/v2/mocks/mock_dynamodbiface"
+)
+
+func TestAudit(t *testing.T) {
+	useDynamoDB(t, mock_dynamodbiface.NewMockDynamoDBAPI(gomock.NewController(t)))
+	sink := &shareddiscovery.FileAuditSink{Path: filepath.Join(t.TempDir(), "audit.jsonl")}
+	for _, event := range []shareddiscovery.AuditEvent{
+		{Time: time.Now().Add(-48 * time.Hour), Caller: "alice", Workspace: "discovery_app", App: "sonos", Outcome: "success"},
+		{Time: time.Now().Add(-time.Hour), Caller: "bob", Workspace: "discovery_app", Outcome: "error", Error: "invalid_signature"},
+	} {
+		if err := sink.Record(context.TODO(), event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	code, stdout, stderr := runArgs("audit", "-file", sink.Path, "-since", "24h", "-output", "yaml")
+	if code != 0 || !strings.Contains(stdout, "caller: bob") || strings.Contains(stdout, "alice") {
+		t.Errorf("discoveryctl audit -since 24h == %d %q %q, want only bob's event", code, stdout, stderr)
+	}
+	if code, _, _ := runArgs("audit"); code != 2 {
+		t.Errorf("discoveryctl audit without a sink == %d, want 2", code)
+	}
+}
@@ -0,0 +1,293 @@
+// Command discoveryctl reads discovery tables and mints admin tokens from the
+// command line.
+//
+// Usage:
+//
+//	discoveryctl <command> [flags]
+//
+// The commands are:
+//
+//	get-config     print the config for an apiToken
+//	validate       check an appName exists in a country
+//	admin-token    sign an admin query and print the apiToken it returns
+//	sign           print the signature of an admin query
+//	list           print every item in a workspace
+//	search         print the items of a workspace matching conditions
+//	diff           compare two configs and save the changes for promote
+//	promote        apply changes saved by diff
+//	export         write every item in a workspace as JSON Lines or YAML
+//	import         load items written by export into a workspace
+//	audit          print the audit trail of admin token retrievals
+//	ensure-tables  create the discovery tables and indexes that are missing
+//
+// Every command accepts -endpoint to talk to a local stand-in such as
+// DynamoDB Local, -region, and -output to print json, yaml or a table.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/pgdevelopers/shareddiscovery/v2"
+	"github.com/pgdevelopers/shareddiscovery/v2/discoveryhttp"
+)
+
+// newDynamoDB builds the client commands run against. Tests replace it.
+var newDynamoDB = func(endpoint, region string) (dynamodbiface.DynamoDBAPI, error) {
+	config := aws.NewConfig()
+	if endpoint != "" {
+		config = config.WithEndpoint(endpoint)
+	}
+	if region != "" {
+		config = config.WithRegion(region)
+	}
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Config:            *config,
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return dynamodb.New(sess), nil
+}
+
+// runFunc runs a command whose flags have been parsed and returns what to
+// print, which is printed even alongside an error. Commands that stream
+// their own output to out return nil.
+type runFunc func(ctx context.Context, discovery shareddiscovery.SharedDiscovery, out io.Writer) (interface{}, error)
+
+type command struct {
+	summary string
+	// setup registers the command's flags and returns how to run it.
+	setup func(flags *flag.FlagSet) runFunc
+}
+
+var commands = map[string]command{
+	"get-config":    {"print the config for an apiToken", getConfig},
+	"validate":      {"check an appName exists in a country", validate},
+	"admin-token":   {"sign an admin query and print the apiToken it returns", adminToken},
+	"sign":          {"print the signature of an admin query", sign},
+	"list":          {"print every item in a workspace", list},
+	"search":        {"print the items of a workspace matching conditions", search},
+	"diff":          {"compare two configs and save the changes for promote", diff},
+	"promote":       {"apply changes saved by diff", promote},
+	"export":        {"write every item in a workspace as JSON Lines or YAML", export},
+	"import":        {"load items written by export into a workspace", importItems},
+	"audit":         {"print the audit trail of admin token retrievals", audit},
+	"ensure-tables": {"create the discovery tables and indexes that are missing", ensureTables},
+}
+
+func main() {
+	os.Exit(run(context.Background(), os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		usage(stderr)
+		return 2
+	}
+	name := args[0]
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(stderr, "discoveryctl: unknown command %q\n", name)
+		usage(stderr)
+		return 2
+	}
+
+	flags := flag.NewFlagSet("discoveryctl "+name, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	endpoint := flags.String("endpoint", os.Getenv("DISCOVERY_ENDPOINT"), "DynamoDB endpoint `url`, such as a local stand-in")
+	region := flags.String("region", os.Getenv("AWS_REGION"), "AWS region")
+	output := flags.String("output", "json", "output `format`: json, yaml or table")
+	runCommand := cmd.setup(flags)
+	if err := flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	dynamo, err := newDynamoDB(*endpoint, *region)
+	if err != nil {
+		fmt.Fprintf(stderr, "discoveryctl %s: %v\n", name, err)
+		return 1
+	}
+	result, err := runCommand(ctx, shareddiscovery.New(dynamo), stdout)
+	if result != nil {
+		if writeErr := write(stdout, *output, result); writeErr != nil && err == nil {
+			err = writeErr
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "discoveryctl %s: %v\n", name, err)
+		if errors.Is(err, discoveryhttp.ErrMissingParameter) {
+			return 2
+		}
+		return 1
+	}
+	return 0
+}
+
+func usage(w io.Writer) {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(w, "usage: discoveryctl <command> [flags]")
+	fmt.Fprintln(w)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-14s %s\n", name, commands[name].summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run discoveryctl <command> -h for the flags of a command.")
+}
+
+// queryFlags registers the flags describing a QueryInput.
+func queryFlags(flags *flag.FlagSet, workspace string) *shareddiscovery.QueryInput {
+	query := &shareddiscovery.QueryInput{}
+	flags.StringVar(&query.Workspace, "workspace", workspace, "discovery table")
+	flags.StringVar(&query.AppName, "app", "", "appName")
+	flags.StringVar(&query.Brand, "brand", "", "brand")
+	flags.StringVar(&query.Country, "country", "", "countryCode")
+	flags.StringVar(&query.Environment, "environment", "", "environment")
+	return query
+}
+
+// signedQuery returns query with the query string an admin caller would
+// send, signed with secretKey.
+func signedQuery(query shareddiscovery.QueryInput, secretKey string) shareddiscovery.QueryInput {
+	query.QueryString = map[string]string{}
+	for param, value := range map[string]string{
+		discoveryhttp.ParamAppName:     query.AppName,
+		discoveryhttp.ParamBrand:       query.Brand,
+		discoveryhttp.ParamCountry:     query.Country,
+		discoveryhttp.ParamEnvironment: query.Environment,
+	} {
+		if value != "" {
+			query.QueryString[param] = value
+		}
+	}
+	query.Signature = shareddiscovery.Sign(secretKey, query)
+	return query
+}
+
+func secretKeyFlag(flags *flag.FlagSet) *string {
+	return flags.String("secret-key", os.Getenv("DISCOVERY_SECRET_KEY"), "admin secret key, defaults to $DISCOVERY_SECRET_KEY")
+}
+
+func require(params map[string]string) error {
+	var missing []string
+	for name, value := range params {
+		if value == "" {
+			missing = append(missing, "-"+name)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	sort.Strings(missing)
+	return fmt.Errorf("%w: %s", discoveryhttp.ErrMissingParameter, strings.Join(missing, ", "))
+}
+
+func getConfig(flags *flag.FlagSet) runFunc {
+	query := queryFlags(flags, "")
+	token := flags.String("token", "", "apiToken")
+	fields := flags.String("fields", "", "comma separated `fields` to print, such as name,urls.api")
+	consistent := flags.Bool("consistent", false, "make a strongly consistent read")
+	return func(ctx context.Context, discovery shareddiscovery.SharedDiscovery, out io.Writer) (interface{}, error) {
+		if err := require(map[string]string{"workspace": query.Workspace, "token": *token}); err != nil {
+			return nil, err
+		}
+		var opts []shareddiscovery.CallOption
+		if *fields != "" {
+			opts = append(opts, shareddiscovery.WithFields(strings.Split(*fields, ",")...))
+		}
+		if *consistent {
+			opts = append(opts, shareddiscovery.WithConsistentRead())
+		}
+		config, err := discovery.GetConfig(ctx, *token, *query, opts...)
+		if err == nil && config == nil {
+			err = shareddiscovery.ErrNoResults
+		}
+		if err != nil {
+			return nil, err
+		}
+		return config, nil
+	}
+}
+
+func validate(flags *flag.FlagSet) runFunc {
+	query := queryFlags(flags, "")
+	return func(ctx context.Context, discovery shareddiscovery.SharedDiscovery, out io.Writer) (interface{}, error) {
+		if err := require(map[string]string{"app": query.AppName, "country": query.Country}); err != nil {
+			return nil, err
+		}
+		valid, err := discovery.GetValidation(ctx, *query)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]bool{"valid": valid}, nil
+	}
+}
+
+func adminToken(flags *flag.FlagSet) runFunc {
+	query := queryFlags(flags, "discovery_app")
+	secretKey := secretKeyFlag(flags)
+	return func(ctx context.Context, discovery shareddiscovery.SharedDiscovery, out io.Writer) (interface{}, error) {
+		if err := require(map[string]string{"secret-key": *secretKey}); err != nil {
+			return nil, err
+		}
+		token, err := discovery.AdminGetAPIToken(ctx, *secretKey, signedQuery(*query, *secretKey))
+		if err != nil {
+			return nil, err
+		}
+		return map[string]string{discoveryhttp.ParamAPIToken: token}, nil
+	}
+}
+
+func sign(flags *flag.FlagSet) runFunc {
+	query := queryFlags(flags, "discovery_app")
+	secretKey := secretKeyFlag(flags)
+	return func(ctx context.Context, discovery shareddiscovery.SharedDiscovery, out io.Writer) (interface{}, error) {
+		if err := require(map[string]string{"secret-key": *secretKey}); err != nil {
+			return nil, err
+		}
+		return map[string]string{discoveryhttp.ParamSignature: signedQuery(*query, *secretKey).Signature}, nil
+	}
+}
+
+func list(flags *flag.FlagSet) runFunc {
+	workspace := flags.String("workspace", "", "discovery table")
+	return func(ctx context.Context, discovery shareddiscovery.SharedDiscovery, out io.Writer) (interface{}, error) {
+		if err := require(map[string]string{"workspace": *workspace}); err != nil {
+			return nil, err
+		}
+		items := []map[string]interface{}{}
+		var unmarshalErr error
+		err := discovery.DynamodbSvc.ScanPagesWithContext(ctx, &dynamodb.ScanInput{TableName: workspace}, func(page *dynamodb.ScanOutput, last bool) bool {
+			var pageItems []map[string]interface{}
+			if unmarshalErr = dynamodbattribute.UnmarshalListOfMaps(page.Items, &pageItems); unmarshalErr != nil {
+				return false
+			}
+			items = append(items, pageItems...)
+			return true
+		})
+		if err == nil {
+			err = unmarshalErr
+		}
+		if err != nil {
+			return nil, err
+		}
+		return items, nil
+	}
+}
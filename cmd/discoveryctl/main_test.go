@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/golang/mock/gomock"
+	"github.com/pgdevelopers/shareddiscovery/v2/mocks/mock_dynamodbiface"
+)
+
+// useDynamoDB points commands at mock for the rest of the test.
+func useDynamoDB(t *testing.T, mock dynamodbiface.DynamoDBAPI) {
+	original := newDynamoDB
+	newDynamoDB = func(endpoint, region string) (dynamodbiface.DynamoDBAPI, error) {
+		return mock, nil
+	}
+	t.Cleanup(func() { newDynamoDB = original })
+}
+
+func runArgs(args ...string) (code int, stdout, stderr string) {
+	var out, errOut bytes.Buffer
+	code = run(context.TODO(), args, &out, &errOut)
+	return code, out.String(), errOut.String()
+}
+
+func TestSign(t *testing.T) {
+	useDynamoDB(t, mock_dynamodbiface.NewMockDynamoDBAPI(gomock.NewController(t)))
+
+	code, stdout, stderr := runArgs("sign", "-secret-key", "secretKey", "-app", "sonos", "-brand", "oralb", "-country", "US", "-environment", "qa")
+
+	want := "545ec3b117f066cf89e18b876ac4534ea900e245c76915003d143488076e1f64"
+	if code != 0 || !strings.Contains(stdout, want) {
+		t.Errorf("discoveryctl sign == %d %q %q, want the signature %q", code, stdout, stderr, want)
+	}
+}
+
+func TestGetConfig(t *testing.T) {
+	mockDynamoDB := mock_dynamodbiface.NewMockDynamoDBAPI(gomock.NewController(t))
+	useDynamoDB(t, mockDynamoDB)
+
+	mockDynamoDB.
+		EXPECT().
+		GetItemWithContext(gomock.Any(), &dynamodb.GetItemInput{
+			TableName: aws.String("apps"),
+			Key: map[string]*dynamodb.AttributeValue{
+				"apiToken":    {S: aws.String("abc")},
+				"countryCode": {S: aws.String("US")},
+			},
+		}).
+		Return(&dynamodb.GetItemOutput{Item: map[string]*dynamodb.AttributeValue{
+			"name": {S: aws.String("app")},
+		}}, nil)
+
+	code, stdout, stderr := runArgs("get-config", "-workspace", "apps", "-token", "abc", "-country", "US", "-output", "yaml")
+
+	if code != 0 || stdout != "name: app\n" {
+		t.Errorf("discoveryctl get-config == %d %q %q, want the config as YAML", code, stdout, stderr)
+	}
+}
+
+func TestGetConfig_Fields(t *testing.T) {
+	mockDynamoDB := mock_dynamodbiface.NewMockDynamoDBAPI(gomock.NewController(t))
+	useDynamoDB(t, mockDynamoDB)
+
+	mockDynamoDB.
+		EXPECT().
+		GetItemWithContext(gomock.Any(), &dynamodb.GetItemInput{
+			TableName:                aws.String("apps"),
+			Key:                      map[string]*dynamodb.AttributeValue{"apiToken": {S: aws.String("abc")}},
+			ConsistentRead:           aws.Bool(true),
+			ProjectionExpression:     aws.String("#0, #1"),
+			ExpressionAttributeNames: map[string]*string{"#0": aws.String("name"), "#1": aws.String("version")},
+		}).
+		Return(&dynamodb.GetItemOutput{Item: map[string]*dynamodb.AttributeValue{
+			"name": {S: aws.String("app")},
+		}}, nil)
+
+	code, stdout, stderr := runArgs("get-config", "-workspace", "apps", "-token", "abc", "-fields", "name,version", "-consistent", "-output", "yaml")
+
+	if code != 0 || stdout != "name: app\n" {
+		t.Errorf("discoveryctl get-config -fields -consistent == %d %q %q, want the config as YAML", code, stdout, stderr)
+	}
+}
+
+func TestGetConfig_MissingParameter(t *testing.T) {
+	useDynamoDB(t, mock_dynamodbiface.NewMockDynamoDBAPI(gomock.NewController(t)))
+
+	code, _, stderr := runArgs("get-config", "-workspace", "apps")
+
+	if code != 2 || !strings.Contains(stderr, "-token") {
+		t.Errorf("discoveryctl get-config without -token == %d %q, want 2 naming -token", code, stderr)
+	}
+}
+
+func TestAdminToken(t *testing.T) {
+	mockDynamoDB := mock_dynamodbiface.NewMockDynamoDBAPI(gomock.NewController(t))
+	useDynamoDB(t, mockDynamoDB)
+
+	mockDynamoDB.
+		EXPECT().
+		QueryWithContext(gomock.Any(), gomock.Any()).
+		Return(&dynamodb.QueryOutput{Items: []map[string]*dynamodb.AttributeValue{
+			{"apiToken": {S: aws.String("abc")}},
+		}}, nil)
+
+	code, stdout, stderr := runArgs("admin-token", "-secret-key", "secretKey", "-app", "sonos", "-brand", "oralb", "-country", "US", "-environment", "qa")
+
+	var body map[string]string
+	if err := json.Unmarshal([]byte(stdout), &body); code != 0 || err != nil || body["apiToken"] != "abc" {
+		t.Errorf("discoveryctl admin-token == %d %q %q, want the apiToken", code, stdout, stderr)
+	}
+}
+
+func TestList(t *testing.T) {
+	mockDynamoDB := mock_dynamodbiface.NewMockDynamoDBAPI(gomock.NewController(t))
+	useDynamoDB(t, mockDynamoDB)
+
+	mockDynamoDB.
+		EXPECT().
+		ScanPagesWithContext(gomock.Any(), &dynamodb.ScanInput{TableName: aws.String("apps")}, gomock.Any()).
+		DoAndReturn(func(ctx aws.Context, input *dynamodb.ScanInput, fn func(*dynamodb.ScanOutput, bool) bool, opts ...request.Option) error {
+			fn(&dynamodb.ScanOutput{Items: []map[string]*dynamodb.AttributeValue{
+				{"apiToken": {S: aws.String("abc")}, "countryCode": {S: aws.String("US")}},
+			}}, false)
+			fn(&dynamodb.ScanOutput{Items: []map[string]*dynamodb.AttributeValue{
+				{"apiToken": {S: aws.String("def")}},
+			}}, true)
+			return nil
+		})
+
+	code, stdout, stderr := runArgs("list", "-workspace", "apps", "-output", "table")
+
+	want := "APITOKEN  COUNTRYCODE\nabc       US\ndef       -\n"
+	if code != 0 || stdout != want {
+		t.Errorf("discoveryctl list == %d %q %q, want %q", code, stdout, stderr, want)
+	}
+}
+
+func TestRun_UnknownCommand(t *testing.T) {
+	if code, _, stderr := runArgs("nope"); code != 2 || !strings.Contains(stderr, "usage") {
+		t.Errorf("discoveryctl nope == %d %q, want 2 with usage", code, stderr)
+	}
+}
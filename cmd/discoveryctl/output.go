@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v3"
+)
+
+// write prints result to w in format.
+func write(w io.Writer, format string, result interface{}) error {
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(result)
+	case "yaml":
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(result); err != nil {
+			return err
+		}
+		return encoder.Close()
+	case "table":
+		return writeTable(w, result)
+	default:
+		return fmt.Errorf("unknown output format %q, want json, yaml or table", format)
+	}
+}
+
+// writeTable prints an object as KEY/VALUE rows and a list of objects with
+// one column per key. Nested values are printed as JSON.
+func writeTable(w io.Writer, result interface{}) error {
+	// round trip through JSON so structs and maps are handled alike
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	var generic interface{}
+	if err := json.Unmarshal(data, &generic); err != nil {
+		return err
+	}
+
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	switch v := generic.(type) {
+	case map[string]interface{}:
+		fmt.Fprintln(table, "KEY\tVALUE")
+		for _, key := range sortedKeys(v) {
+			fmt.Fprintf(table, "%s\t%s\n", key, cell(v[key]))
+		}
+	case []interface{}:
+		var columns []string
+		seen := map[string]bool{}
+		for _, row := range v {
+			object, _ := row.(map[string]interface{})
+			for _, key := range sortedKeys(object) {
+				if !seen[key] {
+					seen[key] = true
+					columns = append(columns, key)
+				}
+			}
+		}
+		sort.Strings(columns)
+
+		header := make([]string, len(columns))
+		for i, column := range columns {
+			header[i] = strings.ToUpper(column)
+		}
+		fmt.Fprintln(table, strings.Join(header, "\t"))
+		for _, row := range v {
+			object, _ := row.(map[string]interface{})
+			cells := make([]string, len(columns))
+			for i, column := range columns {
+				cells[i] = cell(object[column])
+			}
+			fmt.Fprintln(table, strings.Join(cells, "\t"))
+		}
+	default:
+		fmt.Fprintln(table, cell(v))
+	}
+	return table.Flush()
+}
+
+func cell(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "-"
+	case string:
+		return v
+	case float64, bool:
+		return fmt.Sprint(v)
+	default:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+}
+
+func sortedKeys(object map[string]interface{}) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
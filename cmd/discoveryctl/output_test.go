@@ -0,0 +1,31 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	result := map[string]interface{}{"name": "app", "urls": map[string]interface{}{"api": "https://qa"}}
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"json", "{\n  \"name\": \"app\",\n  \"urls\": {\n    \"api\": \"https://qa\"\n  }\n}\n"},
+		{"yaml", "name: app\nurls:\n  api: https://qa\n"},
+		{"table", "KEY   VALUE\nname  app\nurls  {\"api\":\"https://qa\"}\n"},
+	}
+
+	for _, test := range tests {
+		var out bytes.Buffer
+		if err := write(&out, test.format, result); err != nil || out.String() != test.want {
+			t.Errorf("write(w, %q, %v) == %q (%v), want %q", test.format, result, out.String(), err, test.want)
+		}
+	}
+}
+
+func TestWrite_UnknownFormat(t *testing.T) {
+	if err := write(&bytes.Buffer{}, "xml", nil); err == nil {
+		t.Errorf("write(w, %q, nil) == nil, want an error", "xml")
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/pgdevelopers/shareddiscovery/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// changeRow is how a Change is printed, with plain values.
+type changeRow struct {
+	Path string      `json:"path" yaml:"path"`
+	Type string      `json:"type" yaml:"type"`
+	From interface{} `json:"from" yaml:"from"`
+	To   interface{} `json:"to" yaml:"to"`
+}
+
+func changeRows(changes []shareddiscovery.Change) ([]changeRow, error) {
+	rows := make([]changeRow, len(changes))
+	for i, change := range changes {
+		rows[i] = changeRow{Path: change.Path, Type: string(change.Type)}
+		for _, value := range []struct {
+			attribute *dynamodb.AttributeValue
+			into      *interface{}
+		}{{change.From, &rows[i].From}, {change.To, &rows[i].To}} {
+			if value.attribute == nil {
+				continue
+			}
+			if err := dynamodbattribute.Unmarshal(value.attribute, value.into); err != nil {
+				return nil, fmt.Errorf("%s: %w", change.Path, err)
+			}
+		}
+	}
+	return rows, nil
+}
+
+// diff prints what promoting the -against config would change in the config
+// named by -workspace, -token and -country.
+func diff(flags *flag.FlagSet) runFunc {
+	target := queryFlags(flags, "")
+	targetToken := flags.String("token", "", "apiToken")
+	source := &shareddiscovery.QueryInput{}
+	flags.StringVar(&source.Workspace, "against-workspace", "", "discovery table to compare with, defaults to -workspace")
+	flags.StringVar(&source.Country, "against-country", "", "countryCode to compare with, defaults to -country")
+	sourceToken := flags.String("against-token", "", "apiToken to compare with, defaults to -token")
+	file := flags.String("file", "", "file to save the change set to as JSON or YAML, for promote")
+	return func(ctx context.Context, discovery shareddiscovery.SharedDiscovery, out io.Writer) (interface{}, error) {
+		if err := require(map[string]string{"workspace": target.Workspace, "token": *targetToken}); err != nil {
+			return nil, err
+		}
+		if source.Workspace == "" {
+			source.Workspace = target.Workspace
+		}
+		if source.Country == "" {
+			source.Country = target.Country
+		}
+		if *sourceToken == "" {
+			*sourceToken = *targetToken
+		}
+
+		changes, err := discovery.Diff(ctx,
+			shareddiscovery.ConfigKey{Workspace: source.Workspace, APIToken: *sourceToken, Country: source.Country},
+			shareddiscovery.ConfigKey{Workspace: target.Workspace, APIToken: *targetToken, Country: target.Country},
+		)
+		if err != nil {
+			return nil, err
+		}
+		if *file != "" {
+			if err := saveChangeSet(*file, changes); err != nil {
+				return nil, err
+			}
+		}
+		return changeRows(changes.Changes)
+	}
+}
+
+// promote applies a change set saved by diff and prints the changes made.
+func promote(flags *flag.FlagSet) runFunc {
+	file := flags.String("file", "", "change set saved by diff -file")
+	dryRun := flags.Bool("dry-run", false, "print the changes without writing them")
+	return func(ctx context.Context, discovery shareddiscovery.SharedDiscovery, out io.Writer) (interface{}, error) {
+		if err := require(map[string]string{"file": *file}); err != nil {
+			return nil, err
+		}
+		changes, err := loadChangeSet(*file)
+		if err != nil {
+			return nil, err
+		}
+		rows, err := changeRows(changes.Changes)
+		if err != nil || *dryRun {
+			return rows, err
+		}
+		if err := discovery.Promote(ctx, changes); err != nil {
+			return nil, err
+		}
+		return rows, nil
+	}
+}
+
+// changeSetFormat is yaml for .yaml and .yml files and json otherwise.
+func changeSetFormat(file string) string {
+	switch filepath.Ext(file) {
+	case ".yaml", ".yml":
+		return "yaml"
+	default:
+		return "json"
+	}
+}
+
+func saveChangeSet(file string, changes shareddiscovery.ChangeSet) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	if err := write(f, changeSetFormat(file), changes); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func loadChangeSet(file string) (changes shareddiscovery.ChangeSet, err error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return changes, err
+	}
+	// JSON is valid YAML, so either format decodes
+	if err := yaml.Unmarshal(data, &changes); err != nil {
+		return changes, fmt.Errorf("%s: %w", file, err)
+	}
+	return changes, nil
+}
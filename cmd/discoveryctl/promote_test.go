@@ -0,0 +1,85 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/golang/mock/gomock"
+	"github.com/pgdevelopers/shareddiscovery/v2"
+	"github.com/pgdevelopers/shareddiscovery/v2/discoverytest"
+	"github.com/pgdevelopers/shareddiscovery/v2/mocks/mock_dynamodbiface"
+)
+
+func TestDiff(t *testing.T) {
+	mockDynamoDB := mock_dynamodbiface.NewMockDynamoDBAPI(gomock.NewController(t))
+	useDynamoDB(t, mockDynamoDB)
+
+	mockDynamoDB.
+		EXPECT().
+		GetItemWithContext(gomock.Any(), &dynamodb.GetItemInput{
+			TableName:      aws.String("apps_qa"),
+			Key:            map[string]*dynamodb.AttributeValue{"apiToken": {S: aws.String("abc")}},
+			ConsistentRead: aws.Bool(true),
+		}).
+		Return(&dynamodb.GetItemOutput{Item: map[string]*dynamodb.AttributeValue{
+			"name": {S: aws.String("app")},
+			"urls": {M: map[string]*dynamodb.AttributeValue{"api": {S: aws.String("https://qa")}}},
+		}}, nil)
+	mockDynamoDB.
+		EXPECT().
+		GetItemWithContext(gomock.Any(), &dynamodb.GetItemInput{
+			TableName:      aws.String("apps_prod"),
+			Key:            map[string]*dynamodb.AttributeValue{"apiToken": {S: aws.String("abc")}},
+			ConsistentRead: aws.Bool(true),
+		}).
+		Return(&dynamodb.GetItemOutput{Item: map[string]*dynamodb.AttributeValue{
+			"name":  {S: aws.String("app")},
+			"urls":  {M: map[string]*dynamodb.AttributeValue{"api": {S: aws.String("https://prod")}}},
+			"extra": {BOOL: aws.Bool(true)},
+		}}, nil)
+
+	code, stdout, stderr := runArgs("diff", "-workspace", "apps_qa", "-token", "abc", "-against-workspace", "apps_prod", "-output", "table")
+
+	want := "FROM        PATH      TO            TYPE\n-           extra     true          added\nhttps://qa  urls.api  https://prod  changed\n"
+	if code != 0 || stdout != want {
+		t.Errorf("discoveryctl diff == %d %q %q, want %q", code, stdout, stderr, want)
+	}
+}
+
+func TestPromote(t *testing.T) {
+	db := discoverytest.NewDynamoDB()
+	useDynamoDB(t, db)
+	file := filepath.Join(t.TempDir(), "changes.yaml")
+	for table, timeout := range map[string]int{"apps_qa": 5, "apps_prod": 3} {
+		db.Seed(t, shareddiscovery.TableDefinition{HashKey: "apiToken"}.CreateTableInput(table),
+			map[string]interface{}{"apiToken": "abc", "timeout": timeout})
+	}
+
+	if code, _, stderr := runArgs("diff", "-workspace", "apps_prod", "-token", "abc", "-against-workspace", "apps_qa", "-file", file); code != 0 {
+		t.Fatalf("discoveryctl diff -file == %d %q, want 0", code, stderr)
+	}
+	if code, _, stderr := runArgs("promote", "-file", file, "-dry-run"); code != 0 {
+		t.Fatalf("discoveryctl promote -dry-run == %d %q, want 0", code, stderr)
+	}
+	if output, _ := db.GetItem(&dynamodb.GetItemInput{TableName: aws.String("apps_prod"), Key: map[string]*dynamodb.AttributeValue{"apiToken": {S: aws.String("abc")}}}); aws.StringValue(output.Item["timeout"].N) != "3" {
+		t.Errorf("apps_prod after promote -dry-run == %v, want it untouched", output.Item)
+	}
+
+	code, stdout, stderr := runArgs("promote", "-file", file, "-output", "table")
+
+	want := "FROM  PATH     TO  TYPE\n3     timeout  5   changed\n"
+	if code != 0 || stdout != want {
+		t.Errorf("discoveryctl promote == %d %q %q, want %q", code, stdout, stderr, want)
+	}
+	if output, _ := db.GetItem(&dynamodb.GetItemInput{TableName: aws.String("apps_prod"), Key: map[string]*dynamodb.AttributeValue{"apiToken": {S: aws.String("abc")}}}); aws.StringValue(output.Item["timeout"].N) != "5" {
+		t.Errorf("apps_prod after promote == %v, want the qa timeout", output.Item)
+	}
+
+	// the change set is stale now that prod holds the qa value
+	if code, _, stderr := runArgs("promote", "-file", file); code != 1 || !strings.Contains(stderr, "changed since the diff") {
+		t.Errorf("discoveryctl promote of a stale change set == %d %q, want a conflict", code, stderr)
+	}
+}
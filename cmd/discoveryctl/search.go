@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pgdevelopers/shareddiscovery/v2"
+)
+
+// whereFlag collects repeated -where conditions.
+type whereFlag []string
+
+func (where *whereFlag) String() string { return strings.Join(*where, " ") }
+
+func (where *whereFlag) Set(condition string) error {
+	*where = append(*where, condition)
+	return nil
+}
+
+// whereOperators are the operators of -where conditions, longest first so
+// "<=" is not read as "<".
+var whereOperators = []struct {
+	symbol    string
+	predicate func(attribute string, value interface{}) shareddiscovery.Predicate
+}{
+	{"!=", shareddiscovery.NotEqual},
+	{"<=", shareddiscovery.LessThanEqual},
+	{">=", shareddiscovery.GreaterThanEqual},
+	{"^=", func(attribute string, value interface{}) shareddiscovery.Predicate {
+		return shareddiscovery.BeginsWith(attribute, fmt.Sprint(value))
+	}},
+	{"~=", func(attribute string, value interface{}) shareddiscovery.Predicate {
+		return shareddiscovery.Contains(attribute, fmt.Sprint(value))
+	}},
+	{"=", shareddiscovery.Equal},
+	{"<", shareddiscovery.LessThan},
+	{">", shareddiscovery.GreaterThan},
+}
+
+// parseWhere turns a condition such as "sdkVersion=2.1" into a predicate.
+// Values are strings unless the attribute is one of numeric.
+func parseWhere(condition string, numeric map[string]bool) (shareddiscovery.Predicate, error) {
+	for _, operator := range whereOperators {
+		i := strings.Index(condition, operator.symbol)
+		if i <= 0 {
+			continue
+		}
+		attribute, raw := condition[:i], condition[i+len(operator.symbol):]
+		var value interface{} = raw
+		if numeric[attribute] && operator.symbol != "^=" && operator.symbol != "~=" {
+			number, err := strconv.ParseFloat(raw, 64)
+			if err != nil {
+				return shareddiscovery.Predicate{}, fmt.Errorf("-where %s: %s is not a number", condition, raw)
+			}
+			value = number
+		}
+		return operator.predicate(attribute, value), nil
+	}
+	return shareddiscovery.Predicate{}, fmt.Errorf("-where %s: want attribute, operator and value, such as sdkVersion=2.1", condition)
+}
+
+func search(flags *flag.FlagSet) runFunc {
+	workspace := flags.String("workspace", "discovery_app", "discovery table")
+	var where whereFlag
+	flags.Var(&where, "where", "`condition` such as sdkVersion=2.1, build>=5 or appName^=son; repeat to match all")
+	numeric := flags.String("numeric", "", "comma separated `attributes` whose -where values are numbers")
+	limit := flags.Int64("limit", 0, "print at most `n` items, defaults to 100")
+	cursor := flags.String("cursor", "", "continue from the `cursor` of a previous page")
+	cursorKey := flags.String("cursor-key", os.Getenv("DISCOVERY_CURSOR_KEY"), "key encrypting cursors, defaults to $DISCOVERY_CURSOR_KEY")
+	fields := flags.String("fields", "", "comma separated `fields` to print")
+	return func(ctx context.Context, discovery shareddiscovery.SharedDiscovery, out io.Writer) (interface{}, error) {
+		if err := require(map[string]string{"workspace": *workspace, "cursor-key": *cursorKey}); err != nil {
+			return nil, err
+		}
+		numbers := map[string]bool{}
+		for _, attribute := range strings.Split(*numeric, ",") {
+			numbers[attribute] = true
+		}
+		input := shareddiscovery.SearchInput{Workspace: *workspace, Limit: *limit, Cursor: *cursor}
+		for _, condition := range where {
+			predicate, err := parseWhere(condition, numbers)
+			if err != nil {
+				return nil, err
+			}
+			input.Predicates = append(input.Predicates, predicate)
+		}
+		var opts []shareddiscovery.CallOption
+		if *fields != "" {
+			opts = append(opts, shareddiscovery.WithFields(strings.Split(*fields, ",")...))
+		}
+		discovery.CursorKey = []byte(*cursorKey)
+		result, err := discovery.Search(ctx, input, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+}
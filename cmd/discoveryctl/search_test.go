@@ -0,0 +1,32 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/pgdevelopers/shareddiscovery/v2"
+	"github.com/pgdevelopers/shareddiscovery/v2/discoverytest"
+)
+
+func TestSearch(t *testing.T) {
+	db := discoverytest.NewDynamoDB()
+	db.Seed(t, shareddiscovery.DiscoveryAppTable.CreateTableInput("discovery_app"),
+		map[string]interface{}{"apiToken": "a", "appName": "sonos", "countryCode": "US", "sdkVersion": "2.1", "build": 7},
+		map[string]interface{}{"apiToken": "b", "appName": "razor", "countryCode": "US", "sdkVersion": "2.1", "build": 3},
+		map[string]interface{}{"apiToken": "c", "appName": "brush", "countryCode": "US", "sdkVersion": "1.9", "build": 9},
+	)
+	useDynamoDB(t, db)
+
+	code, stdout, stderr := runArgs("search", "-cursor-key", "key", "-where", "sdkVersion=2.1", "-where", "build>=5", "-numeric", "build")
+	var result shareddiscovery.SearchResult
+	if err := json.Unmarshal([]byte(stdout), &result); code != 0 || err != nil || len(result.Items) != 1 || result.Items[0]["apiToken"] != "a" {
+		t.Errorf("discoveryctl search == %d %q %q, want the sonos app", code, stdout, stderr)
+	}
+
+	if code, _, stderr := runArgs("search", "-cursor-key", "key", "-where", "sdkVersion"); code != 1 {
+		t.Errorf("discoveryctl search -where sdkVersion == %d %q, want 1", code, stderr)
+	}
+	if code, _, stderr := runArgs("search", "-where", "sdkVersion=2.1"); code != 2 {
+		t.Errorf("discoveryctl search without -cursor-key == %d %q, want 2", code, stderr)
+	}
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"io"
+	"strings"
+
+	"github.com/pgdevelopers/shareddiscovery/v2"
+)
+
+func ensureTables(flags *flag.FlagSet) runFunc {
+	workspaces := flags.String("workspaces", "", "comma separated config `workspaces` to create besides discovery_app")
+	dryRun := flags.Bool("dry-run", false, "print the changes without making them")
+	return func(ctx context.Context, discovery shareddiscovery.SharedDiscovery, out io.Writer) (interface{}, error) {
+		if *workspaces != "" {
+			discovery.Workspaces = strings.Split(*workspaces, ",")
+		}
+		changes, err := discovery.EnsureTables(ctx, shareddiscovery.EnsureOptions{DryRun: *dryRun})
+		if changes == nil {
+			changes = []shareddiscovery.TableChange{}
+		}
+		return changes, err
+	}
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/pgdevelopers/shareddiscovery/v2/discoverytest"
+)
+
+func TestEnsureTables(t *testing.T) {
+	useDynamoDB(t, discoverytest.NewDynamoDB())
+
+	code, stdout, stderr := runArgs("ensure-tables", "-workspaces", "apps", "-output", "table")
+	want := "ACTION  TABLE\ncreate  discovery_app\ncreate  apps\n"
+	if code != 0 || stdout != want {
+		t.Errorf("discoveryctl ensure-tables == %d %q %q, want %q", code, stdout, stderr, want)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/pgdevelopers/shareddiscovery/v2"
+)
+
+// importResult is how an ImportChange is printed.
+type importResult struct {
+	APIToken string   `json:"apiToken" yaml:"apiToken"`
+	Country  string   `json:"countryCode,omitempty" yaml:"countryCode,omitempty"`
+	Action   string   `json:"action" yaml:"action"`
+	Changed  []string `json:"changed,omitempty" yaml:"changed,omitempty"`
+	Error    string   `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+func export(flags *flag.FlagSet) runFunc {
+	workspace := flags.String("workspace", "", "discovery table")
+	file := flags.String("file", "-", "file to write, - for stdout")
+	format := flags.String("format", "", "jsonl or yaml, defaults to the -file extension or jsonl")
+	return func(ctx context.Context, discovery shareddiscovery.SharedDiscovery, out io.Writer) (interface{}, error) {
+		if err := require(map[string]string{"workspace": *workspace}); err != nil {
+			return nil, err
+		}
+		if *file != "-" {
+			f, err := os.Create(*file)
+			if err != nil {
+				return nil, err
+			}
+			defer f.Close()
+			out = f
+		}
+		_, err := discovery.Export(ctx, *workspace, out, exportFormat(*format, *file))
+		return nil, err
+	}
+}
+
+func importItems(flags *flag.FlagSet) runFunc {
+	workspace := flags.String("workspace", "", "discovery table")
+	file := flags.String("file", "", "file to read, - for stdin")
+	format := flags.String("format", "", "jsonl or yaml, defaults to the -file extension or jsonl")
+	dryRun := flags.Bool("dry-run", false, "print the changes without writing them")
+	return func(ctx context.Context, discovery shareddiscovery.SharedDiscovery, out io.Writer) (interface{}, error) {
+		if err := require(map[string]string{"workspace": *workspace, "file": *file}); err != nil {
+			return nil, err
+		}
+		var in io.Reader = os.Stdin
+		if *file != "-" {
+			f, err := os.Open(*file)
+			if err != nil {
+				return nil, err
+			}
+			defer f.Close()
+			in = f
+		}
+
+		changes, err := discovery.Import(ctx, *workspace, in, exportFormat(*format, *file), shareddiscovery.ImportOptions{DryRun: *dryRun})
+		if err != nil {
+			return nil, err
+		}
+		results := make([]importResult, len(changes))
+		failed := 0
+		for i, change := range changes {
+			results[i] = importResult{
+				APIToken: change.Key.APIToken,
+				Country:  change.Key.Country,
+				Action:   string(change.Action),
+				Changed:  change.Changed,
+			}
+			if change.Err != nil {
+				results[i].Error = change.Err.Error()
+				failed++
+			}
+		}
+		if failed > 0 {
+			return results, fmt.Errorf("%d of %d items failed", failed, len(changes))
+		}
+		return results, nil
+	}
+}
+
+func exportFormat(format, file string) shareddiscovery.ExportFormat {
+	if format != "" {
+		return shareddiscovery.ExportFormat(format)
+	}
+	switch filepath.Ext(file) {
+	case ".yaml", ".yml":
+		return shareddiscovery.ExportYAML
+	default:
+		return shareddiscovery.ExportJSONLines
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/golang/mock/gomock"
+	"github.com/pgdevelopers/shareddiscovery/v2/mocks/mock_dynamodbiface"
+)
+
+func TestExportImport(t *testing.T) {
+	mockDynamoDB := mock_dynamodbiface.NewMockDynamoDBAPI(gomock.NewController(t))
+	useDynamoDB(t, mockDynamoDB)
+	file := filepath.Join(t.TempDir(), "apps.yaml")
+
+	mockDynamoDB.
+		EXPECT().
+		ScanWithContext(gomock.Any(), gomock.Any()).
+		Return(&dynamodb.ScanOutput{Items: []map[string]*dynamodb.AttributeValue{
+			{"apiToken": {S: aws.String("abc")}, "ports": {NS: aws.StringSlice([]string{"443"})}},
+		}}, nil)
+	mockDynamoDB.
+		EXPECT().
+		DescribeTableWithContext(gomock.Any(), gomock.Any()).
+		Return(&dynamodb.DescribeTableOutput{Table: &dynamodb.TableDescription{KeySchema: []*dynamodb.KeySchemaElement{
+			{AttributeName: aws.String("apiToken"), KeyType: aws.String(dynamodb.KeyTypeHash)},
+		}}}, nil)
+	mockDynamoDB.
+		EXPECT().
+		GetItemWithContext(gomock.Any(), gomock.Any()).
+		Return(&dynamodb.GetItemOutput{}, nil)
+
+	if code, _, stderr := runArgs("export", "-workspace", "apps", "-file", file); code != 0 {
+		t.Fatalf("discoveryctl export == %d %q, want 0", code, stderr)
+	}
+	if data, err := os.ReadFile(file); err != nil || string(data) != "apiToken:\n  S: abc\nports:\n  NS:\n    - \"443\"\n" {
+		t.Errorf("discoveryctl export wrote %q (%v), want typed YAML", data, err)
+	}
+
+	code, stdout, stderr := runArgs("import", "-workspace", "apps_dev", "-file", file, "-dry-run", "-output", "table")
+
+	want := "ACTION  APITOKEN  CHANGED\ncreate  abc       [\"apiToken\",\"ports\"]\n"
+	if code != 0 || stdout != want {
+		t.Errorf("discoveryctl import -dry-run == %d %q %q, want %q", code, stdout, stderr, want)
+	}
+}
@@ -0,0 +1,336 @@
+// Package discoveryhttp serves the discovery library over HTTP. It parses
+// requests into a shareddiscovery.QueryInput, calls the matching
+// shareddiscovery.IFace method and writes a JSON response, so every service
+// exposes the same endpoints, error bodies and status codes:
+//
+//	GET /config           the config for an apiToken
+//	GET /validation       whether an appName exists in a country
+//	GET /admin/token      the apiToken for a signed admin query
+//	GET /health           a readiness report, when the IFace is a HealthChecker
+package discoveryhttp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/pgdevelopers/shareddiscovery/v2"
+)
+
+// Header and query parameter names understood by the handler.
+const (
+	HeaderAPIToken  = "X-Api-Token"
+	HeaderSignature = "X-Signature"
+
+	// HeaderServedCountry is set on config responses to the countryCode of
+	// the row served, which differs from the requested one when the
+	// workspace has a CountryFallback.
+	HeaderServedCountry = "X-Served-Country"
+
+	ParamAPIToken    = "apiToken"
+	ParamSignature   = "signature"
+	ParamWorkspace   = "workspace"
+	ParamAppName     = "appName"
+	ParamBrand       = "brand"
+	ParamCountry     = "countryCode"
+	ParamEnvironment = "environment"
+
+	// ParamFields is a comma separated list of config fields to return and
+	// ParamConsistentRead, when "true", asks for a strongly consistent read.
+	// A Cache-Control: no-cache header skips the library's cache.
+	ParamFields         = "fields"
+	ParamConsistentRead = "consistentRead"
+)
+
+// ErrMissingParameter is returned when a required parameter is absent.
+var ErrMissingParameter = errors.New("missing required parameter")
+
+// SecretKeyFunc returns the admin secret key used to verify the signature of query.
+type SecretKeyFunc func(ctx context.Context, query shareddiscovery.QueryInput) (string, error)
+
+// HealthChecker is implemented by discovery implementations that can report
+// whether they are ready to serve, such as shareddiscovery.SharedDiscovery.
+type HealthChecker interface {
+	HealthCheck(ctx context.Context) (shareddiscovery.HealthReport, error)
+}
+
+// Handler is an http.Handler serving config, validation and admin-token
+// endpoints on top of a shareddiscovery.IFace.
+type Handler struct {
+	Discovery shareddiscovery.IFace
+
+	// SecretKey is required for the admin endpoint. Without it admin
+	// requests are answered with 404.
+	SecretKey SecretKeyFunc
+
+	mux *http.ServeMux
+}
+
+// New returns a Handler for discovery. Mount it under any prefix with http.StripPrefix.
+func New(discovery shareddiscovery.IFace, secretKey SecretKeyFunc) *Handler {
+	handler := &Handler{Discovery: discovery, SecretKey: secretKey, mux: http.NewServeMux()}
+	handler.mux.HandleFunc("/config", get(handler.config))
+	handler.mux.HandleFunc("/validation", get(handler.validation))
+	handler.mux.HandleFunc("/admin/token", get(handler.adminToken))
+	handler.mux.HandleFunc("/health", get(handler.health))
+	return handler
+}
+
+// ServeHTTP implements http.Handler.
+func (handler *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	handler.mux.ServeHTTP(w, r)
+}
+
+// ErrorBody is the JSON body of every error response.
+type ErrorBody struct {
+	Error ErrorDetail `json:"error"`
+}
+
+// ErrorDetail describes an error with a stable code taken from
+// shareddiscovery.ErrorClass and a human readable message.
+type ErrorDetail struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ParseQuery builds a QueryInput from request parameters and headers. Every
+// parameter other than the signature, apiToken and workspace ends up in
+// QueryString, since that is what admin callers sign. The signature is read
+// from the X-Signature header, falling back to the signature parameter.
+func ParseQuery(values url.Values, header http.Header) shareddiscovery.QueryInput {
+	query := shareddiscovery.QueryInput{
+		AppName:     values.Get(ParamAppName),
+		Brand:       values.Get(ParamBrand),
+		Country:     values.Get(ParamCountry),
+		Environment: values.Get(ParamEnvironment),
+		Workspace:   values.Get(ParamWorkspace),
+		Signature:   header.Get(HeaderSignature),
+		QueryString: map[string]string{},
+	}
+	if query.Signature == "" {
+		query.Signature = values.Get(ParamSignature)
+	}
+	for key := range values {
+		if key == ParamSignature || key == ParamAPIToken || key == ParamWorkspace {
+			continue
+		}
+		query.QueryString[key] = values.Get(key)
+	}
+	return query
+}
+
+// APIToken reads the apiToken from the X-Api-Token header, falling back to
+// the apiToken parameter.
+func APIToken(values url.Values, header http.Header) string {
+	if token := header.Get(HeaderAPIToken); token != "" {
+		return token
+	}
+	return values.Get(ParamAPIToken)
+}
+
+// CallOptions builds the shareddiscovery.CallOption values a request asks for.
+func CallOptions(values url.Values, header http.Header) []shareddiscovery.CallOption {
+	var opts []shareddiscovery.CallOption
+	if fields := values.Get(ParamFields); fields != "" {
+		opts = append(opts, shareddiscovery.WithFields(strings.Split(fields, ",")...))
+	}
+	if consistent, _ := strconv.ParseBool(values.Get(ParamConsistentRead)); consistent {
+		opts = append(opts, shareddiscovery.WithConsistentRead())
+	}
+	for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+		if strings.TrimSpace(directive) == "no-cache" {
+			opts = append(opts, shareddiscovery.WithoutCache())
+		}
+	}
+	return opts
+}
+
+// StatusCode maps an error returned by the discovery library to an HTTP status.
+func StatusCode(err error) int {
+	var awsErr awserr.Error
+	switch {
+	case err == nil:
+		return http.StatusOK
+	case errors.Is(err, ErrMissingParameter), errors.As(err, new(*shareddiscovery.ValidationError)):
+		return http.StatusBadRequest
+	case errors.Is(err, shareddiscovery.ErrInvalidSignature):
+		return http.StatusUnauthorized
+	case errors.As(err, new(*shareddiscovery.AuthorizationError)):
+		return http.StatusForbidden
+	case errors.Is(err, shareddiscovery.ErrNoResults):
+		return http.StatusNotFound
+	case errors.Is(err, shareddiscovery.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, shareddiscovery.ErrCircuitOpen), errors.Is(err, shareddiscovery.ErrUnhealthy):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	case errors.As(err, new(*shareddiscovery.RateLimitError)):
+		return http.StatusTooManyRequests
+	case errors.As(err, &awsErr) && request.IsErrorThrottle(err):
+		return http.StatusTooManyRequests
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func (handler *Handler) config(w http.ResponseWriter, r *http.Request) {
+	query := ParseQuery(r.URL.Query(), r.Header)
+	token := APIToken(r.URL.Query(), r.Header)
+	if err := require(map[string]string{ParamAPIToken: token, ParamWorkspace: query.Workspace}); err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	var served string
+	opts := append(CallOptions(r.URL.Query(), r.Header), shareddiscovery.WithServedCountry(&served))
+	config, err := handler.Discovery.GetConfig(r.Context(), token, query, opts...)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	if config == nil {
+		writeJSON(w, http.StatusNotFound, ErrorBody{ErrorDetail{Code: "not_found", Message: "no config found"}})
+		return
+	}
+	if served != "" {
+		w.Header().Set(HeaderServedCountry, served)
+	}
+	writeJSON(w, http.StatusOK, config)
+}
+
+func (handler *Handler) validation(w http.ResponseWriter, r *http.Request) {
+	query := ParseQuery(r.URL.Query(), r.Header)
+	if err := require(map[string]string{ParamAppName: query.AppName, ParamCountry: query.Country}); err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	valid, err := handler.Discovery.GetValidation(r.Context(), query, CallOptions(r.URL.Query(), r.Header)...)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]bool{"valid": valid})
+}
+
+func (handler *Handler) adminToken(w http.ResponseWriter, r *http.Request) {
+	if handler.SecretKey == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	query := ParseQuery(r.URL.Query(), r.Header)
+	if err := require(map[string]string{ParamSignature: query.Signature, ParamWorkspace: query.Workspace}); err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	secretKey, err := handler.SecretKey(r.Context(), query)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	token, err := handler.Discovery.AdminGetAPIToken(r.Context(), secretKey, query, CallOptions(r.URL.Query(), r.Header)...)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{ParamAPIToken: token})
+}
+
+// health answers 200 with the report when every check passes and 503
+// otherwise. Implementations that cannot report, or wrap one that cannot,
+// are answered with 404.
+func (handler *Handler) health(w http.ResponseWriter, r *http.Request) {
+	checker, ok := handler.Discovery.(HealthChecker)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	report, err := checker.HealthCheck(r.Context())
+	if errors.Is(err, shareddiscovery.ErrNoHealthCheck) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil && !errors.Is(err, shareddiscovery.ErrUnhealthy) {
+		WriteError(w, err)
+		return
+	}
+	status := http.StatusOK
+	if !report.Healthy {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, report)
+}
+
+// WriteError writes err as an ErrorBody with the status from StatusCode.
+// Internal errors are not described to the caller, and rate limited ones
+// get a Retry-After header.
+func WriteError(w http.ResponseWriter, err error) {
+	status := StatusCode(err)
+	detail := ErrorDetail{Code: shareddiscovery.ErrorClass(err), Message: err.Error()}
+	if errors.Is(err, ErrMissingParameter) {
+		detail.Code = "missing_parameter"
+	}
+	if status == http.StatusInternalServerError {
+		detail.Message = http.StatusText(status)
+	}
+	var limited *shareddiscovery.RateLimitError
+	if errors.As(err, &limited) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(limited.RetryAfter.Seconds()))))
+	}
+	writeJSON(w, status, ErrorBody{detail})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func get(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			writeJSON(w, http.StatusMethodNotAllowed, ErrorBody{ErrorDetail{Code: "method_not_allowed", Message: r.Method + " is not allowed"}})
+			return
+		}
+		next(w, r)
+	}
+}
+
+func require(params map[string]string) error {
+	var missing []string
+	for name, value := range params {
+		if value == "" {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	sort.Strings(missing)
+	return &missingParameterError{missing}
+}
+
+type missingParameterError struct {
+	names []string
+}
+
+func (err *missingParameterError) Error() string {
+	return ErrMissingParameter.Error() + ": " + strings.Join(err.names, ", ")
+}
+
+func (err *missingParameterError) Unwrap() error {
+	return ErrMissingParameter
+}
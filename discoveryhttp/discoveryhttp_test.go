@@ -0,0 +1,300 @@
+package discoveryhttp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/pgdevelopers/shareddiscovery/v2"
+	"github.com/pgdevelopers/shareddiscovery/v2/discoverytest"
+)
+
+// fakeDiscovery records the last call and answers with its fields.
+type fakeDiscovery struct {
+	config    map[string]interface{}
+	valid     bool
+	token     string
+	err       error
+	apiToken  string
+	secretKey string
+	query     shareddiscovery.QueryInput
+}
+
+func (fake *fakeDiscovery) GetValidation(ctx context.Context, query shareddiscovery.QueryInput, opts ...shareddiscovery.CallOption) (bool, error) {
+	fake.query = query
+	return fake.valid, fake.err
+}
+
+func (fake *fakeDiscovery) GetConfig(ctx context.Context, apiToken string, query shareddiscovery.QueryInput, opts ...shareddiscovery.CallOption) (map[string]interface{}, error) {
+	fake.apiToken, fake.query = apiToken, query
+	return fake.config, fake.err
+}
+
+func (fake *fakeDiscovery) BatchGetConfig(ctx context.Context, keys []shareddiscovery.ConfigKey, opts ...shareddiscovery.CallOption) []shareddiscovery.ConfigResult {
+	return nil
+}
+
+func (fake *fakeDiscovery) AdminGetAPIToken(ctx context.Context, secretKey string, query shareddiscovery.QueryInput, opts ...shareddiscovery.CallOption) (string, error) {
+	fake.secretKey, fake.query = secretKey, query
+	return fake.token, fake.err
+}
+
+func secretKey(ctx context.Context, query shareddiscovery.QueryInput) (string, error) {
+	return "secretKey", nil
+}
+
+func serve(handler http.Handler, method, target string, header http.Header) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, nil)
+	for key := range header {
+		r.Header.Set(key, header.Get(key))
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+func decodeError(t *testing.T, w *httptest.ResponseRecorder) ErrorDetail {
+	t.Helper()
+	var body ErrorBody
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatalf("decoding error body: %v", err)
+	}
+	return body.Error
+}
+
+func TestConfig(t *testing.T) {
+	fake := &fakeDiscovery{config: map[string]interface{}{"name": "app"}}
+	handler := New(fake, nil)
+
+	w := serve(handler, http.MethodGet, "/config?workspace=apps&countryCode=US", http.Header{HeaderAPIToken: {"abc"}})
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /config == %d, want %d", w.Code, http.StatusOK)
+	}
+	if got := w.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("GET /config Content-Type == %q, want application/json", got)
+	}
+	var config map[string]interface{}
+	if err := json.NewDecoder(w.Body).Decode(&config); err != nil || config["name"] != "app" {
+		t.Errorf("GET /config body == %v (%v), want %v", config, err, fake.config)
+	}
+	if fake.apiToken != "abc" || fake.query.Workspace != "apps" || fake.query.Country != "US" {
+		t.Errorf("GetConfig(ctx, %q, %+v), want the apiToken header, workspace and country passed through", fake.apiToken, fake.query)
+	}
+}
+
+func TestConfig_CallOptions(t *testing.T) {
+	db := discoverytest.NewDynamoDB()
+	db.Seed(t, shareddiscovery.TableDefinition{HashKey: "apiToken"}.CreateTableInput("apps"),
+		map[string]interface{}{"apiToken": "abc", "name": "app", "urls": map[string]string{"api": "https://api", "cdn": "https://cdn"}})
+	discovery := shareddiscovery.New(db)
+	discovery.Cache = shareddiscovery.NewMemoryCache(0)
+	discovery.Cache.Set(shareddiscovery.ConfigKey{Workspace: "apps", APIToken: "abc"}, map[string]interface{}{"name": "stale"})
+	handler := New(discovery, nil)
+
+	w := serve(handler, http.MethodGet, "/config?workspace=apps&fields=name,urls.api&consistentRead=true", http.Header{HeaderAPIToken: {"abc"}})
+
+	want := `{"name":"app","urls":{"api":"https://api"}}` + "\n"
+	if w.Code != http.StatusOK || w.Body.String() != want {
+		t.Errorf("GET /config?fields=name,urls.api&consistentRead=true == %d %q, want %q", w.Code, w.Body.String(), want)
+	}
+
+	w = serve(handler, http.MethodGet, "/config?workspace=apps&fields=name", http.Header{HeaderAPIToken: {"abc"}, "Cache-Control": {"no-cache"}})
+
+	if want := `{"name":"app"}` + "\n"; w.Body.String() != want {
+		t.Errorf("GET /config?fields=name with Cache-Control: no-cache == %q, want %q", w.Body.String(), want)
+	}
+}
+
+func TestConfig_ServedCountry(t *testing.T) {
+	db := discoverytest.NewDynamoDB()
+	db.Seed(t, shareddiscovery.WorkspaceTable.CreateTableInput("apps"),
+		map[string]string{"apiToken": "abc", "countryCode": "DACH", "name": "app"})
+	discovery := shareddiscovery.New(db)
+	discovery.CountryFallbacks = map[string]shareddiscovery.CountryFallback{"apps": {Groups: map[string][]string{"DACH": {"AT"}}}}
+
+	w := serve(New(discovery, nil), http.MethodGet, "/config?workspace=apps&countryCode=AT", http.Header{HeaderAPIToken: {"abc"}})
+
+	if got := w.Header().Get(HeaderServedCountry); w.Code != http.StatusOK || got != "DACH" {
+		t.Errorf("GET /config?countryCode=AT == %d %s %q, want 200 served from DACH", w.Code, HeaderServedCountry, got)
+	}
+}
+
+func TestConfig_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		fake   *fakeDiscovery
+		status int
+		code   string
+	}{
+		{"missing token", "/config?workspace=apps", &fakeDiscovery{}, http.StatusBadRequest, "missing_parameter"},
+		{"not found", "/config?workspace=apps&apiToken=abc", &fakeDiscovery{}, http.StatusNotFound, "not_found"},
+		{"invalid country", "/config?workspace=apps&apiToken=abc&countryCode=XX", &fakeDiscovery{err: &shareddiscovery.ValidationError{Field: "countryCode", Value: "XX"}}, http.StatusBadRequest, "invalid_input"},
+		{"forbidden", "/config?workspace=apps&apiToken=abc", &fakeDiscovery{err: &shareddiscovery.AuthorizationError{KeyID: "support", Field: "brand", Value: "gillette"}}, http.StatusForbidden, "forbidden"},
+		{"circuit open", "/config?workspace=apps&apiToken=abc", &fakeDiscovery{err: shareddiscovery.ErrCircuitOpen}, http.StatusServiceUnavailable, "circuit_open"},
+		{"timeout", "/config?workspace=apps&apiToken=abc", &fakeDiscovery{err: context.DeadlineExceeded}, http.StatusGatewayTimeout, "timeout"},
+		{"rate limited", "/config?workspace=apps&apiToken=abc", &fakeDiscovery{err: &shareddiscovery.RateLimitError{Bucket: "apiToken:abc"}}, http.StatusTooManyRequests, "rate_limited"},
+		{
+			"throttled",
+			"/config?workspace=apps&apiToken=abc",
+			&fakeDiscovery{err: awserr.New(dynamodb.ErrCodeProvisionedThroughputExceededException, "slow down", nil)},
+			http.StatusTooManyRequests,
+			"aws." + dynamodb.ErrCodeProvisionedThroughputExceededException,
+		},
+		{"internal", "/config?workspace=apps&apiToken=abc", &fakeDiscovery{err: errors.New("table arn:aws:dynamodb:secret")}, http.StatusInternalServerError, "internal"},
+	}
+
+	for _, test := range tests {
+		w := serve(New(test.fake, nil), http.MethodGet, test.target, nil)
+		if w.Code != test.status {
+			t.Errorf("%s: GET %s == %d, want %d", test.name, test.target, w.Code, test.status)
+		}
+		detail := decodeError(t, w)
+		if detail.Code != test.code {
+			t.Errorf("%s: GET %s error code == %q, want %q", test.name, test.target, detail.Code, test.code)
+		}
+		if test.status == http.StatusInternalServerError && detail.Message != http.StatusText(test.status) {
+			t.Errorf("%s: GET %s error message == %q, want internal details hidden", test.name, test.target, detail.Message)
+		}
+	}
+}
+
+func TestConfig_RetryAfter(t *testing.T) {
+	fake := &fakeDiscovery{err: &shareddiscovery.RateLimitError{Bucket: "apiToken:abc", RetryAfter: 1500 * time.Millisecond}}
+	w := serve(New(fake, nil), http.MethodGet, "/config?workspace=apps&apiToken=abc", nil)
+	if retryAfter := w.Header().Get("Retry-After"); retryAfter != "2" {
+		t.Errorf("GET /config Retry-After == %q, want %q", retryAfter, "2")
+	}
+}
+
+// checkedDiscovery is a fakeDiscovery that is also a HealthChecker.
+type checkedDiscovery struct {
+	fakeDiscovery
+	report shareddiscovery.HealthReport
+}
+
+func (checked *checkedDiscovery) HealthCheck(ctx context.Context) (shareddiscovery.HealthReport, error) {
+	if !checked.report.Healthy {
+		return checked.report, shareddiscovery.ErrUnhealthy
+	}
+	return checked.report, nil
+}
+
+func TestHealth(t *testing.T) {
+	tests := []struct {
+		name      string
+		discovery shareddiscovery.IFace
+		status    int
+	}{
+		{"healthy", &checkedDiscovery{report: shareddiscovery.HealthReport{Healthy: true}}, http.StatusOK},
+		{"unhealthy", &checkedDiscovery{report: shareddiscovery.HealthReport{Checks: []shareddiscovery.HealthCheckResult{{Name: "table:apps"}}}}, http.StatusServiceUnavailable},
+		{"unsupported", &fakeDiscovery{}, http.StatusNotFound},
+		{"rate limited", shareddiscovery.NewRateLimiter(&checkedDiscovery{report: shareddiscovery.HealthReport{Healthy: true}}, shareddiscovery.RateLimits{}, nil), http.StatusOK},
+		{"rate limited unsupported", shareddiscovery.NewRateLimiter(&fakeDiscovery{}, shareddiscovery.RateLimits{}, nil), http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		w := serve(New(test.discovery, nil), http.MethodGet, "/health", nil)
+		if w.Code != test.status {
+			t.Errorf("%s: GET /health == %d, want %d", test.name, w.Code, test.status)
+		}
+		if checked, ok := test.discovery.(*checkedDiscovery); ok {
+			var report shareddiscovery.HealthReport
+			if err := json.NewDecoder(w.Body).Decode(&report); err != nil || report.Healthy != checked.report.Healthy || len(report.Checks) != len(checked.report.Checks) {
+				t.Errorf("%s: GET /health body == %+v, %v, want %+v", test.name, report, err, checked.report)
+			}
+		}
+	}
+}
+
+func TestValidation(t *testing.T) {
+	fake := &fakeDiscovery{valid: true}
+	w := serve(New(fake, nil), http.MethodGet, "/validation?appName=app&countryCode=US", nil)
+
+	var body map[string]bool
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil || w.Code != http.StatusOK || !body["valid"] {
+		t.Errorf("GET /validation == %d %v (%v), want 200 valid", w.Code, body, err)
+	}
+
+	w = serve(New(fake, nil), http.MethodGet, "/validation?appName=app", nil)
+	if detail := decodeError(t, w); w.Code != http.StatusBadRequest || detail.Message != "missing required parameter: countryCode" {
+		t.Errorf("GET /validation without countryCode == %d %q, want 400 naming countryCode", w.Code, detail.Message)
+	}
+}
+
+func TestAdminToken(t *testing.T) {
+	target := "/admin/token?workspace=discovery_app&appName=app&brand=oralb&countryCode=US&environment=qa"
+	tests := []struct {
+		name   string
+		target string
+		header http.Header
+	}{
+		{"header", target, http.Header{HeaderSignature: {"sig"}}},
+		{"parameter", target + "&signature=sig", nil},
+	}
+
+	for _, test := range tests {
+		fake := &fakeDiscovery{token: "abc"}
+		w := serve(New(fake, secretKey), http.MethodGet, test.target, test.header)
+
+		var body map[string]string
+		if err := json.NewDecoder(w.Body).Decode(&body); err != nil || w.Code != http.StatusOK || body[ParamAPIToken] != "abc" {
+			t.Errorf("%s: GET /admin/token == %d %v (%v), want 200 with apiToken", test.name, w.Code, body, err)
+		}
+		if fake.secretKey != "secretKey" || fake.query.Signature != "sig" {
+			t.Errorf("%s: AdminGetAPIToken(ctx, %q, %+v), want the secret key and signature", test.name, fake.secretKey, fake.query)
+		}
+		want := map[string]string{"appName": "app", "brand": "oralb", "countryCode": "US", "environment": "qa"}
+		if fmt.Sprint(fake.query.QueryString) != fmt.Sprint(want) {
+			t.Errorf("%s: QueryString == %v, want %v", test.name, fake.query.QueryString, want)
+		}
+	}
+}
+
+func TestAdminToken_InvalidSignature(t *testing.T) {
+	fake := &fakeDiscovery{err: shareddiscovery.ErrInvalidSignature}
+	w := serve(New(fake, secretKey), http.MethodGet, "/admin/token?workspace=discovery_app&signature=bad", nil)
+
+	if detail := decodeError(t, w); w.Code != http.StatusUnauthorized || detail.Code != "invalid_signature" {
+		t.Errorf("GET /admin/token with a bad signature == %d %q, want 401 invalid_signature", w.Code, detail.Code)
+	}
+}
+
+func TestAdminToken_Disabled(t *testing.T) {
+	w := serve(New(&fakeDiscovery{}, nil), http.MethodGet, "/admin/token?workspace=discovery_app&signature=sig", nil)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("GET /admin/token without SecretKey == %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	w := serve(New(&fakeDiscovery{}, nil), http.MethodPost, "/config", nil)
+
+	if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") == "" {
+		t.Errorf("POST /config == %d Allow %q, want 405 with Allow", w.Code, w.Header().Get("Allow"))
+	}
+}
+
+func TestParseQuery(t *testing.T) {
+	values := url.Values{"appName": {"app"}, "signature": {"param"}, "apiToken": {"abc"}, "workspace": {"apps"}, "custom": {"x"}}
+
+	query := ParseQuery(values, http.Header{HeaderSignature: {"header"}})
+
+	if query.Signature != "header" {
+		t.Errorf("ParseQuery Signature == %q, want the header to win", query.Signature)
+	}
+	want := map[string]string{"appName": "app", "custom": "x"}
+	if fmt.Sprint(query.QueryString) != fmt.Sprint(want) {
+		t.Errorf("ParseQuery QueryString == %v, want %v", query.QueryString, want)
+	}
+}
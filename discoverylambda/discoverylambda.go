@@ -0,0 +1,186 @@
+// Package discoverylambda serves the discovery library from AWS Lambda behind
+// API Gateway. Proxy events are turned into requests for a
+// discoveryhttp.Handler, so Lambda functions answer with the same endpoints,
+// error bodies and status codes as HTTP services:
+//
+//	adapter := discoverylambda.New(shareddiscovery.New(dynamodb.New(session)), secretKey)
+//	lambda.Start(adapter.HandleV1) // or adapter.HandleV2 for HTTP APIs
+package discoverylambda
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/honeycombio/beeline-go"
+	"github.com/honeycombio/beeline-go/propagation"
+	"github.com/honeycombio/beeline-go/trace"
+	"github.com/pgdevelopers/shareddiscovery/v2"
+	"github.com/pgdevelopers/shareddiscovery/v2/discoveryhttp"
+	otelpropagation "go.opentelemetry.io/otel/propagation"
+)
+
+// StartTraceFunc starts the span covering one invocation, continuing the
+// trace carried by the incoming headers when there is one.
+type StartTraceFunc func(ctx context.Context, name string, header http.Header) (context.Context, shareddiscovery.Span)
+
+// Adapter turns API Gateway proxy events into discovery calls.
+type Adapter struct {
+	Handler http.Handler
+
+	// BasePath is stripped from event paths before routing, for APIs that
+	// mount discovery below a prefix or stage.
+	BasePath string
+
+	// StartTrace defaults to BeelineTrace.
+	StartTrace StartTraceFunc
+}
+
+// New returns an Adapter serving a discoveryhttp.Handler for discovery.
+func New(discovery shareddiscovery.IFace, secretKey discoveryhttp.SecretKeyFunc) *Adapter {
+	return &Adapter{Handler: discoveryhttp.New(discovery, secretKey)}
+}
+
+// HandleV1 answers an API Gateway REST API (payload version 1.0) proxy event.
+func (adapter *Adapter) HandleV1(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	query := url.Values{}
+	for key, value := range event.QueryStringParameters {
+		query.Set(key, value)
+	}
+	for key, values := range event.MultiValueQueryStringParameters {
+		query[key] = values
+	}
+	header := http.Header{}
+	for key, value := range event.Headers {
+		header.Set(key, value)
+	}
+	for key, values := range event.MultiValueHeaders {
+		header.Del(key)
+		for _, value := range values {
+			header.Add(key, value)
+		}
+	}
+
+	w := adapter.serve(ctx, event.HTTPMethod, event.Path, query, header)
+	return events.APIGatewayProxyResponse{
+		StatusCode:        w.status,
+		Headers:           singleValued(w.header),
+		MultiValueHeaders: w.header,
+		Body:              w.body.String(),
+	}, nil
+}
+
+// HandleV2 answers an API Gateway HTTP API (payload version 2.0) event.
+func (adapter *Adapter) HandleV2(ctx context.Context, event events.APIGatewayV2HTTPRequest) (events.APIGatewayV2HTTPResponse, error) {
+	query, err := url.ParseQuery(event.RawQueryString)
+	if err != nil {
+		query = url.Values{}
+		for key, value := range event.QueryStringParameters {
+			query.Set(key, value)
+		}
+	}
+	header := http.Header{}
+	for key, value := range event.Headers {
+		header.Set(key, value)
+	}
+
+	w := adapter.serve(ctx, event.RequestContext.HTTP.Method, event.RawPath, query, header)
+	return events.APIGatewayV2HTTPResponse{
+		StatusCode: w.status,
+		Headers:    singleValued(w.header),
+		Body:       w.body.String(),
+	}, nil
+}
+
+func (adapter *Adapter) serve(ctx context.Context, method, path string, query url.Values, header http.Header) *responseWriter {
+	startTrace := adapter.StartTrace
+	if startTrace == nil {
+		startTrace = BeelineTrace
+	}
+	ctx, span := startTrace(ctx, "discoverylambda", header)
+	defer span.Send()
+
+	path = "/" + strings.TrimPrefix(strings.TrimPrefix(path, adapter.BasePath), "/")
+	span.AddField("request.method", method)
+	span.AddField("request.path", path)
+
+	w := &responseWriter{header: http.Header{}}
+	r := (&http.Request{
+		Method:     method,
+		URL:        &url.URL{Path: path, RawQuery: query.Encode()},
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     header,
+		Body:       http.NoBody,
+		RequestURI: path,
+	}).WithContext(ctx)
+	adapter.Handler.ServeHTTP(w, r)
+
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	span.AddField("response.status_code", w.status)
+	return w
+}
+
+// BeelineTrace continues a trace from an X-Honeycomb-Trace or W3C traceparent
+// header, and otherwise starts a new beeline trace.
+func BeelineTrace(ctx context.Context, name string, header http.Header) (context.Context, shareddiscovery.Span) {
+	prop, err := propagation.UnmarshalHoneycombTraceContext(header.Get(propagation.TracePropagationHTTPHeader))
+	if err != nil || prop == nil {
+		_, prop, err = propagation.UnmarshalW3CTraceContext(ctx, map[string]string{
+			"traceparent": header.Get("traceparent"),
+			"tracestate":  header.Get("tracestate"),
+		})
+	}
+	if err != nil || prop == nil {
+		return beeline.StartSpan(ctx, name)
+	}
+
+	ctx, tr := trace.NewTrace(ctx, prop)
+	span := tr.GetRootSpan()
+	span.AddField("name", name)
+	return ctx, span
+}
+
+// OTelTrace returns a StartTraceFunc that extracts the incoming trace context
+// with propagator before starting a span on tracer.
+func OTelTrace(tracer shareddiscovery.Tracer, propagator otelpropagation.TextMapPropagator) StartTraceFunc {
+	return func(ctx context.Context, name string, header http.Header) (context.Context, shareddiscovery.Span) {
+		ctx = propagator.Extract(ctx, otelpropagation.HeaderCarrier(header))
+		return tracer.StartSpan(ctx, name)
+	}
+}
+
+func singleValued(header http.Header) map[string]string {
+	values := make(map[string]string, len(header))
+	for key := range header {
+		values[key] = header.Get(key)
+	}
+	return values
+}
+
+// responseWriter buffers the response so it can be returned as an event.
+type responseWriter struct {
+	header http.Header
+	status int
+	body   strings.Builder
+}
+
+func (w *responseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(b)
+}
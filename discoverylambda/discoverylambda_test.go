@@ -0,0 +1,150 @@
+package discoverylambda
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/honeycombio/beeline-go/trace"
+	"github.com/pgdevelopers/shareddiscovery/v2"
+	"go.opentelemetry.io/otel/propagation"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// fakeDiscovery records the last call and answers with its fields.
+type fakeDiscovery struct {
+	config   map[string]interface{}
+	token    string
+	err      error
+	apiToken string
+	query    shareddiscovery.QueryInput
+	traceID  string
+}
+
+func (fake *fakeDiscovery) GetValidation(ctx context.Context, query shareddiscovery.QueryInput, opts ...shareddiscovery.CallOption) (bool, error) {
+	fake.query = query
+	return true, fake.err
+}
+
+func (fake *fakeDiscovery) GetConfig(ctx context.Context, apiToken string, query shareddiscovery.QueryInput, opts ...shareddiscovery.CallOption) (map[string]interface{}, error) {
+	fake.apiToken, fake.query = apiToken, query
+	if span := trace.GetSpanFromContext(ctx); span != nil {
+		fake.traceID = span.GetTrace().GetTraceID()
+	}
+	return fake.config, fake.err
+}
+
+func (fake *fakeDiscovery) BatchGetConfig(ctx context.Context, keys []shareddiscovery.ConfigKey, opts ...shareddiscovery.CallOption) []shareddiscovery.ConfigResult {
+	return nil
+}
+
+func (fake *fakeDiscovery) AdminGetAPIToken(ctx context.Context, secretKey string, query shareddiscovery.QueryInput, opts ...shareddiscovery.CallOption) (string, error) {
+	fake.query = query
+	fake.traceID = oteltrace.SpanContextFromContext(ctx).TraceID().String()
+	return fake.token, fake.err
+}
+
+// contextTracer starts spans that only carry the context they were started from.
+type contextTracer struct{}
+
+func (contextTracer) StartSpan(ctx context.Context, name string) (context.Context, shareddiscovery.Span) {
+	return ctx, nopSpan{}
+}
+
+type nopSpan struct{}
+
+func (nopSpan) AddField(key string, val interface{}) {}
+func (nopSpan) Send()                                {}
+
+func secretKey(ctx context.Context, query shareddiscovery.QueryInput) (string, error) {
+	return "secretKey", nil
+}
+
+func loadEvent(t *testing.T, name string, event interface{}) {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, event); err != nil {
+		t.Fatalf("decoding %s: %v", name, err)
+	}
+}
+
+func TestHandleV1_Config(t *testing.T) {
+	var event events.APIGatewayProxyRequest
+	loadEvent(t, "apigw-v1-config.json", &event)
+	fake := &fakeDiscovery{config: map[string]interface{}{"name": "app"}}
+	adapter := New(fake, nil)
+	adapter.BasePath = "/discovery"
+
+	response, err := adapter.HandleV1(context.TODO(), event)
+
+	if err != nil || response.StatusCode != http.StatusOK {
+		t.Fatalf("HandleV1(ctx, %s) == %d (%v), want %d", event.Path, response.StatusCode, err, http.StatusOK)
+	}
+	if response.Body != "{\"name\":\"app\"}\n" {
+		t.Errorf("HandleV1(ctx, %s) body == %q, want the config", event.Path, response.Body)
+	}
+	if response.Headers["Content-Type"] != "application/json" {
+		t.Errorf("HandleV1(ctx, %s) headers == %v, want a JSON content type", event.Path, response.Headers)
+	}
+	if fake.apiToken != "someApiToken" || fake.query.Workspace != "apps" || fake.query.Country != "US" {
+		t.Errorf("GetConfig(ctx, %q, %+v), want the event apiToken, workspace and country", fake.apiToken, fake.query)
+	}
+	if fake.traceID != "trace-1234" {
+		t.Errorf("GetConfig trace id == %q, want %q from X-Honeycomb-Trace", fake.traceID, "trace-1234")
+	}
+}
+
+func TestHandleV2_AdminToken(t *testing.T) {
+	var event events.APIGatewayV2HTTPRequest
+	loadEvent(t, "apigw-v2-admin-token.json", &event)
+	fake := &fakeDiscovery{token: "someApiToken"}
+	adapter := New(fake, secretKey)
+	adapter.BasePath = "/discovery"
+	adapter.StartTrace = OTelTrace(contextTracer{}, propagation.TraceContext{})
+
+	response, err := adapter.HandleV2(context.TODO(), event)
+
+	if err != nil || response.StatusCode != http.StatusOK || response.Body != "{\"apiToken\":\"someApiToken\"}\n" {
+		t.Fatalf("HandleV2(ctx, %s) == %d %q (%v), want 200 with the apiToken", event.RawPath, response.StatusCode, response.Body, err)
+	}
+	if fake.query.Signature != "someSignature" || fake.query.QueryString["brand"] != "oralb" {
+		t.Errorf("AdminGetAPIToken query == %+v, want the event signature and parameters", fake.query)
+	}
+	if fake.traceID != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("AdminGetAPIToken trace id == %q, want the traceparent trace id", fake.traceID)
+	}
+}
+
+func TestHandleV2_Validation_MissingParameter(t *testing.T) {
+	var event events.APIGatewayV2HTTPRequest
+	loadEvent(t, "apigw-v2-validation.json", &event)
+	adapter := New(&fakeDiscovery{}, nil)
+	adapter.BasePath = "/discovery"
+
+	response, err := adapter.HandleV2(context.TODO(), event)
+
+	if err != nil || response.StatusCode != http.StatusBadRequest {
+		t.Errorf("HandleV2(ctx, %s) == %d (%v), want %d", event.RawPath, response.StatusCode, err, http.StatusBadRequest)
+	}
+	if response.Body != "{\"error\":{\"code\":\"missing_parameter\",\"message\":\"missing required parameter: countryCode\"}}\n" {
+		t.Errorf("HandleV2(ctx, %s) body == %q, want a missing_parameter error", event.RawPath, response.Body)
+	}
+}
+
+func TestBeelineTrace_W3C(t *testing.T) {
+	header := http.Header{"Traceparent": {"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}}
+
+	_, span := BeelineTrace(context.TODO(), "discoverylambda", header)
+
+	root, ok := span.(*trace.Span)
+	if !ok || root.GetTrace().GetTraceID() != "4bf92f3577b34da6a3ce929d0e0e4736" || root.GetParentID() != "00f067aa0ba902b7" {
+		t.Errorf("BeelineTrace(ctx, %v) == %+v, want a span continuing the traceparent", header, span)
+	}
+}
@@ -0,0 +1,720 @@
+// Package discoverytest provides an in-memory DynamoDB for testing code
+// built on shareddiscovery. Unlike the gomock mocks it stores items and
+// evaluates the key conditions, filters, conditions and projections it is
+// sent, so tests can check behavior rather than the shape of requests.
+package discoverytest
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/awsutil"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// DynamoDB is an in-memory dynamodbiface.DynamoDBAPI. It supports
+// CreateTable, DescribeTable, GetItem, PutItem, UpdateItem, DeleteItem,
+// Query (including global and local secondary indexes), Scan and
+// BatchGetItem, with pagination. Calling any other method panics.
+//
+// Items are stored as copies, so callers may reuse the values they pass in.
+type DynamoDB struct {
+	dynamodbiface.DynamoDBAPI
+
+	mu     sync.Mutex
+	tables map[string]*table
+}
+
+type table struct {
+	description *dynamodb.TableDescription
+	key         keySchema
+	indexes     map[string]keySchema
+	items       map[string]item
+}
+
+// keySchema names the hash and, optionally, range key of a table or index.
+type keySchema struct {
+	hash, rng string
+}
+
+// NewDynamoDB returns a DynamoDB without any tables.
+func NewDynamoDB() *DynamoDB {
+	return &DynamoDB{tables: map[string]*table{}}
+}
+
+// CreateTable creates a table with the key schema and secondary indexes of input.
+func (db *DynamoDB) CreateTable(input *dynamodb.CreateTableInput) (*dynamodb.CreateTableOutput, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	name := aws.StringValue(input.TableName)
+	if _, ok := db.tables[name]; ok {
+		return nil, awserr.New(dynamodb.ErrCodeResourceInUseException, "Table already exists: "+name, nil)
+	}
+	t := &table{
+		key:     schemaOf(input.KeySchema),
+		indexes: map[string]keySchema{},
+		items:   map[string]item{},
+		description: &dynamodb.TableDescription{
+			TableName:            input.TableName,
+			TableArn:             aws.String("arn:aws:dynamodb:local:000000000000:table/" + name),
+			TableStatus:          aws.String(dynamodb.TableStatusActive),
+			KeySchema:            input.KeySchema,
+			AttributeDefinitions: input.AttributeDefinitions,
+			BillingModeSummary:   &dynamodb.BillingModeSummary{BillingMode: input.BillingMode},
+			StreamSpecification:  input.StreamSpecification,
+			ItemCount:            aws.Int64(0),
+		},
+	}
+	if t.key.hash == "" {
+		return nil, validationError("KeySchema must contain a HASH key")
+	}
+	for _, index := range input.GlobalSecondaryIndexes {
+		t.indexes[aws.StringValue(index.IndexName)] = schemaOf(index.KeySchema)
+		t.description.GlobalSecondaryIndexes = append(t.description.GlobalSecondaryIndexes, &dynamodb.GlobalSecondaryIndexDescription{
+			IndexName:   index.IndexName,
+			IndexStatus: aws.String(dynamodb.IndexStatusActive),
+			KeySchema:   index.KeySchema,
+			Projection:  index.Projection,
+		})
+	}
+	for _, index := range input.LocalSecondaryIndexes {
+		t.indexes[aws.StringValue(index.IndexName)] = schemaOf(index.KeySchema)
+		t.description.LocalSecondaryIndexes = append(t.description.LocalSecondaryIndexes, &dynamodb.LocalSecondaryIndexDescription{
+			IndexName:  index.IndexName,
+			KeySchema:  index.KeySchema,
+			Projection: index.Projection,
+		})
+	}
+	db.tables[name] = t
+	return &dynamodb.CreateTableOutput{TableDescription: t.describe()}, nil
+}
+
+// CreateTableWithContext is CreateTable.
+func (db *DynamoDB) CreateTableWithContext(_ aws.Context, input *dynamodb.CreateTableInput, _ ...request.Option) (*dynamodb.CreateTableOutput, error) {
+	return db.CreateTable(input)
+}
+
+// DescribeTable describes a table created with CreateTable.
+func (db *DynamoDB) DescribeTable(input *dynamodb.DescribeTableInput) (*dynamodb.DescribeTableOutput, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	t, err := db.table(input.TableName)
+	if err != nil {
+		return nil, err
+	}
+	return &dynamodb.DescribeTableOutput{Table: t.describe()}, nil
+}
+
+// DescribeTableWithContext is DescribeTable.
+func (db *DynamoDB) DescribeTableWithContext(_ aws.Context, input *dynamodb.DescribeTableInput, _ ...request.Option) (*dynamodb.DescribeTableOutput, error) {
+	return db.DescribeTable(input)
+}
+
+// GetItem returns the item with the given key.
+func (db *DynamoDB) GetItem(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	t, err := db.table(input.TableName)
+	if err != nil {
+		return nil, err
+	}
+	id, err := t.keyOf(input.Key, true)
+	if err != nil {
+		return nil, err
+	}
+	project, err := projection(input.ProjectionExpression, input.ExpressionAttributeNames, input.AttributesToGet)
+	if err != nil {
+		return nil, err
+	}
+	output := &dynamodb.GetItemOutput{}
+	if stored, ok := t.items[id]; ok {
+		output.Item = project(stored)
+	}
+	return output, nil
+}
+
+// GetItemWithContext is GetItem.
+func (db *DynamoDB) GetItemWithContext(_ aws.Context, input *dynamodb.GetItemInput, _ ...request.Option) (*dynamodb.GetItemOutput, error) {
+	return db.GetItem(input)
+}
+
+// PutItem stores an item, replacing any item with the same key, when its
+// ConditionExpression holds.
+func (db *DynamoDB) PutItem(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	t, err := db.table(input.TableName)
+	if err != nil {
+		return nil, err
+	}
+	id, err := t.keyOf(input.Item, false)
+	if err != nil {
+		return nil, err
+	}
+	old, existed := t.items[id]
+	if err := check(input.ConditionExpression, input.ExpressionAttributeNames, input.ExpressionAttributeValues, old); err != nil {
+		return nil, err
+	}
+	t.items[id] = copyItem(input.Item)
+
+	output := &dynamodb.PutItemOutput{}
+	if existed && aws.StringValue(input.ReturnValues) == dynamodb.ReturnValueAllOld {
+		output.Attributes = copyItem(old)
+	}
+	return output, nil
+}
+
+// PutItemWithContext is PutItem.
+func (db *DynamoDB) PutItemWithContext(_ aws.Context, input *dynamodb.PutItemInput, _ ...request.Option) (*dynamodb.PutItemOutput, error) {
+	return db.PutItem(input)
+}
+
+// UpdateItem applies the SET and REMOVE clauses of UpdateExpression to the
+// item with the given key, creating it when missing, when its
+// ConditionExpression holds.
+func (db *DynamoDB) UpdateItem(input *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	t, err := db.table(input.TableName)
+	if err != nil {
+		return nil, err
+	}
+	id, err := t.keyOf(input.Key, true)
+	if err != nil {
+		return nil, err
+	}
+	if input.UpdateExpression == nil {
+		return nil, validationError("UpdateExpression is required")
+	}
+	assignments, err := parseUpdate(*input.UpdateExpression, input.ExpressionAttributeNames, input.ExpressionAttributeValues)
+	if err != nil {
+		return nil, validationError("Invalid UpdateExpression: " + err.Error())
+	}
+	old, existed := t.items[id]
+	if err := check(input.ConditionExpression, input.ExpressionAttributeNames, input.ExpressionAttributeValues, old); err != nil {
+		return nil, err
+	}
+
+	updated := copyItem(input.Key)
+	if existed {
+		updated = copyItem(old)
+	}
+	for _, a := range assignments {
+		if _, ok := input.Key[a.path[0]]; ok {
+			return nil, validationError("Cannot update attribute " + a.path[0] + ". This attribute is part of the key")
+		}
+	}
+	if err := apply(updated, old, assignments); err != nil {
+		return nil, validationError("Invalid UpdateExpression: " + err.Error())
+	}
+	t.items[id] = copyItem(updated)
+
+	output := &dynamodb.UpdateItemOutput{}
+	switch aws.StringValue(input.ReturnValues) {
+	case dynamodb.ReturnValueAllOld:
+		if existed {
+			output.Attributes = copyItem(old)
+		}
+	case dynamodb.ReturnValueAllNew:
+		output.Attributes = copyItem(updated)
+	}
+	return output, nil
+}
+
+// UpdateItemWithContext is UpdateItem.
+func (db *DynamoDB) UpdateItemWithContext(_ aws.Context, input *dynamodb.UpdateItemInput, _ ...request.Option) (*dynamodb.UpdateItemOutput, error) {
+	return db.UpdateItem(input)
+}
+
+// DeleteItem removes the item with the given key when its ConditionExpression holds.
+func (db *DynamoDB) DeleteItem(input *dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	t, err := db.table(input.TableName)
+	if err != nil {
+		return nil, err
+	}
+	id, err := t.keyOf(input.Key, true)
+	if err != nil {
+		return nil, err
+	}
+	old, existed := t.items[id]
+	if err := check(input.ConditionExpression, input.ExpressionAttributeNames, input.ExpressionAttributeValues, old); err != nil {
+		return nil, err
+	}
+	delete(t.items, id)
+
+	output := &dynamodb.DeleteItemOutput{}
+	if existed && aws.StringValue(input.ReturnValues) == dynamodb.ReturnValueAllOld {
+		output.Attributes = copyItem(old)
+	}
+	return output, nil
+}
+
+// DeleteItemWithContext is DeleteItem.
+func (db *DynamoDB) DeleteItemWithContext(_ aws.Context, input *dynamodb.DeleteItemInput, _ ...request.Option) (*dynamodb.DeleteItemOutput, error) {
+	return db.DeleteItem(input)
+}
+
+// Query returns the items of a table or index matching KeyConditionExpression
+// or KeyConditions, sorted by range key, then applies FilterExpression.
+// Limit and ExclusiveStartKey page through the results as DynamoDB does.
+func (db *DynamoDB) Query(input *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	t, err := db.table(input.TableName)
+	if err != nil {
+		return nil, err
+	}
+	schema, err := t.schema(input.IndexName)
+	if err != nil {
+		return nil, err
+	}
+
+	var keyCondition condition
+	switch {
+	case input.KeyConditionExpression != nil:
+		keyCondition, err = parseCondition(*input.KeyConditionExpression, input.ExpressionAttributeNames, input.ExpressionAttributeValues)
+	case input.KeyConditions != nil:
+		keyCondition, err = legacyConditions(input.KeyConditions)
+	default:
+		err = fmt.Errorf("either KeyConditions or KeyConditionExpression must be set")
+	}
+	if err != nil {
+		return nil, validationError(err.Error())
+	}
+	filter, err := filterOf(input.FilterExpression, input.ExpressionAttributeNames, input.ExpressionAttributeValues, input.QueryFilter)
+	if err != nil {
+		return nil, err
+	}
+	project, err := projection(input.ProjectionExpression, input.ExpressionAttributeNames, input.AttributesToGet)
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []item
+	for _, stored := range t.sorted(schema, aws.BoolValue(input.ScanIndexForward) || input.ScanIndexForward == nil) {
+		if keyCondition(stored) {
+			matched = append(matched, stored)
+		}
+	}
+	page := t.page(schema, matched, input.ExclusiveStartKey, input.Limit)
+
+	output := &dynamodb.QueryOutput{
+		Count:            aws.Int64(0),
+		ScannedCount:     aws.Int64(int64(len(page.items))),
+		LastEvaluatedKey: page.lastEvaluatedKey,
+	}
+	for _, stored := range page.items {
+		if filter(stored) {
+			output.Items = append(output.Items, project(stored))
+		}
+	}
+	output.Count = aws.Int64(int64(len(output.Items)))
+	if aws.StringValue(input.Select) == dynamodb.SelectCount {
+		output.Items = nil
+	}
+	return output, nil
+}
+
+// QueryWithContext is Query.
+func (db *DynamoDB) QueryWithContext(_ aws.Context, input *dynamodb.QueryInput, _ ...request.Option) (*dynamodb.QueryOutput, error) {
+	return db.Query(input)
+}
+
+// QueryPages calls fn with every page of a Query until fn returns false.
+func (db *DynamoDB) QueryPages(input *dynamodb.QueryInput, fn func(*dynamodb.QueryOutput, bool) bool) error {
+	input = awsutil.CopyOf(input).(*dynamodb.QueryInput)
+	for {
+		output, err := db.Query(input)
+		if err != nil {
+			return err
+		}
+		last := len(output.LastEvaluatedKey) == 0
+		if !fn(output, last) || last {
+			return nil
+		}
+		input.ExclusiveStartKey = output.LastEvaluatedKey
+	}
+}
+
+// QueryPagesWithContext is QueryPages.
+func (db *DynamoDB) QueryPagesWithContext(_ aws.Context, input *dynamodb.QueryInput, fn func(*dynamodb.QueryOutput, bool) bool, _ ...request.Option) error {
+	return db.QueryPages(input, fn)
+}
+
+// Scan returns the items of a table or index matching FilterExpression.
+// Limit and ExclusiveStartKey page through the items as DynamoDB does.
+func (db *DynamoDB) Scan(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	t, err := db.table(input.TableName)
+	if err != nil {
+		return nil, err
+	}
+	schema, err := t.schema(input.IndexName)
+	if err != nil {
+		return nil, err
+	}
+	filter, err := filterOf(input.FilterExpression, input.ExpressionAttributeNames, input.ExpressionAttributeValues, input.ScanFilter)
+	if err != nil {
+		return nil, err
+	}
+	project, err := projection(input.ProjectionExpression, input.ExpressionAttributeNames, input.AttributesToGet)
+	if err != nil {
+		return nil, err
+	}
+
+	page := t.page(schema, t.sorted(schema, true), input.ExclusiveStartKey, input.Limit)
+	output := &dynamodb.ScanOutput{
+		ScannedCount:     aws.Int64(int64(len(page.items))),
+		LastEvaluatedKey: page.lastEvaluatedKey,
+	}
+	for _, stored := range page.items {
+		if filter(stored) {
+			output.Items = append(output.Items, project(stored))
+		}
+	}
+	output.Count = aws.Int64(int64(len(output.Items)))
+	if aws.StringValue(input.Select) == dynamodb.SelectCount {
+		output.Items = nil
+	}
+	return output, nil
+}
+
+// ScanWithContext is Scan.
+func (db *DynamoDB) ScanWithContext(_ aws.Context, input *dynamodb.ScanInput, _ ...request.Option) (*dynamodb.ScanOutput, error) {
+	return db.Scan(input)
+}
+
+// ScanPages calls fn with every page of a Scan until fn returns false.
+func (db *DynamoDB) ScanPages(input *dynamodb.ScanInput, fn func(*dynamodb.ScanOutput, bool) bool) error {
+	input = awsutil.CopyOf(input).(*dynamodb.ScanInput)
+	for {
+		output, err := db.Scan(input)
+		if err != nil {
+			return err
+		}
+		last := len(output.LastEvaluatedKey) == 0
+		if !fn(output, last) || last {
+			return nil
+		}
+		input.ExclusiveStartKey = output.LastEvaluatedKey
+	}
+}
+
+// ScanPagesWithContext is ScanPages.
+func (db *DynamoDB) ScanPagesWithContext(_ aws.Context, input *dynamodb.ScanInput, fn func(*dynamodb.ScanOutput, bool) bool, _ ...request.Option) error {
+	return db.ScanPages(input, fn)
+}
+
+// BatchGetItem returns the items with the requested keys. It never leaves
+// keys unprocessed.
+func (db *DynamoDB) BatchGetItem(input *dynamodb.BatchGetItemInput) (*dynamodb.BatchGetItemOutput, error) {
+	keys := 0
+	for _, request := range input.RequestItems {
+		keys += len(request.Keys)
+	}
+	if keys > 100 {
+		return nil, validationError("Too many items requested for the BatchGetItem call")
+	}
+
+	output := &dynamodb.BatchGetItemOutput{Responses: map[string][]map[string]*dynamodb.AttributeValue{}}
+	for name, request := range input.RequestItems {
+		for _, key := range request.Keys {
+			result, err := db.GetItem(&dynamodb.GetItemInput{
+				TableName:                aws.String(name),
+				Key:                      key,
+				ProjectionExpression:     request.ProjectionExpression,
+				ExpressionAttributeNames: request.ExpressionAttributeNames,
+				AttributesToGet:          request.AttributesToGet,
+			})
+			if err != nil {
+				return nil, err
+			}
+			if result.Item != nil {
+				output.Responses[name] = append(output.Responses[name], result.Item)
+			}
+		}
+	}
+	return output, nil
+}
+
+// BatchGetItemWithContext is BatchGetItem.
+func (db *DynamoDB) BatchGetItemWithContext(_ aws.Context, input *dynamodb.BatchGetItemInput, _ ...request.Option) (*dynamodb.BatchGetItemOutput, error) {
+	return db.BatchGetItem(input)
+}
+
+func (db *DynamoDB) table(name *string) (*table, error) {
+	t, ok := db.tables[aws.StringValue(name)]
+	if !ok {
+		return nil, awserr.New(dynamodb.ErrCodeResourceNotFoundException, "Requested resource not found: Table: "+aws.StringValue(name)+" not found", nil)
+	}
+	return t, nil
+}
+
+func (t *table) describe() *dynamodb.TableDescription {
+	description := awsutil.CopyOf(t.description).(*dynamodb.TableDescription)
+	description.ItemCount = aws.Int64(int64(len(t.items)))
+	return description
+}
+
+func (t *table) schema(indexName *string) (keySchema, error) {
+	if indexName == nil {
+		return t.key, nil
+	}
+	schema, ok := t.indexes[*indexName]
+	if !ok {
+		return keySchema{}, validationError("The table does not have the specified index: " + *indexName)
+	}
+	return schema, nil
+}
+
+// keyOf returns the identity of the item with key. When exact is set key
+// must hold nothing but the table's key attributes.
+func (t *table) keyOf(key item, exact bool) (string, error) {
+	hash, rng := key[t.key.hash], key[t.key.rng]
+	switch {
+	case hash == nil:
+		return "", validationError("The provided key element does not match the schema: missing " + t.key.hash)
+	case t.key.rng != "" && rng == nil:
+		return "", validationError("The provided key element does not match the schema: missing " + t.key.rng)
+	case exact && len(key) != len(t.key.names()):
+		return "", validationError("The provided key element does not match the schema")
+	}
+	return fmt.Sprintf("%v|%v", canonical(hash), canonical(rng)), nil
+}
+
+func (schema keySchema) names() []string {
+	if schema.rng == "" {
+		return []string{schema.hash}
+	}
+	return []string{schema.hash, schema.rng}
+}
+
+// sorted returns the items that have the key attributes of schema, ordered
+// by hash key and then range key.
+func (t *table) sorted(schema keySchema, forward bool) []item {
+	var items []item
+	for _, stored := range t.items {
+		if stored[schema.hash] != nil && (schema.rng == "" || stored[schema.rng] != nil) {
+			items = append(items, stored)
+		}
+	}
+	less := func(a, b item) bool {
+		for _, name := range append(schema.names(), t.key.names()...) {
+			if cmp, _ := compare(a[name], b[name]); cmp != 0 {
+				return cmp < 0
+			}
+		}
+		return false
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		if forward {
+			return less(items[i], items[j])
+		}
+		return less(items[j], items[i])
+	})
+	return items
+}
+
+type page struct {
+	items            []item
+	lastEvaluatedKey item
+}
+
+// page returns up to limit items following exclusiveStartKey, and the key
+// to resume from when items remain.
+func (t *table) page(schema keySchema, items []item, exclusiveStartKey item, limit *int64) page {
+	if exclusiveStartKey != nil {
+		start, _ := t.keyOf(exclusiveStartKey, false)
+		for i, stored := range items {
+			if id, _ := t.keyOf(stored, false); id == start {
+				items = items[i+1:]
+				break
+			}
+		}
+	}
+	if limit == nil || int64(len(items)) <= *limit {
+		return page{items: items}
+	}
+	items = items[:*limit]
+	last := items[len(items)-1]
+	key := item{}
+	for _, name := range append(t.key.names(), schema.names()...) {
+		key[name] = last[name]
+	}
+	return page{items: items, lastEvaluatedKey: copyItem(key)}
+}
+
+func schemaOf(elements []*dynamodb.KeySchemaElement) keySchema {
+	var schema keySchema
+	for _, element := range elements {
+		switch aws.StringValue(element.KeyType) {
+		case dynamodb.KeyTypeHash:
+			schema.hash = aws.StringValue(element.AttributeName)
+		case dynamodb.KeyTypeRange:
+			schema.rng = aws.StringValue(element.AttributeName)
+		}
+	}
+	return schema
+}
+
+func check(expression *string, names map[string]*string, values map[string]*dynamodb.AttributeValue, stored item) error {
+	if expression == nil {
+		return nil
+	}
+	cond, err := parseCondition(*expression, names, values)
+	if err != nil {
+		return validationError("Invalid ConditionExpression: " + err.Error())
+	}
+	if stored == nil {
+		stored = item{}
+	}
+	if !cond(stored) {
+		return awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "The conditional request failed", nil)
+	}
+	return nil
+}
+
+func filterOf(expression *string, names map[string]*string, values map[string]*dynamodb.AttributeValue, legacy map[string]*dynamodb.Condition) (condition, error) {
+	switch {
+	case expression != nil:
+		cond, err := parseCondition(*expression, names, values)
+		if err != nil {
+			return nil, validationError("Invalid FilterExpression: " + err.Error())
+		}
+		return cond, nil
+	case legacy != nil:
+		cond, err := legacyConditions(legacy)
+		if err != nil {
+			return nil, validationError(err.Error())
+		}
+		return cond, nil
+	default:
+		return func(item) bool { return true }, nil
+	}
+}
+
+// projection returns a function copying the projected attributes of an
+// item. Nested paths project only the value they name, within the maps and
+// lists that hold it.
+func projection(expression *string, names map[string]*string, attributesToGet []*string) (func(item) item, error) {
+	var paths [][]string
+	switch {
+	case expression != nil:
+		var err error
+		paths, err = parsePaths(*expression, names)
+		if err != nil {
+			return nil, validationError("Invalid ProjectionExpression: " + err.Error())
+		}
+	case attributesToGet != nil:
+		for _, name := range attributesToGet {
+			paths = append(paths, []string{aws.StringValue(name)})
+		}
+	default:
+		return copyItem, nil
+	}
+	return func(stored item) item {
+		projected := item{}
+		for _, path := range paths {
+			if value := projectPath(projected[path[0]], stored[path[0]], path[1:]); value != nil {
+				projected[path[0]] = value
+			}
+		}
+		projected = copyItem(projected)
+		for _, value := range projected {
+			compactLists(value)
+		}
+		return projected
+	}, nil
+}
+
+// projectPath adds the value at path below stored to into, keeping the maps
+// and lists around it like DynamoDB does. Projected list elements are put
+// at their index, leaving nil slots that compactLists drops.
+func projectPath(into, stored *dynamodb.AttributeValue, path []string) *dynamodb.AttributeValue {
+	switch {
+	case stored == nil || into == stored:
+		// missing, or the whole attribute is projected already
+		return into
+	case len(path) == 0:
+		return stored
+	}
+	if strings.HasPrefix(path[0], "[") {
+		index, _ := strconv.Atoi(strings.Trim(path[0], "[]"))
+		if index >= len(stored.L) {
+			return into
+		}
+		if into == nil {
+			into = &dynamodb.AttributeValue{L: make([]*dynamodb.AttributeValue, len(stored.L))}
+		}
+		if len(into.L) == len(stored.L) {
+			if element := projectPath(into.L[index], stored.L[index], path[1:]); element != nil {
+				into.L[index] = element
+			}
+		}
+		return into
+	}
+	child, ok := stored.M[path[0]]
+	if !ok {
+		return into
+	}
+	if into == nil {
+		into = &dynamodb.AttributeValue{M: map[string]*dynamodb.AttributeValue{}}
+	}
+	if into.M != nil {
+		if value := projectPath(into.M[path[0]], child, path[1:]); value != nil {
+			into.M[path[0]] = value
+		}
+	}
+	return into
+}
+
+// compactLists drops the list elements below value that were not
+// projected, keeping the others in index order.
+func compactLists(value *dynamodb.AttributeValue) {
+	if value.L != nil {
+		elements := value.L[:0]
+		for _, element := range value.L {
+			if element != nil {
+				compactLists(element)
+				elements = append(elements, element)
+			}
+		}
+		value.L = elements
+	}
+	for _, child := range value.M {
+		compactLists(child)
+	}
+}
+
+func copyItem(i item) item {
+	if i == nil {
+		return nil
+	}
+	c := make(item, len(i))
+	for name, value := range i {
+		c[name] = awsutil.CopyOf(value).(*dynamodb.AttributeValue)
+	}
+	return c
+}
+
+func validationError(message string) error {
+	return awserr.New("ValidationException", strings.TrimSpace(message), nil)
+}
@@ -0,0 +1,224 @@
+package discoverytest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// newAppsTable returns a DynamoDB with an apps table keyed by apiToken and
+// countryCode, and an appNameCountryIndex like discovery_app has.
+func newAppsTable(t *testing.T) *DynamoDB {
+	t.Helper()
+	db := NewDynamoDB()
+	var rows []interface{}
+	for i, country := range []string{"US", "CA", "MX", "GB", "DE"} {
+		rows = append(rows, item{
+			"apiToken":    {S: aws.String(fmt.Sprintf("token-%d", i))},
+			"countryCode": {S: aws.String(country)},
+			"appName":     {S: aws.String("sonos")},
+			"environment": {S: aws.String([]string{"qa", "prod"}[i%2])},
+		})
+	}
+	db.Seed(t, &dynamodb.CreateTableInput{
+		TableName: aws.String("apps"),
+		KeySchema: []*dynamodb.KeySchemaElement{
+			{AttributeName: aws.String("apiToken"), KeyType: aws.String(dynamodb.KeyTypeHash)},
+			{AttributeName: aws.String("countryCode"), KeyType: aws.String(dynamodb.KeyTypeRange)},
+		},
+		GlobalSecondaryIndexes: []*dynamodb.GlobalSecondaryIndex{{
+			IndexName: aws.String("appNameCountryIndex"),
+			KeySchema: []*dynamodb.KeySchemaElement{
+				{AttributeName: aws.String("appName"), KeyType: aws.String(dynamodb.KeyTypeHash)},
+				{AttributeName: aws.String("countryCode"), KeyType: aws.String(dynamodb.KeyTypeRange)},
+			},
+		}},
+	}, rows...)
+	return db
+}
+
+func TestDynamoDB_GetPutDelete(t *testing.T) {
+	db := newAppsTable(t)
+	key := item{"apiToken": {S: aws.String("token-0")}, "countryCode": {S: aws.String("US")}}
+
+	got, err := db.GetItem(&dynamodb.GetItemInput{TableName: aws.String("apps"), Key: key, ProjectionExpression: aws.String("appName")})
+	if err != nil || len(got.Item) != 1 || aws.StringValue(got.Item["appName"].S) != "sonos" {
+		t.Errorf("GetItem(token-0, US) == %v, %v, want only appName", got.Item, err)
+	}
+
+	_, err = db.PutItem(&dynamodb.PutItemInput{
+		TableName:           aws.String("apps"),
+		Item:                key,
+		ConditionExpression: aws.String("attribute_not_exists(apiToken)"),
+	})
+	if aerr, ok := err.(awserr.Error); !ok || aerr.Code() != dynamodb.ErrCodeConditionalCheckFailedException {
+		t.Errorf("PutItem(existing, attribute_not_exists) == %v, want %s", err, dynamodb.ErrCodeConditionalCheckFailedException)
+	}
+
+	if _, err := db.DeleteItem(&dynamodb.DeleteItemInput{TableName: aws.String("apps"), Key: key}); err != nil {
+		t.Errorf("DeleteItem(token-0, US) == %v, want nil", err)
+	}
+	if got, _ := db.GetItem(&dynamodb.GetItemInput{TableName: aws.String("apps"), Key: key}); got.Item != nil {
+		t.Errorf("GetItem(deleted) == %v, want no item", got.Item)
+	}
+
+	if _, err := db.GetItem(&dynamodb.GetItemInput{TableName: aws.String("apps"), Key: item{"apiToken": {S: aws.String("token-0")}}}); err == nil {
+		t.Errorf("GetItem(partial key) == nil, want a ValidationException")
+	}
+	if _, err := db.GetItem(&dynamodb.GetItemInput{TableName: aws.String("missing"), Key: key}); err == nil {
+		t.Errorf("GetItem(missing table) == nil, want a ResourceNotFoundException")
+	}
+}
+
+func TestDynamoDB_Query(t *testing.T) {
+	db := newAppsTable(t)
+
+	output, err := db.Query(&dynamodb.QueryInput{
+		TableName: aws.String("apps"),
+		IndexName: aws.String("appNameCountryIndex"),
+		KeyConditions: map[string]*dynamodb.Condition{
+			"appName":     {ComparisonOperator: aws.String("EQ"), AttributeValueList: []*dynamodb.AttributeValue{{S: aws.String("sonos")}}},
+			"countryCode": {ComparisonOperator: aws.String("EQ"), AttributeValueList: []*dynamodb.AttributeValue{{S: aws.String("CA")}}},
+		},
+		FilterExpression:          aws.String("environment = :e"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":e": {S: aws.String("prod")}},
+	})
+	if err != nil || len(output.Items) != 1 || aws.StringValue(output.Items[0]["apiToken"].S) != "token-1" {
+		t.Errorf("Query(appNameCountryIndex, sonos, CA, prod) == %v, %v, want token-1", output, err)
+	}
+
+	output, err = db.Query(&dynamodb.QueryInput{
+		TableName:                 aws.String("apps"),
+		IndexName:                 aws.String("appNameCountryIndex"),
+		KeyConditionExpression:    aws.String("appName = :a"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":a": {S: aws.String("sonos")}},
+		ScanIndexForward:          aws.Bool(false),
+	})
+	if err != nil || len(output.Items) != 5 || aws.StringValue(output.Items[0]["countryCode"].S) != "US" {
+		t.Errorf("Query(appNameCountryIndex, sonos, descending) == %v, %v, want 5 items starting with US", output, err)
+	}
+
+	if _, err := db.Query(&dynamodb.QueryInput{TableName: aws.String("apps"), IndexName: aws.String("missing")}); err == nil {
+		t.Errorf("Query(missing index) == nil, want a ValidationException")
+	}
+}
+
+func TestDynamoDB_ScanPages(t *testing.T) {
+	db := newAppsTable(t)
+	var pages, items int
+
+	err := db.ScanPages(&dynamodb.ScanInput{
+		TableName:                 aws.String("apps"),
+		Limit:                     aws.Int64(2),
+		FilterExpression:          aws.String("environment = :e"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":e": {S: aws.String("qa")}},
+	}, func(page *dynamodb.ScanOutput, last bool) bool {
+		pages++
+		items += len(page.Items)
+		return true
+	})
+
+	if err != nil || pages != 3 || items != 3 {
+		t.Errorf("ScanPages(Limit 2, qa) == %d pages, %d items, %v, want 3 pages, 3 items", pages, items, err)
+	}
+}
+
+func TestDynamoDB_BatchGetItem(t *testing.T) {
+	db := newAppsTable(t)
+
+	output, err := db.BatchGetItem(&dynamodb.BatchGetItemInput{RequestItems: map[string]*dynamodb.KeysAndAttributes{
+		"apps": {Keys: []map[string]*dynamodb.AttributeValue{
+			{"apiToken": {S: aws.String("token-0")}, "countryCode": {S: aws.String("US")}},
+			{"apiToken": {S: aws.String("token-0")}, "countryCode": {S: aws.String("CA")}},
+			{"apiToken": {S: aws.String("token-2")}, "countryCode": {S: aws.String("MX")}},
+		}},
+	}})
+
+	if err != nil || len(output.Responses["apps"]) != 2 || len(output.UnprocessedKeys) != 0 {
+		t.Errorf("BatchGetItem(3 keys, 2 stored) == %v, %v, want 2 items", output, err)
+	}
+}
+
+func TestDynamoDB_NestedProjection(t *testing.T) {
+	db := newAppsTable(t)
+	key := item{"apiToken": {S: aws.String("token-0")}, "countryCode": {S: aws.String("US")}}
+	_, err := db.PutItem(&dynamodb.PutItemInput{TableName: aws.String("apps"), Item: item{
+		"apiToken":    key["apiToken"],
+		"countryCode": key["countryCode"],
+		"urls": {M: map[string]*dynamodb.AttributeValue{
+			"api": {S: aws.String("https://api")},
+			"cdn": {S: aws.String("https://cdn")},
+		}},
+		"ports": {L: []*dynamodb.AttributeValue{{N: aws.String("80")}, {N: aws.String("443")}, {N: aws.String("8080")}}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := db.GetItem(&dynamodb.GetItemInput{
+		TableName:                aws.String("apps"),
+		Key:                      key,
+		ProjectionExpression:     aws.String("#u.api, ports[2], ports[0], missing.field"),
+		ExpressionAttributeNames: map[string]*string{"#u": aws.String("urls")},
+	})
+
+	want := item{
+		"urls":  {M: map[string]*dynamodb.AttributeValue{"api": {S: aws.String("https://api")}}},
+		"ports": {L: []*dynamodb.AttributeValue{{N: aws.String("80")}, {N: aws.String("8080")}}},
+	}
+	if err != nil || fmt.Sprint(got.Item) != fmt.Sprint(want) {
+		t.Errorf("GetItem(#u.api, ports[2], ports[0]) == %v, %v, want %v", got.Item, err, want)
+	}
+}
+
+func TestDynamoDB_UpdateItem(t *testing.T) {
+	db := newAppsTable(t)
+	key := item{"apiToken": {S: aws.String("token-0")}, "countryCode": {S: aws.String("US")}}
+	if _, err := db.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName:                 aws.String("apps"),
+		Key:                       key,
+		UpdateExpression:          aws.String("SET urls = :urls"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":urls": {M: map[string]*dynamodb.AttributeValue{"api": {S: aws.String("https://qa")}}}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := db.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName:                 aws.String("apps"),
+		Key:                       key,
+		UpdateExpression:          aws.String("SET #u.api = :api, copy = environment REMOVE appName"),
+		ConditionExpression:       aws.String("#u.api = :old"),
+		ExpressionAttributeNames:  map[string]*string{"#u": aws.String("urls")},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":api": {S: aws.String("https://prod")}, ":old": {S: aws.String("https://qa")}},
+		ReturnValues:              aws.String(dynamodb.ReturnValueAllNew),
+	})
+	if err != nil || aws.StringValue(output.Attributes["urls"].M["api"].S) != "https://prod" || aws.StringValue(output.Attributes["copy"].S) != "qa" || output.Attributes["appName"] != nil {
+		t.Errorf("UpdateItem(SET #u.api, copy REMOVE appName) == %v, %v, want the updated item", output, err)
+	}
+
+	_, err = db.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName:                 aws.String("apps"),
+		Key:                       key,
+		UpdateExpression:          aws.String("SET urls.api = :api"),
+		ConditionExpression:       aws.String("urls.api = :api"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":api": {S: aws.String("https://qa")}},
+	})
+	if aerr, ok := err.(awserr.Error); !ok || aerr.Code() != dynamodb.ErrCodeConditionalCheckFailedException {
+		t.Errorf("UpdateItem(stale condition) == %v, want %s", err, dynamodb.ErrCodeConditionalCheckFailedException)
+	}
+
+	for _, expression := range []string{"SET missing.api = :api", "SET apiToken = :api", "ADD version :api"} {
+		_, err := db.UpdateItem(&dynamodb.UpdateItemInput{
+			TableName:                 aws.String("apps"),
+			Key:                       key,
+			UpdateExpression:          aws.String(expression),
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":api": {S: aws.String("x")}},
+		})
+		if aerr, ok := err.(awserr.Error); !ok || aerr.Code() != "ValidationException" {
+			t.Errorf("UpdateItem(%q) == %v, want a ValidationException", expression, err)
+		}
+	}
+}
@@ -0,0 +1,743 @@
+package discoverytest
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// item is a DynamoDB item.
+type item = map[string]*dynamodb.AttributeValue
+
+// condition reports whether an item matches a parsed condition expression.
+type condition func(item) bool
+
+// operand resolves to a value of an item, or nil when the item lacks it.
+type operand func(item) *dynamodb.AttributeValue
+
+// parser turns condition, filter and key condition expressions into
+// conditions. It understands comparisons, BETWEEN, IN, AND, OR, NOT,
+// parentheses, attribute_exists, attribute_not_exists, attribute_type,
+// begins_with, contains and size, with #name and :value placeholders.
+type parser struct {
+	tokens []string
+	pos    int
+	names  map[string]*string
+	values map[string]*dynamodb.AttributeValue
+}
+
+func parseCondition(expression string, names map[string]*string, values map[string]*dynamodb.AttributeValue) (condition, error) {
+	tokens, err := tokenize(expression)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens, names: names, values: values}
+	cond, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos != len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q in %q", p.tokens[p.pos], expression)
+	}
+	return cond, nil
+}
+
+// parsePaths parses a projection expression into attribute paths.
+func parsePaths(expression string, names map[string]*string) ([][]string, error) {
+	tokens, err := tokenize(expression)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens, names: names}
+	var paths [][]string
+	for {
+		path, err := p.path()
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+		if !p.accept(",") {
+			break
+		}
+	}
+	if p.pos != len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q in %q", p.tokens[p.pos], expression)
+	}
+	return paths, nil
+}
+
+func tokenize(expression string) ([]string, error) {
+	var tokens []string
+	runes := []rune(expression)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case strings.ContainsRune("(),.[]=", r):
+			tokens = append(tokens, string(r))
+			i++
+		case r == '<' || r == '>':
+			if i+1 < len(runes) && (runes[i+1] == '=' || (r == '<' && runes[i+1] == '>')) {
+				tokens = append(tokens, string(runes[i:i+2]))
+				i += 2
+			} else {
+				tokens = append(tokens, string(r))
+				i++
+			}
+		case r == '#' || r == ':' || r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r):
+			start := i
+			i++
+			for i < len(runes) && (runes[i] == '_' || runes[i] == '-' || unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i])) {
+				i++
+			}
+			tokens = append(tokens, string(runes[start:i]))
+		default:
+			return nil, fmt.Errorf("unexpected %q in %q", r, expression)
+		}
+	}
+	return tokens, nil
+}
+
+func (p *parser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *parser) accept(token string) bool {
+	if strings.EqualFold(p.peek(), token) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(token string) error {
+	if !p.accept(token) {
+		return fmt.Errorf("expected %q, found %q", token, p.peek())
+	}
+	return nil
+}
+
+func (p *parser) or() (condition, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("OR") {
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(i item) bool { return l(i) || right(i) }
+	}
+	return left, nil
+}
+
+func (p *parser) and() (condition, error) {
+	left, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("AND") {
+		right, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(i item) bool { return l(i) && right(i) }
+	}
+	return left, nil
+}
+
+func (p *parser) not() (condition, error) {
+	if p.accept("NOT") {
+		cond, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return func(i item) bool { return !cond(i) }, nil
+	}
+	return p.primary()
+}
+
+func (p *parser) primary() (condition, error) {
+	if p.accept("(") {
+		cond, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		return cond, p.expect(")")
+	}
+
+	switch name := strings.ToLower(p.peek()); name {
+	case "attribute_exists", "attribute_not_exists", "attribute_type", "begins_with", "contains":
+		p.pos++
+		return p.function(name)
+	}
+
+	left, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	switch comparator := p.peek(); {
+	case comparator == "=" || comparator == "<>" || comparator == "<" || comparator == "<=" || comparator == ">" || comparator == ">=":
+		p.pos++
+		right, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		return compareWith(comparator, left, right), nil
+	case p.accept("BETWEEN"):
+		low, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("AND"); err != nil {
+			return nil, err
+		}
+		high, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		return func(i item) bool {
+			value := left(i)
+			lowCmp, lowOK := compare(value, low(i))
+			highCmp, highOK := compare(value, high(i))
+			return lowOK && highOK && lowCmp >= 0 && highCmp <= 0
+		}, nil
+	case p.accept("IN"):
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		var candidates []operand
+		for {
+			candidate, err := p.operand()
+			if err != nil {
+				return nil, err
+			}
+			candidates = append(candidates, candidate)
+			if !p.accept(",") {
+				break
+			}
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return func(i item) bool {
+			value := left(i)
+			for _, candidate := range candidates {
+				if value != nil && equal(value, candidate(i)) {
+					return true
+				}
+			}
+			return false
+		}, nil
+	default:
+		return nil, fmt.Errorf("expected a comparison, found %q", comparator)
+	}
+}
+
+func (p *parser) function(name string) (condition, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	path, err := p.path()
+	if err != nil {
+		return nil, err
+	}
+	var arg operand
+	if name != "attribute_exists" && name != "attribute_not_exists" {
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+		if arg, err = p.operand(); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+
+	switch name {
+	case "attribute_exists":
+		return func(i item) bool { return resolve(i, path) != nil }, nil
+	case "attribute_not_exists":
+		return func(i item) bool { return resolve(i, path) == nil }, nil
+	case "attribute_type":
+		return func(i item) bool {
+			value, kind := resolve(i, path), arg(i)
+			return value != nil && kind != nil && typeOf(value) == aws.StringValue(kind.S)
+		}, nil
+	case "begins_with":
+		return func(i item) bool {
+			value, prefix := resolve(i, path), arg(i)
+			switch {
+			case value == nil || prefix == nil:
+				return false
+			case value.S != nil && prefix.S != nil:
+				return strings.HasPrefix(*value.S, *prefix.S)
+			case value.B != nil && prefix.B != nil:
+				return bytes.HasPrefix(value.B, prefix.B)
+			}
+			return false
+		}, nil
+	default: // contains
+		return func(i item) bool {
+			value, member := resolve(i, path), arg(i)
+			switch {
+			case value == nil || member == nil:
+				return false
+			case value.S != nil && member.S != nil:
+				return strings.Contains(*value.S, *member.S)
+			case value.B != nil && member.B != nil:
+				return bytes.Contains(value.B, member.B)
+			}
+			for _, element := range elements(value) {
+				if equal(element, member) {
+					return true
+				}
+			}
+			return false
+		}, nil
+	}
+}
+
+func (p *parser) operand() (operand, error) {
+	token := p.peek()
+	switch {
+	case strings.HasPrefix(token, ":"):
+		p.pos++
+		value, ok := p.values[token]
+		if !ok {
+			return nil, fmt.Errorf("value %s is not defined in ExpressionAttributeValues", token)
+		}
+		return func(item) *dynamodb.AttributeValue { return value }, nil
+	case strings.EqualFold(token, "size"):
+		p.pos++
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		path, err := p.path()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return func(i item) *dynamodb.AttributeValue {
+			value := resolve(i, path)
+			switch {
+			case value == nil:
+				return nil
+			case value.S != nil:
+				return &dynamodb.AttributeValue{N: aws.String(strconv.Itoa(len([]rune(*value.S))))}
+			case value.B != nil:
+				return &dynamodb.AttributeValue{N: aws.String(strconv.Itoa(len(value.B)))}
+			case value.M != nil:
+				return &dynamodb.AttributeValue{N: aws.String(strconv.Itoa(len(value.M)))}
+			}
+			return &dynamodb.AttributeValue{N: aws.String(strconv.Itoa(len(elements(value))))}
+		}, nil
+	default:
+		path, err := p.path()
+		if err != nil {
+			return nil, err
+		}
+		return func(i item) *dynamodb.AttributeValue { return resolve(i, path) }, nil
+	}
+}
+
+// path parses a document path. List indexes are kept as "[n]" elements.
+func (p *parser) path() ([]string, error) {
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	path := []string{name}
+	for {
+		switch {
+		case p.accept("."):
+			if name, err = p.name(); err != nil {
+				return nil, err
+			}
+			path = append(path, name)
+		case p.accept("["):
+			index := p.peek()
+			if _, err := strconv.Atoi(index); err != nil {
+				return nil, fmt.Errorf("expected a list index, found %q", index)
+			}
+			p.pos++
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			path = append(path, "["+index+"]")
+		default:
+			return path, nil
+		}
+	}
+}
+
+func (p *parser) name() (string, error) {
+	token := p.peek()
+	switch {
+	case strings.HasPrefix(token, "#"):
+		p.pos++
+		name, ok := p.names[token]
+		if !ok {
+			return "", fmt.Errorf("name %s is not defined in ExpressionAttributeNames", token)
+		}
+		return aws.StringValue(name), nil
+	case token == "" || strings.HasPrefix(token, ":") || strings.ContainsAny(token, "(),.[]=<>"):
+		return "", fmt.Errorf("expected an attribute name, found %q", token)
+	default:
+		p.pos++
+		return token, nil
+	}
+}
+
+func resolve(i item, path []string) *dynamodb.AttributeValue {
+	value := i[path[0]]
+	for _, element := range path[1:] {
+		if value == nil {
+			return nil
+		}
+		if strings.HasPrefix(element, "[") {
+			index, _ := strconv.Atoi(strings.Trim(element, "[]"))
+			if index >= len(value.L) {
+				return nil
+			}
+			value = value.L[index]
+		} else {
+			value = value.M[element]
+		}
+	}
+	return value
+}
+
+func compareWith(comparator string, left, right operand) condition {
+	return func(i item) bool {
+		a, b := left(i), right(i)
+		if a == nil || b == nil {
+			return false
+		}
+		switch comparator {
+		case "=":
+			return equal(a, b)
+		case "<>":
+			return !equal(a, b)
+		}
+		cmp, ok := compare(a, b)
+		if !ok {
+			return false
+		}
+		switch comparator {
+		case "<":
+			return cmp < 0
+		case "<=":
+			return cmp <= 0
+		case ">":
+			return cmp > 0
+		default:
+			return cmp >= 0
+		}
+	}
+}
+
+// compare orders two strings, numbers or binaries of the same type.
+func compare(a, b *dynamodb.AttributeValue) (int, bool) {
+	switch {
+	case a == nil || b == nil:
+		return 0, false
+	case a.S != nil && b.S != nil:
+		return strings.Compare(*a.S, *b.S), true
+	case a.N != nil && b.N != nil:
+		x, xOK := new(big.Float).SetString(*a.N)
+		y, yOK := new(big.Float).SetString(*b.N)
+		if !xOK || !yOK {
+			return 0, false
+		}
+		return x.Cmp(y), true
+	case a.B != nil && b.B != nil:
+		return bytes.Compare(a.B, b.B), true
+	}
+	return 0, false
+}
+
+func equal(a, b *dynamodb.AttributeValue) bool {
+	return reflect.DeepEqual(canonical(a), canonical(b))
+}
+
+// canonical returns value in a form where equal values are deeply equal:
+// numbers are normalized and sets sorted.
+func canonical(value *dynamodb.AttributeValue) interface{} {
+	number := func(n string) string {
+		if f, ok := new(big.Float).SetString(n); ok {
+			return f.Text('g', -1)
+		}
+		return n
+	}
+	sortedSet := func(set []string) []string {
+		sort.Strings(set)
+		return set
+	}
+	switch {
+	case value == nil:
+		return nil
+	case value.S != nil:
+		return [2]string{"S", *value.S}
+	case value.N != nil:
+		return [2]string{"N", number(*value.N)}
+	case value.B != nil:
+		return [2]string{"B", string(value.B)}
+	case value.BOOL != nil:
+		return [2]string{"BOOL", strconv.FormatBool(*value.BOOL)}
+	case value.NULL != nil:
+		return [2]string{"NULL", "true"}
+	case value.SS != nil:
+		return map[string][]string{"SS": sortedSet(aws.StringValueSlice(value.SS))}
+	case value.NS != nil:
+		set := make([]string, len(value.NS))
+		for i, n := range value.NS {
+			set[i] = number(aws.StringValue(n))
+		}
+		return map[string][]string{"NS": sortedSet(set)}
+	case value.BS != nil:
+		set := make([]string, len(value.BS))
+		for i, b := range value.BS {
+			set[i] = string(b)
+		}
+		return map[string][]string{"BS": sortedSet(set)}
+	case value.M != nil:
+		m := make(map[string]interface{}, len(value.M))
+		for name, element := range value.M {
+			m[name] = canonical(element)
+		}
+		return m
+	case value.L != nil:
+		l := make([]interface{}, len(value.L))
+		for i, element := range value.L {
+			l[i] = canonical(element)
+		}
+		return l
+	}
+	return nil
+}
+
+func typeOf(value *dynamodb.AttributeValue) string {
+	switch {
+	case value.S != nil:
+		return dynamodb.ScalarAttributeTypeS
+	case value.N != nil:
+		return dynamodb.ScalarAttributeTypeN
+	case value.B != nil:
+		return dynamodb.ScalarAttributeTypeB
+	case value.BOOL != nil:
+		return "BOOL"
+	case value.NULL != nil:
+		return "NULL"
+	case value.SS != nil:
+		return "SS"
+	case value.NS != nil:
+		return "NS"
+	case value.BS != nil:
+		return "BS"
+	case value.M != nil:
+		return "M"
+	default:
+		return "L"
+	}
+}
+
+// elements returns the members of a set or list as values.
+func elements(value *dynamodb.AttributeValue) []*dynamodb.AttributeValue {
+	var members []*dynamodb.AttributeValue
+	for _, s := range value.SS {
+		members = append(members, &dynamodb.AttributeValue{S: s})
+	}
+	for _, n := range value.NS {
+		members = append(members, &dynamodb.AttributeValue{N: n})
+	}
+	for _, b := range value.BS {
+		members = append(members, &dynamodb.AttributeValue{B: b})
+	}
+	return append(members, value.L...)
+}
+
+// legacyConditions turns KeyConditions or QueryFilter maps into a condition.
+func legacyConditions(conditions map[string]*dynamodb.Condition) (condition, error) {
+	var checks []condition
+	for name, c := range conditions {
+		name, args := name, c.AttributeValueList
+		value := func(i item) *dynamodb.AttributeValue { return i[name] }
+		arg := func(n int) operand {
+			return func(item) *dynamodb.AttributeValue {
+				if n < len(args) {
+					return args[n]
+				}
+				return nil
+			}
+		}
+		switch op := aws.StringValue(c.ComparisonOperator); op {
+		case dynamodb.ComparisonOperatorEq:
+			checks = append(checks, compareWith("=", value, arg(0)))
+		case dynamodb.ComparisonOperatorNe:
+			checks = append(checks, compareWith("<>", value, arg(0)))
+		case dynamodb.ComparisonOperatorLt:
+			checks = append(checks, compareWith("<", value, arg(0)))
+		case dynamodb.ComparisonOperatorLe:
+			checks = append(checks, compareWith("<=", value, arg(0)))
+		case dynamodb.ComparisonOperatorGt:
+			checks = append(checks, compareWith(">", value, arg(0)))
+		case dynamodb.ComparisonOperatorGe:
+			checks = append(checks, compareWith(">=", value, arg(0)))
+		case dynamodb.ComparisonOperatorBeginsWith:
+			checks = append(checks, func(i item) bool {
+				v, prefix := i[name], arg(0)(i)
+				return v != nil && v.S != nil && prefix != nil && prefix.S != nil && strings.HasPrefix(*v.S, *prefix.S)
+			})
+		case dynamodb.ComparisonOperatorBetween:
+			checks = append(checks, func(i item) bool {
+				low, lowOK := compare(i[name], arg(0)(i))
+				high, highOK := compare(i[name], arg(1)(i))
+				return lowOK && highOK && low >= 0 && high <= 0
+			})
+		default:
+			return nil, fmt.Errorf("unsupported ComparisonOperator %s", op)
+		}
+	}
+	return func(i item) bool {
+		for _, check := range checks {
+			if !check(i) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+// assignment is a single action of an update expression. value is nil for
+// REMOVE.
+type assignment struct {
+	path  []string
+	value operand
+}
+
+// parseUpdate parses the SET and REMOVE clauses of an update expression.
+// SET takes values, paths and size; ADD, DELETE and functions such as
+// list_append are not supported.
+func parseUpdate(expression string, names map[string]*string, values map[string]*dynamodb.AttributeValue) ([]assignment, error) {
+	tokens, err := tokenize(expression)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens, names: names, values: values}
+	var assignments []assignment
+	for p.pos < len(p.tokens) {
+		switch {
+		case p.accept("SET"):
+			for {
+				path, err := p.path()
+				if err != nil {
+					return nil, err
+				}
+				if err := p.expect("="); err != nil {
+					return nil, err
+				}
+				value, err := p.operand()
+				if err != nil {
+					return nil, err
+				}
+				assignments = append(assignments, assignment{path: path, value: value})
+				if !p.accept(",") {
+					break
+				}
+			}
+		case p.accept("REMOVE"):
+			for {
+				path, err := p.path()
+				if err != nil {
+					return nil, err
+				}
+				assignments = append(assignments, assignment{path: path})
+				if !p.accept(",") {
+					break
+				}
+			}
+		default:
+			return nil, fmt.Errorf("unexpected %q in %q", p.peek(), expression)
+		}
+	}
+	if len(assignments) == 0 {
+		return nil, fmt.Errorf("empty update expression")
+	}
+	return assignments, nil
+}
+
+// apply makes the assignments to i. Operands are resolved against old, the
+// item before the update, as DynamoDB does.
+func apply(i, old item, assignments []assignment) error {
+	values := make([]*dynamodb.AttributeValue, len(assignments))
+	for n, a := range assignments {
+		if a.value == nil {
+			continue
+		}
+		if values[n] = a.value(old); values[n] == nil {
+			return fmt.Errorf("an operand in the update expression does not refer to an existing value")
+		}
+	}
+	for n, a := range assignments {
+		if err := assign(i, a.path, values[n]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// assign sets the value at path, or removes it when value is nil. The maps
+// and lists leading to path must exist.
+func assign(i item, path []string, value *dynamodb.AttributeValue) error {
+	if len(path) == 1 {
+		if value == nil {
+			delete(i, path[0])
+		} else {
+			i[path[0]] = value
+		}
+		return nil
+	}
+	parent := resolve(i, path[:len(path)-1])
+	last := path[len(path)-1]
+	switch {
+	case parent != nil && parent.M != nil && !strings.HasPrefix(last, "["):
+		if value == nil {
+			delete(parent.M, last)
+		} else {
+			parent.M[last] = value
+		}
+		return nil
+	case parent != nil && parent.L != nil && strings.HasPrefix(last, "["):
+		index, _ := strconv.Atoi(strings.Trim(last, "[]"))
+		switch {
+		case index >= len(parent.L) && value != nil:
+			parent.L = append(parent.L, value)
+		case index >= len(parent.L):
+		case value == nil:
+			parent.L = append(parent.L[:index], parent.L[index+1:]...)
+		default:
+			parent.L[index] = value
+		}
+		return nil
+	}
+	return fmt.Errorf("the document path provided in the update expression is invalid for update")
+}
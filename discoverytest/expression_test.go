@@ -0,0 +1,69 @@
+package discoverytest
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+func TestParseCondition(t *testing.T) {
+	var (
+		names  = map[string]*string{"#0": aws.String("appName"), "#n": aws.String("urls")}
+		values = map[string]*dynamodb.AttributeValue{
+			":0":   {S: aws.String("sonos")},
+			":e":   {S: aws.String("qa")},
+			":p":   {S: aws.String("so")},
+			":one": {N: aws.String("1.0")},
+			":ten": {N: aws.String("10")},
+			":loc": {S: aws.String("en-US")},
+			":t":   {S: aws.String("M")},
+		}
+		stored = item{
+			"appName":     {S: aws.String("sonos")},
+			"environment": {S: aws.String("qa")},
+			"version":     {N: aws.String("2")},
+			"locales":     {SS: aws.StringSlice([]string{"fr-CA", "en-US"})},
+			"urls":        {M: map[string]*dynamodb.AttributeValue{"api": {S: aws.String("https://qa")}}},
+			"ports":       {L: []*dynamodb.AttributeValue{{N: aws.String("443")}}},
+		}
+	)
+	tests := []struct {
+		expression string
+		want       bool
+	}{
+		{"(#0 = :0) AND (environment = :e)", true},
+		{"environment = :e and appName <> :0", false},
+		{"environment = :p OR appName = :0", true},
+		{"NOT appName = :0", false},
+		{"version BETWEEN :one AND :ten", true},
+		{"version > :ten", false},
+		{"version IN (:one, :ten)", false},
+		{"begins_with(appName, :p)", true},
+		{"contains(locales, :loc)", true},
+		{"attribute_exists(#n.api) AND attribute_not_exists(countryCode)", true},
+		{"attribute_type(urls, :t)", true},
+		{"size(locales) > :one", true},
+		{"ports[0] = :one", false},
+		{"missing = :0", false},
+	}
+
+	for _, test := range tests {
+		cond, err := parseCondition(test.expression, names, values)
+		if err != nil {
+			t.Errorf("parseCondition(%q) == %v, want nil", test.expression, err)
+			continue
+		}
+		if got := cond(stored); got != test.want {
+			t.Errorf("parseCondition(%q)(item) == %v, want %v", test.expression, got, test.want)
+		}
+	}
+}
+
+func TestParseCondition_Invalid(t *testing.T) {
+	for _, expression := range []string{"appName = :undefined", "#undefined = :0", "appName =", "appName = :0 AND", "(appName = :0", "appName ! :0"} {
+		if _, err := parseCondition(expression, nil, map[string]*dynamodb.AttributeValue{":0": {S: aws.String("x")}}); err == nil {
+			t.Errorf("parseCondition(%q) == nil, want an error", expression)
+		}
+	}
+}
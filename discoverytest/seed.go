@@ -0,0 +1,37 @@
+package discoverytest
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// Seed creates the table of input, unless it already exists, and puts rows
+// in it. Rows are marshalled with dynamodbattribute.MarshalMap, except
+// items that are already attribute value maps. Any error fails t.
+func (db *DynamoDB) Seed(t testing.TB, input *dynamodb.CreateTableInput, rows ...interface{}) {
+	t.Helper()
+	db.mu.Lock()
+	_, exists := db.tables[aws.StringValue(input.TableName)]
+	db.mu.Unlock()
+	if !exists {
+		if _, err := db.CreateTable(input); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, row := range rows {
+		item, ok := row.(map[string]*dynamodb.AttributeValue)
+		if !ok {
+			var err error
+			if item, err = dynamodbattribute.MarshalMap(row); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := db.PutItem(&dynamodb.PutItemInput{TableName: input.TableName, Item: item}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
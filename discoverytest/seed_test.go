@@ -0,0 +1,36 @@
+package discoverytest
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+func TestDynamoDB_Seed(t *testing.T) {
+	var (
+		db    = NewDynamoDB()
+		input = &dynamodb.CreateTableInput{
+			TableName: aws.String("apps"),
+			KeySchema: []*dynamodb.KeySchemaElement{{AttributeName: aws.String("apiToken"), KeyType: aws.String(dynamodb.KeyTypeHash)}},
+		}
+	)
+
+	db.Seed(t, input,
+		map[string]interface{}{"apiToken": "a", "timeout": 5},
+		struct {
+			APIToken string `dynamodbav:"apiToken"`
+		}{"b"},
+	)
+	// the table exists, so seeding again only adds rows
+	db.Seed(t, input, item{"apiToken": {S: aws.String("c")}})
+
+	output, err := db.Scan(&dynamodb.ScanInput{TableName: aws.String("apps")})
+	if err != nil || aws.Int64Value(output.Count) != 3 {
+		t.Errorf("Scan(apps) == %v, %v, want the 3 seeded rows", output, err)
+	}
+	got, _ := db.GetItem(&dynamodb.GetItemInput{TableName: aws.String("apps"), Key: item{"apiToken": {S: aws.String("a")}}})
+	if aws.StringValue(got.Item["timeout"].N) != "5" {
+		t.Errorf("GetItem(a) == %v, want timeout 5", got.Item)
+	}
+}
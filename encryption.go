@@ -0,0 +1,373 @@
+package shareddiscovery
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+)
+
+// EncryptedAttribute is the key of the map an encrypted attribute is stored
+// as. The map under it holds the encrypted data key, the ID of the key that
+// encrypted it and the nonce prefixed ciphertext.
+const EncryptedAttribute = "$encrypted"
+
+// ErrDecryption is returned when an encrypted attribute cannot be decrypted.
+var ErrDecryption = errors.New("cannot decrypt attribute")
+
+// KeyProvider creates and decrypts the data keys attributes are encrypted
+// with. Data keys are 32 byte AES-256 keys, and are bound to the encryption
+// context of the row they encrypt, which must be given again to decrypt
+// them.
+type KeyProvider interface {
+	GenerateDataKey(ctx context.Context, encryptionContext map[string]string) (plaintext, encrypted []byte, keyID string, err error)
+	DecryptDataKey(ctx context.Context, encrypted []byte, keyID string, encryptionContext map[string]string) ([]byte, error)
+}
+
+// KMSKeyProvider generates data keys under the KMS key KeyID.
+type KMSKeyProvider struct {
+	Client kmsiface.KMSAPI
+	KeyID  string
+}
+
+// GenerateDataKey returns a new AES-256 data key encrypted under KeyID with
+// encryptionContext.
+func (provider KMSKeyProvider) GenerateDataKey(ctx context.Context, encryptionContext map[string]string) ([]byte, []byte, string, error) {
+	output, err := provider.Client.GenerateDataKeyWithContext(ctx, &kms.GenerateDataKeyInput{
+		KeyId:             aws.String(provider.KeyID),
+		KeySpec:           aws.String(kms.DataKeySpecAes256),
+		EncryptionContext: aws.StringMap(encryptionContext),
+	})
+	if err != nil {
+		return nil, nil, "", err
+	}
+	return output.Plaintext, output.CiphertextBlob, aws.StringValue(output.KeyId), nil
+}
+
+// DecryptDataKey decrypts a data key made by GenerateDataKey with the same
+// encryptionContext.
+func (provider KMSKeyProvider) DecryptDataKey(ctx context.Context, encrypted []byte, keyID string, encryptionContext map[string]string) ([]byte, error) {
+	output, err := provider.Client.DecryptWithContext(ctx, &kms.DecryptInput{
+		CiphertextBlob:    encrypted,
+		KeyId:             aws.String(keyID),
+		EncryptionContext: aws.StringMap(encryptionContext),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return output.Plaintext, nil
+}
+
+// LocalKeyProvider wraps data keys with a fixed 32 byte master Key instead
+// of KMS, for tests and local stand-ins. It is not meant for production.
+type LocalKeyProvider struct {
+	Key []byte
+}
+
+// GenerateDataKey returns a random data key wrapped with Key and bound to
+// encryptionContext.
+func (provider LocalKeyProvider) GenerateDataKey(ctx context.Context, encryptionContext map[string]string) ([]byte, []byte, string, error) {
+	plaintext := make([]byte, 32)
+	if _, err := rand.Read(plaintext); err != nil {
+		return nil, nil, "", err
+	}
+	encrypted, err := seal(provider.Key, plaintext, localAdditionalData(encryptionContext))
+	if err != nil {
+		return nil, nil, "", err
+	}
+	return plaintext, encrypted, "local", nil
+}
+
+// DecryptDataKey unwraps a data key made by GenerateDataKey with the same
+// encryptionContext.
+func (provider LocalKeyProvider) DecryptDataKey(ctx context.Context, encrypted []byte, keyID string, encryptionContext map[string]string) ([]byte, error) {
+	return open(provider.Key, encrypted, localAdditionalData(encryptionContext))
+}
+
+func localAdditionalData(encryptionContext map[string]string) []byte {
+	return append([]byte("local"), encodeContext(encryptionContext)...)
+}
+
+// encodeContext returns encryptionContext as JSON, which has its keys
+// sorted so the same context always gives the same bytes.
+func encodeContext(encryptionContext map[string]string) []byte {
+	encoded, _ := json.Marshal(encryptionContext)
+	return encoded
+}
+
+// Encryption encrypts marked attributes with data keys from a KeyProvider
+// when the library writes them, and decrypts every encrypted attribute it
+// reads. Each value is encrypted with AES-256-GCM and bound to its
+// attribute name and to the workspace, apiToken and countryCode of its row,
+// which are also the encryption context of its data key. A ciphertext moved
+// to another attribute or row therefore fails to decrypt; Promote decrypts
+// and encrypts again what it copies between rows.
+type Encryption struct {
+	// Attributes are the top level attributes to encrypt, by workspace.
+	Attributes map[string][]string
+
+	keys KeyProvider
+	ttl  time.Duration
+
+	mu        sync.Mutex
+	current   map[string]*dataKey
+	unwrapped map[string]dataKeyEntry
+}
+
+type dataKey struct {
+	plaintext []byte
+	encrypted []byte
+	keyID     string
+	expires   time.Time
+}
+
+type dataKeyEntry struct {
+	plaintext []byte
+	expires   time.Time
+}
+
+// NewEncryption returns Encryption using keys. Decrypted data keys are
+// cached for ttl, and a generated data key is reused for ttl to encrypt
+// other attributes of the same row. A ttl of zero caches nothing.
+func NewEncryption(keys KeyProvider, ttl time.Duration) *Encryption {
+	return &Encryption{keys: keys, ttl: ttl, current: map[string]*dataKey{}, unwrapped: map[string]dataKeyEntry{}}
+}
+
+// encryptionContext returns the encryption context of the row key. The
+// apiToken is given as a SHA-256 digest, as KMS logs encryption contexts.
+func encryptionContext(key ConfigKey) map[string]string {
+	sum := sha256.Sum256([]byte(key.APIToken))
+	return map[string]string{
+		"workspace":   key.Workspace,
+		"apiToken":    "sha256:" + hex.EncodeToString(sum[:]),
+		"countryCode": key.Country,
+	}
+}
+
+// additionalData returns the data an attribute's ciphertext is bound to.
+func additionalData(encryptionContext map[string]string, name string) []byte {
+	encoded, _ := json.Marshal(struct {
+		Context   map[string]string `json:"context"`
+		Attribute string            `json:"attribute"`
+	}{encryptionContext, name})
+	return encoded
+}
+
+// marked reports whether name is encrypted in workspace.
+func (encryption *Encryption) marked(workspace, name string) bool {
+	for _, attribute := range encryption.Attributes[workspace] {
+		if attribute == name {
+			return true
+		}
+	}
+	return false
+}
+
+// EncryptItem returns a copy of item, the row or part of the row key, with
+// the attributes marked for its workspace encrypted. Attributes that are
+// already encrypted are kept.
+func (encryption *Encryption) EncryptItem(ctx context.Context, key ConfigKey, item map[string]*dynamodb.AttributeValue) (map[string]*dynamodb.AttributeValue, error) {
+	encrypted := make(map[string]*dynamodb.AttributeValue, len(item))
+	for name, value := range item {
+		encrypted[name] = value
+		if !encryption.marked(key.Workspace, name) || isEncrypted(value) {
+			continue
+		}
+		var err error
+		if encrypted[name], err = encryption.encrypt(ctx, key, name, value); err != nil {
+			return nil, fmt.Errorf("encrypting %s: %w", name, err)
+		}
+	}
+	return encrypted, nil
+}
+
+// DecryptItem returns a copy of item, the row or part of the row key, with
+// every encrypted attribute decrypted, whether or not it is marked.
+// Attributes encrypted for another row fail with ErrDecryption.
+func (encryption *Encryption) DecryptItem(ctx context.Context, key ConfigKey, item map[string]*dynamodb.AttributeValue) (map[string]*dynamodb.AttributeValue, error) {
+	decrypted := make(map[string]*dynamodb.AttributeValue, len(item))
+	for name, value := range item {
+		decrypted[name] = value
+		if !isEncrypted(value) {
+			continue
+		}
+		var err error
+		if decrypted[name], err = encryption.decrypt(ctx, key, name, value); err != nil {
+			return nil, fmt.Errorf("%w %s: %v", ErrDecryption, name, err)
+		}
+	}
+	return decrypted, nil
+}
+
+func (encryption *Encryption) encrypt(ctx context.Context, row ConfigKey, name string, value *dynamodb.AttributeValue) (*dynamodb.AttributeValue, error) {
+	bound := encryptionContext(row)
+	key, err := encryption.dataKey(ctx, bound)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := json.Marshal(encodeAttribute(value))
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := seal(key.plaintext, plaintext, additionalData(bound, name))
+	if err != nil {
+		return nil, err
+	}
+	return &dynamodb.AttributeValue{M: map[string]*dynamodb.AttributeValue{
+		EncryptedAttribute: {M: map[string]*dynamodb.AttributeValue{
+			"key":   {B: key.encrypted},
+			"keyId": {S: aws.String(key.keyID)},
+			"data":  {B: sealed},
+		}},
+	}}, nil
+}
+
+func (encryption *Encryption) decrypt(ctx context.Context, row ConfigKey, name string, value *dynamodb.AttributeValue) (*dynamodb.AttributeValue, error) {
+	envelope := value.M[EncryptedAttribute].M
+	if envelope["key"] == nil || envelope["keyId"] == nil || envelope["data"] == nil {
+		return nil, errors.New("malformed envelope")
+	}
+	bound := encryptionContext(row)
+	key, err := encryption.unwrap(ctx, envelope["key"].B, aws.StringValue(envelope["keyId"].S), bound)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := open(key, envelope["data"].B, additionalData(bound, name))
+	if err != nil {
+		return nil, err
+	}
+	var raw interface{}
+	if err := json.Unmarshal(plaintext, &raw); err != nil {
+		return nil, err
+	}
+	return decodeAttribute(raw)
+}
+
+// dataKey returns the data key to encrypt with in encryptionContext,
+// generating one when there is no current key for it or it has expired.
+// Expired keys are pruned whenever a key is added.
+func (encryption *Encryption) dataKey(ctx context.Context, encryptionContext map[string]string) (*dataKey, error) {
+	cacheKey := string(encodeContext(encryptionContext))
+	encryption.mu.Lock()
+	defer encryption.mu.Unlock()
+	now := time.Now()
+	if current, ok := encryption.current[cacheKey]; ok && now.Before(current.expires) {
+		return current, nil
+	}
+	plaintext, encrypted, keyID, err := encryption.keys.GenerateDataKey(ctx, encryptionContext)
+	if err != nil {
+		return nil, err
+	}
+	for key, current := range encryption.current {
+		if !now.Before(current.expires) {
+			delete(encryption.current, key)
+		}
+	}
+	key := &dataKey{plaintext: plaintext, encrypted: encrypted, keyID: keyID, expires: now.Add(encryption.ttl)}
+	if encryption.ttl > 0 {
+		encryption.current[cacheKey] = key
+	}
+	return key, nil
+}
+
+// unwrap returns the plaintext of an encrypted data key, from the cache
+// when it has been decrypted in encryptionContext within ttl. Expired keys
+// are pruned whenever a key is added, so the cache holds at most the keys
+// used within ttl.
+func (encryption *Encryption) unwrap(ctx context.Context, encrypted []byte, keyID string, encryptionContext map[string]string) ([]byte, error) {
+	cacheKey := string(encodeContext(encryptionContext)) + string(encrypted)
+	encryption.mu.Lock()
+	entry, ok := encryption.unwrapped[cacheKey]
+	encryption.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.plaintext, nil
+	}
+
+	plaintext, err := encryption.keys.DecryptDataKey(ctx, encrypted, keyID, encryptionContext)
+	if err != nil {
+		return nil, err
+	}
+	if encryption.ttl > 0 {
+		now := time.Now()
+		encryption.mu.Lock()
+		for key, entry := range encryption.unwrapped {
+			if !now.Before(entry.expires) {
+				delete(encryption.unwrapped, key)
+			}
+		}
+		encryption.unwrapped[cacheKey] = dataKeyEntry{plaintext: plaintext, expires: now.Add(encryption.ttl)}
+		encryption.mu.Unlock()
+	}
+	return plaintext, nil
+}
+
+func isEncrypted(value *dynamodb.AttributeValue) bool {
+	if value == nil || len(value.M) != 1 {
+		return false
+	}
+	envelope, ok := value.M[EncryptedAttribute]
+	return ok && envelope.M != nil
+}
+
+// seal encrypts plaintext with AES-GCM under key and prefixes the nonce.
+func seal(key, plaintext, additional []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additional), nil
+}
+
+// open decrypts what seal returned.
+func open(key, sealed, additional []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	return aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], additional)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encryptItem encrypts the marked attributes of item, of the row key, when
+// the service has Encryption, and returns item as is otherwise.
+func (service SharedDiscovery) encryptItem(ctx context.Context, key ConfigKey, item map[string]*dynamodb.AttributeValue) (map[string]*dynamodb.AttributeValue, error) {
+	if service.Encryption == nil {
+		return item, nil
+	}
+	return service.Encryption.EncryptItem(ctx, key, item)
+}
+
+// decryptItem decrypts the encrypted attributes of item, of the row key,
+// when the service has Encryption, and returns item as is otherwise.
+func (service SharedDiscovery) decryptItem(ctx context.Context, key ConfigKey, item map[string]*dynamodb.AttributeValue) (map[string]*dynamodb.AttributeValue, error) {
+	if service.Encryption == nil || item == nil {
+		return item, nil
+	}
+	return service.Encryption.DecryptItem(ctx, key, item)
+}
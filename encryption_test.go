@@ -0,0 +1,205 @@
+package shareddiscovery
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/golang/mock/gomock"
+	"github.com/pgdevelopers/shareddiscovery/v2/discoverytest"
+	"github.com/pgdevelopers/shareddiscovery/v2/mocks/mock_kmsiface"
+)
+
+var localKeys = LocalKeyProvider{Key: bytes.Repeat([]byte{7}, 32)}
+
+func TestEncryption_RoundTrip(t *testing.T) {
+	var (
+		ctx        = context.TODO()
+		encryption = NewEncryption(localKeys, time.Minute)
+		row        = ConfigKey{Workspace: "apps", APIToken: "abc", Country: "US"}
+		item       = map[string]*dynamodb.AttributeValue{
+			"apiToken": {S: aws.String("abc")},
+			"stripe":   {M: map[string]*dynamodb.AttributeValue{"key": {S: aws.String("sk_test")}, "ports": {NS: aws.StringSlice([]string{"443"})}}},
+		}
+	)
+	encryption.Attributes = map[string][]string{"apps": {"stripe"}}
+
+	encrypted, err := encryption.EncryptItem(ctx, row, item)
+	if err != nil || !isEncrypted(encrypted["stripe"]) || encrypted["apiToken"] != item["apiToken"] {
+		t.Fatalf("EncryptItem(ctx, %v, item) == %v, %v, want only stripe encrypted", row, encrypted, err)
+	}
+	if again, _ := encryption.EncryptItem(ctx, row, encrypted); again["stripe"] != encrypted["stripe"] {
+		t.Errorf("EncryptItem of an encrypted item == %v, want the ciphertext kept", again["stripe"])
+	}
+
+	decrypted, err := encryption.DecryptItem(ctx, row, encrypted)
+	if err != nil || !reflect.DeepEqual(encodeItem(decrypted), encodeItem(item)) {
+		t.Errorf("DecryptItem(ctx, %v, encrypted) == %v, %v, want %v", row, decrypted, err, item)
+	}
+
+	// ciphertexts are bound to their attribute
+	moved := map[string]*dynamodb.AttributeValue{"other": encrypted["stripe"]}
+	if _, err := encryption.DecryptItem(ctx, row, moved); !errors.Is(err, ErrDecryption) {
+		t.Errorf("DecryptItem(ctx, %v, moved) == %v, want %v", row, err, ErrDecryption)
+	}
+
+	// and to their row, such as a prod secret pasted into a QA row
+	for _, other := range []ConfigKey{
+		{Workspace: "apps-qa", APIToken: "abc", Country: "US"},
+		{Workspace: "apps", APIToken: "xyz", Country: "US"},
+		{Workspace: "apps", APIToken: "abc", Country: "DE"},
+	} {
+		if _, err := encryption.DecryptItem(ctx, other, encrypted); !errors.Is(err, ErrDecryption) {
+			t.Errorf("DecryptItem(ctx, %v, encrypted) == %v, want %v", other, err, ErrDecryption)
+		}
+	}
+}
+
+func TestEncryption_PrunesDataKeys(t *testing.T) {
+	var (
+		ctx        = context.TODO()
+		encryption = NewEncryption(localKeys, time.Minute)
+		bound      = encryptionContext(ConfigKey{Workspace: "apps", APIToken: "abc"})
+		firstKey   = string(encodeContext(bound))
+	)
+	_, first, _, _ := localKeys.GenerateDataKey(ctx, bound)
+	_, second, _, _ := localKeys.GenerateDataKey(ctx, bound)
+	firstKey += string(first)
+
+	if _, err := encryption.unwrap(ctx, first, "local", bound); err != nil {
+		t.Fatal(err)
+	}
+	// expire the first key, as if ttl had passed
+	entry := encryption.unwrapped[firstKey]
+	entry.expires = time.Now().Add(-time.Second)
+	encryption.unwrapped[firstKey] = entry
+
+	if _, err := encryption.unwrap(ctx, second, "local", bound); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := encryption.unwrapped[firstKey]; ok || len(encryption.unwrapped) != 1 {
+		t.Errorf("unwrapped == %d keys, want the expired key pruned", len(encryption.unwrapped))
+	}
+}
+
+func TestKMSKeyProvider(t *testing.T) {
+	var (
+		ctx        = context.TODO()
+		mockKMS    = mock_kmsiface.NewMockKMSAPI(gomock.NewController(t))
+		encryption = NewEncryption(KMSKeyProvider{Client: mockKMS, KeyID: "alias/discovery"}, time.Minute)
+		plaintext  = bytes.Repeat([]byte{1}, 32)
+		row        = ConfigKey{Workspace: "apps", APIToken: "abc", Country: "US"}
+		bound      = aws.StringMap(map[string]string{
+			"workspace":   "apps",
+			"apiToken":    "sha256:ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad",
+			"countryCode": "US",
+		})
+	)
+	encryption.Attributes = map[string][]string{"apps": {"secret"}}
+
+	// one data key is generated and decrypted for the row however many
+	// values use it, in the row's encryption context
+	mockKMS.
+		EXPECT().
+		GenerateDataKeyWithContext(gomock.Any(), &kms.GenerateDataKeyInput{KeyId: aws.String("alias/discovery"), KeySpec: aws.String(kms.DataKeySpecAes256), EncryptionContext: bound}).
+		Return(&kms.GenerateDataKeyOutput{Plaintext: plaintext, CiphertextBlob: []byte("wrapped"), KeyId: aws.String("arn:key")}, nil)
+	mockKMS.
+		EXPECT().
+		DecryptWithContext(gomock.Any(), &kms.DecryptInput{CiphertextBlob: []byte("wrapped"), KeyId: aws.String("arn:key"), EncryptionContext: bound}).
+		Return(&kms.DecryptOutput{Plaintext: plaintext}, nil)
+
+	var items []map[string]*dynamodb.AttributeValue
+	for _, value := range []string{"one", "two"} {
+		encrypted, err := encryption.EncryptItem(ctx, row, map[string]*dynamodb.AttributeValue{"secret": {S: aws.String(value)}})
+		if err != nil {
+			t.Fatalf("EncryptItem(ctx, %v, %q) == %v, want nil", row, value, err)
+		}
+		items = append(items, encrypted)
+	}
+	for i, value := range []string{"one", "two"} {
+		if decrypted, err := encryption.DecryptItem(ctx, row, items[i]); err != nil || aws.StringValue(decrypted["secret"].S) != value {
+			t.Errorf("DecryptItem(ctx, %q) == %v, %v, want %q", value, decrypted, err, value)
+		}
+	}
+}
+
+func TestEncryption_ReadAndWritePaths(t *testing.T) {
+	var (
+		ctx  = context.TODO()
+		db   = discoverytest.NewDynamoDB()
+		self = New(db)
+		qa   = ConfigKey{Workspace: "apps", APIToken: "qa"}
+		prod = ConfigKey{Workspace: "apps", APIToken: "prod"}
+	)
+	db.Seed(t, TableDefinition{HashKey: "apiToken"}.CreateTableInput("apps"))
+	self.Encryption = NewEncryption(localKeys, time.Minute)
+	self.Encryption.Attributes = map[string][]string{"apps": {"stripeKey"}}
+	input := `{"apiToken":{"S":"qa"},"name":{"S":"app"},"stripeKey":{"S":"sk_qa"}}
+{"apiToken":{"S":"prod"},"name":{"S":"app"},"stripeKey":{"S":"sk_prod"}}
+`
+
+	if _, err := self.Import(ctx, "apps", strings.NewReader(input), ExportJSONLines, ImportOptions{}); err != nil {
+		t.Fatalf("Import(ctx, %q, r) == %v, want nil", "apps", err)
+	}
+	stored, _ := db.GetItem(&dynamodb.GetItemInput{TableName: aws.String("apps"), Key: keyAttributes(qa)})
+	if !isEncrypted(stored.Item["stripeKey"]) || stored.Item["name"].S == nil {
+		t.Errorf("stored qa item == %v, want only stripeKey encrypted", stored.Item)
+	}
+	if changes, err := self.Import(ctx, "apps", strings.NewReader(input), ExportJSONLines, ImportOptions{}); err != nil || changes[0].Action != ImportUnchanged {
+		t.Errorf("Import(ctx, %q, r) again == %+v, %v, want the encrypted items unchanged", "apps", changes, err)
+	}
+
+	if config, err := self.GetConfig(ctx, "qa", QueryInput{Workspace: "apps"}); err != nil || config["stripeKey"] != "sk_qa" {
+		t.Errorf("GetConfig(ctx, %q, %q) == %v, %v, want stripeKey decrypted", "qa", "apps", config, err)
+	}
+	if results := self.BatchGetConfig(ctx, []ConfigKey{qa, prod}); results[1].Err != nil || results[1].Config["stripeKey"] != "sk_prod" {
+		t.Errorf("BatchGetConfig(ctx, qa, prod) == %+v, want stripeKey decrypted", results)
+	}
+
+	changes, err := self.Diff(ctx, qa, prod)
+	if err != nil || len(changes.Changes) != 1 || changes.Changes[0].Path != "stripeKey" || !isEncrypted(changes.Changes[0].To) {
+		t.Fatalf("Diff(ctx, qa, prod) == %+v, %v, want stripeKey changed and still encrypted", changes, err)
+	}
+	if err := self.Promote(ctx, changes); err != nil {
+		t.Fatalf("Promote(ctx, qa to prod) == %v, want nil", err)
+	}
+	if again, err := self.Diff(ctx, qa, prod); err != nil || len(again.Changes) != 0 {
+		t.Errorf("Diff(ctx, qa, prod) after Promote == %+v, %v, want no changes", again, err)
+	}
+	if config, err := self.GetConfig(ctx, "prod", QueryInput{Workspace: "apps"}); err != nil || config["stripeKey"] != "sk_qa" {
+		t.Errorf("GetConfig(ctx, %q, %q) after Promote == %v, %v, want the qa stripeKey", "prod", "apps", config, err)
+	}
+
+	// the qa ciphertext is encrypted again for the prod row
+	stored, _ = db.GetItem(&dynamodb.GetItemInput{TableName: aws.String("apps"), Key: keyAttributes(prod)})
+	if reflect.DeepEqual(stored.Item["stripeKey"], changes.Changes[0].To) {
+		t.Errorf("stored prod stripeKey == the qa ciphertext, want it encrypted for prod")
+	}
+
+	// a change set edited by hand is encrypted before it is written
+	plain := ChangeSet{Target: prod, Changes: []Change{{Path: "stripeKey", Type: DiffChanged, From: stored.Item["stripeKey"], To: &dynamodb.AttributeValue{S: aws.String("sk_new")}}}}
+	if err := self.Promote(ctx, plain); err != nil {
+		t.Fatalf("Promote(ctx, plaintext change) == %v, want nil", err)
+	}
+	if stored, _ := db.GetItem(&dynamodb.GetItemInput{TableName: aws.String("apps"), Key: keyAttributes(prod)}); !isEncrypted(stored.Item["stripeKey"]) {
+		t.Errorf("stored prod stripeKey == %v, want it encrypted", stored.Item["stripeKey"])
+	}
+
+	// a ciphertext pasted into another row by someone with write access to
+	// the table does not decrypt there
+	stored, _ = db.GetItem(&dynamodb.GetItemInput{TableName: aws.String("apps"), Key: keyAttributes(prod)})
+	db.Seed(t, TableDefinition{HashKey: "apiToken"}.CreateTableInput("apps"), map[string]*dynamodb.AttributeValue{
+		"apiToken":  {S: aws.String("qa")},
+		"stripeKey": stored.Item["stripeKey"],
+	})
+	if config, err := self.GetConfig(ctx, "qa", QueryInput{Workspace: "apps"}); !errors.Is(err, ErrDecryption) {
+		t.Errorf("GetConfig(ctx, %q, %q) with the prod ciphertext == %v, %v, want %v", "qa", "apps", config, err, ErrDecryption)
+	}
+}
@@ -0,0 +1,71 @@
+package shareddiscovery
+
+import (
+	"context"
+	"errors"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+)
+
+var (
+	// ErrInvalidSignature is returned by AdminGetAPIToken when the HMAC
+	// signature does not match the query.
+	ErrInvalidSignature = errors.New("invalid signature")
+
+	// ErrNoResults is returned by AdminGetAPIToken when no row matches the query.
+	ErrNoResults = errors.New("No results found")
+)
+
+// ErrorClass buckets err into a short, low cardinality name that is safe to
+// group telemetry by or to return to callers, such as "not_found" or
+// "aws.ProvisionedThroughputExceededException".
+func ErrorClass(err error) string {
+	var awsErr awserr.Error
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, ErrInvalidSignature):
+		return "invalid_signature"
+	case errors.As(err, new(*AuthorizationError)):
+		return "forbidden"
+	case errors.Is(err, ErrNoResults):
+		return "not_found"
+	case errors.Is(err, ErrCircuitOpen):
+		return "circuit_open"
+	case errors.Is(err, ErrUnprocessed):
+		return "unprocessed"
+	case errors.Is(err, ErrConflict):
+		return "conflict"
+	case errors.As(err, new(*ValidationError)):
+		return "invalid_input"
+	case errors.As(err, new(*SecretError)):
+		return "unresolved_secret"
+	case errors.Is(err, ErrDecryption):
+		return "decryption"
+	case errors.As(err, new(*RateLimitError)):
+		return "rate_limited"
+	case errors.Is(err, ErrUnhealthy):
+		return "unhealthy"
+	case errors.Is(err, ErrSchemaMismatch):
+		return "schema_mismatch"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.As(err, &awsErr):
+		return "aws." + awsErr.Code()
+	default:
+		return "internal"
+	}
+}
+
+// finishSpan tags span with the outcome of its operation and sends it.
+func finishSpan(span Span, err error) {
+	if err != nil {
+		span.AddField("outcome", "error")
+		span.AddField("error.class", ErrorClass(err))
+	} else {
+		span.AddField("outcome", "success")
+	}
+	span.Send()
+}
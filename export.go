@@ -0,0 +1,484 @@
+package shareddiscovery
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"gopkg.in/yaml.v3"
+)
+
+// ExportFormat is the encoding used by Export and Import. Items are written
+// in DynamoDB JSON, where every value is tagged with its type ({"S": "US"},
+// {"N": "1.5"}, {"SS": [...]}, binary as base64), so sets, binary and
+// numbers survive a round trip unchanged.
+type ExportFormat string
+
+// The supported export formats.
+const (
+	// ExportJSONLines writes one item per line.
+	ExportJSONLines ExportFormat = "jsonl"
+	// ExportYAML writes one item per YAML document.
+	ExportYAML ExportFormat = "yaml"
+)
+
+// ImportAction is what Import does, or would do, with an item.
+type ImportAction string
+
+// The actions Import takes.
+const (
+	ImportCreate    ImportAction = "create"
+	ImportUpdate    ImportAction = "update"
+	ImportUnchanged ImportAction = "unchanged"
+)
+
+// ImportOptions configure Import.
+type ImportOptions struct {
+	// DryRun reports the changes Import would make without writing them.
+	DryRun bool
+}
+
+// ImportChange describes the outcome of importing a single item. Changed
+// lists the attributes that differ from the stored item. When Err is set the
+// item was not written, for instance because it changed since it was read.
+type ImportChange struct {
+	Key     ConfigKey
+	Action  ImportAction
+	Changed []string
+	Err     error
+}
+
+// Export streams every item of workspace to w in format and returns how many
+// items were written.
+func (service SharedDiscovery) Export(ctx context.Context, workspace string, w io.Writer, format ExportFormat) (count int, err error) {
+	ctx, exportSpan := service.startSpan(ctx, "Export")
+	defer func(start time.Time) {
+		service.observe(ctx, "Export", workspace, start, err)
+		finishSpan(exportSpan, err)
+	}(time.Now())
+	exportSpan.AddField("workspace", workspace)
+	exportSpan.AddField("format", string(format))
+
+	encode, closeEncoder, err := newItemEncoder(w, format)
+	if err != nil {
+		exportSpan.AddField("error.message", err.Error())
+		return 0, err
+	}
+
+	// each page is retried on its own and the scan resumes after the last
+	// page written, so a throttled scan never writes an item twice
+	input := &dynamodb.ScanInput{TableName: aws.String(workspace)}
+	for {
+		var page *dynamodb.ScanOutput
+		err = service.Resilience.call(ctx, exportSpan, workspace, func() (err error) {
+			page, err = service.DynamodbSvc.ScanWithContext(ctx, input)
+			return err
+		})
+		if err != nil {
+			break
+		}
+		for _, item := range page.Items {
+			if err = encode(encodeItem(item)); err != nil {
+				break
+			}
+			count++
+		}
+		if err != nil || len(page.LastEvaluatedKey) == 0 {
+			break
+		}
+		input.ExclusiveStartKey = page.LastEvaluatedKey
+	}
+	if err == nil {
+		err = closeEncoder()
+	}
+	exportSpan.AddField("items.count", count)
+	if err != nil {
+		exportSpan.AddField("error.message", err.Error())
+	}
+	return count, err
+}
+
+// Import loads items written by Export from r into workspace. Each item is
+// compared with the stored one and only written when it differs, so
+// importing the same file twice is a no-op. Writes are conditional: new
+// items must still be absent and updated items must still hold the values
+// they were compared against, so concurrent edits are reported rather than
+// overwritten.
+//
+// A change is returned for every item read. The error is only set when r
+// cannot be decoded or the table cannot be described.
+func (service SharedDiscovery) Import(ctx context.Context, workspace string, r io.Reader, format ExportFormat, options ImportOptions) (changes []ImportChange, err error) {
+	ctx, importSpan := service.startSpan(ctx, "Import")
+	defer func(start time.Time) {
+		service.observe(ctx, "Import", workspace, start, err)
+		finishSpan(importSpan, err)
+	}(time.Now())
+	importSpan.AddField("workspace", workspace)
+	importSpan.AddField("format", string(format))
+	importSpan.AddField("dry_run", options.DryRun)
+
+	decode, err := newItemDecoder(r, format)
+	if err != nil {
+		importSpan.AddField("error.message", err.Error())
+		return nil, err
+	}
+
+	var table *dynamodb.DescribeTableOutput
+	err = service.Resilience.call(ctx, importSpan, workspace, func() (err error) {
+		table, err = service.DynamodbSvc.DescribeTableWithContext(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(workspace)})
+		return err
+	})
+	if err != nil {
+		importSpan.AddField("error.message", err.Error())
+		return nil, err
+	}
+	var keyNames []string
+	if table.Table != nil {
+		for _, element := range table.Table.KeySchema {
+			keyNames = append(keyNames, aws.StringValue(element.AttributeName))
+		}
+	}
+	if len(keyNames) == 0 {
+		err = fmt.Errorf("shareddiscovery: no key schema for %s", workspace)
+		importSpan.AddField("error.message", err.Error())
+		return nil, err
+	}
+
+	for {
+		var raw map[string]interface{}
+		if err = decode(&raw); err == io.EOF {
+			err = nil
+			break
+		}
+		if err != nil {
+			importSpan.AddField("error.message", err.Error())
+			return changes, err
+		}
+		item, decodeErr := decodeItem(raw)
+		if decodeErr != nil {
+			err = fmt.Errorf("shareddiscovery: item %d: %w", len(changes)+1, decodeErr)
+			importSpan.AddField("error.message", err.Error())
+			return changes, err
+		}
+		changes = append(changes, service.importItem(ctx, importSpan, workspace, keyNames, item, options))
+	}
+
+	for _, action := range []ImportAction{ImportCreate, ImportUpdate, ImportUnchanged} {
+		importSpan.AddField("items."+string(action), countAction(changes, action))
+	}
+	return changes, nil
+}
+
+func (service SharedDiscovery) importItem(ctx context.Context, span Span, workspace string, keyNames []string, item map[string]*dynamodb.AttributeValue, options ImportOptions) ImportChange {
+	change := ImportChange{Key: keyFromItem(workspace, item)}
+
+	key := map[string]*dynamodb.AttributeValue{}
+	for _, name := range keyNames {
+		if item[name] == nil {
+			change.Err = fmt.Errorf("shareddiscovery: item is missing key attribute %s", name)
+			return change
+		}
+		key[name] = item[name]
+	}
+
+	var stored *dynamodb.GetItemOutput
+	change.Err = service.Resilience.call(ctx, span, workspace, func() (err error) {
+		stored, err = service.DynamodbSvc.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+			TableName:      aws.String(workspace),
+			Key:            key,
+			ConsistentRead: aws.Bool(true),
+		})
+		return err
+	})
+	if change.Err != nil {
+		return change
+	}
+
+	// compare plaintexts, as either side may hold a ciphertext of the same
+	// value, bound to the row as keyed
+	row := keyFromItem(workspace, key)
+	plain, err := service.decryptItem(ctx, row, item)
+	var storedPlain map[string]*dynamodb.AttributeValue
+	if err == nil {
+		storedPlain, err = service.decryptItem(ctx, row, stored.Item)
+	}
+	if err == nil {
+		item, err = service.encryptItem(ctx, row, item)
+	}
+	if err != nil {
+		change.Err = err
+		return change
+	}
+
+	input := &dynamodb.PutItemInput{TableName: aws.String(workspace), Item: item}
+	if len(stored.Item) == 0 {
+		change.Action = ImportCreate
+		change.Changed = sortedAttributeNames(item)
+		input.ConditionExpression = aws.String("attribute_not_exists(#k0)")
+		input.ExpressionAttributeNames = map[string]*string{"#k0": aws.String(keyNames[0])}
+	} else {
+		change.Changed = changedAttributes(storedPlain, plain)
+		if len(change.Changed) == 0 {
+			change.Action = ImportUnchanged
+			return change
+		}
+		change.Action = ImportUpdate
+		unchangedSince(input, stored.Item)
+	}
+
+	if options.DryRun {
+		return change
+	}
+	change.Err = service.Resilience.call(ctx, span, workspace, func() (err error) {
+		_, err = service.DynamodbSvc.PutItemWithContext(ctx, input)
+		return err
+	})
+	if change.Err == nil && service.Cache != nil {
+		service.Cache.Invalidate(change.Key)
+	}
+	return change
+}
+
+// unchangedSince conditions input on the scalar attributes of stored still
+// holding their values. Sets, lists and maps are left out to keep the
+// condition small, so edits that only touch those are not detected.
+func unchangedSince(input *dynamodb.PutItemInput, stored map[string]*dynamodb.AttributeValue) {
+	input.ExpressionAttributeNames = map[string]*string{}
+	input.ExpressionAttributeValues = map[string]*dynamodb.AttributeValue{}
+	var conditions []string
+	for i, name := range sortedAttributeNames(stored) {
+		value := stored[name]
+		if value.S == nil && value.N == nil && value.B == nil && value.BOOL == nil {
+			continue
+		}
+		input.ExpressionAttributeNames[fmt.Sprintf("#a%d", i)] = aws.String(name)
+		input.ExpressionAttributeValues[fmt.Sprintf(":a%d", i)] = value
+		conditions = append(conditions, fmt.Sprintf("#a%d = :a%d", i, i))
+	}
+	input.ConditionExpression = aws.String(strings.Join(conditions, " AND "))
+}
+
+func changedAttributes(stored, item map[string]*dynamodb.AttributeValue) []string {
+	var changed []string
+	for name, value := range item {
+		if _, ok := stored[name]; !ok || !reflect.DeepEqual(encodeAttribute(stored[name]), encodeAttribute(value)) {
+			changed = append(changed, name)
+		}
+	}
+	for name := range stored {
+		if _, ok := item[name]; !ok {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+func sortedAttributeNames(item map[string]*dynamodb.AttributeValue) []string {
+	names := make([]string, 0, len(item))
+	for name := range item {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func countAction(changes []ImportChange, action ImportAction) int {
+	count := 0
+	for _, change := range changes {
+		if change.Action == action {
+			count++
+		}
+	}
+	return count
+}
+
+func newItemEncoder(w io.Writer, format ExportFormat) (encode func(interface{}) error, closeEncoder func() error, err error) {
+	switch format {
+	case ExportJSONLines:
+		encoder := json.NewEncoder(w)
+		return encoder.Encode, func() error { return nil }, nil
+	case ExportYAML:
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		return encoder.Encode, encoder.Close, nil
+	default:
+		return nil, nil, fmt.Errorf("shareddiscovery: unknown export format %q", format)
+	}
+}
+
+func newItemDecoder(r io.Reader, format ExportFormat) (decode func(interface{}) error, err error) {
+	switch format {
+	case ExportJSONLines:
+		return json.NewDecoder(r).Decode, nil
+	case ExportYAML:
+		return yaml.NewDecoder(r).Decode, nil
+	default:
+		return nil, fmt.Errorf("shareddiscovery: unknown export format %q", format)
+	}
+}
+
+func encodeItem(item map[string]*dynamodb.AttributeValue) map[string]interface{} {
+	encoded := make(map[string]interface{}, len(item))
+	for name, value := range item {
+		encoded[name] = encodeAttribute(value)
+	}
+	return encoded
+}
+
+// encodeAttribute returns value in DynamoDB JSON. Sets are sorted so equal
+// items always encode the same way.
+func encodeAttribute(value *dynamodb.AttributeValue) map[string]interface{} {
+	switch {
+	case value == nil:
+		return map[string]interface{}{"NULL": true}
+	case value.S != nil:
+		return map[string]interface{}{"S": *value.S}
+	case value.N != nil:
+		return map[string]interface{}{"N": *value.N}
+	case value.B != nil:
+		return map[string]interface{}{"B": base64.StdEncoding.EncodeToString(value.B)}
+	case value.BOOL != nil:
+		return map[string]interface{}{"BOOL": *value.BOOL}
+	case value.NULL != nil:
+		return map[string]interface{}{"NULL": *value.NULL}
+	case value.SS != nil:
+		return map[string]interface{}{"SS": sortedSet(aws.StringValueSlice(value.SS))}
+	case value.NS != nil:
+		return map[string]interface{}{"NS": sortedSet(aws.StringValueSlice(value.NS))}
+	case value.BS != nil:
+		set := make([]string, len(value.BS))
+		for i, b := range value.BS {
+			set[i] = base64.StdEncoding.EncodeToString(b)
+		}
+		return map[string]interface{}{"BS": sortedSet(set)}
+	case value.M != nil:
+		return map[string]interface{}{"M": encodeItem(value.M)}
+	case value.L != nil:
+		list := make([]interface{}, len(value.L))
+		for i, element := range value.L {
+			list[i] = encodeAttribute(element)
+		}
+		return map[string]interface{}{"L": list}
+	default:
+		return map[string]interface{}{"NULL": true}
+	}
+}
+
+func sortedSet(set []string) []interface{} {
+	sort.Strings(set)
+	sorted := make([]interface{}, len(set))
+	for i, member := range set {
+		sorted[i] = member
+	}
+	return sorted
+}
+
+func decodeItem(raw map[string]interface{}) (map[string]*dynamodb.AttributeValue, error) {
+	item := make(map[string]*dynamodb.AttributeValue, len(raw))
+	for name, value := range raw {
+		decoded, err := decodeAttribute(value)
+		if err != nil {
+			return nil, fmt.Errorf("attribute %s: %w", name, err)
+		}
+		item[name] = decoded
+	}
+	return item, nil
+}
+
+var errAttributeType = errors.New("want an object with exactly one of S, N, B, BOOL, NULL, SS, NS, BS, M or L")
+
+func decodeAttribute(raw interface{}) (*dynamodb.AttributeValue, error) {
+	typed, ok := raw.(map[string]interface{})
+	if !ok || len(typed) != 1 {
+		return nil, errAttributeType
+	}
+	for kind, value := range typed {
+		switch kind {
+		case "S", "N":
+			s, ok := value.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s must be a string", kind)
+			}
+			if kind == "S" {
+				return &dynamodb.AttributeValue{S: aws.String(s)}, nil
+			}
+			return &dynamodb.AttributeValue{N: aws.String(s)}, nil
+		case "B":
+			s, _ := value.(string)
+			b, err := base64.StdEncoding.DecodeString(s)
+			if err != nil {
+				return nil, fmt.Errorf("B must be base64: %w", err)
+			}
+			return &dynamodb.AttributeValue{B: b}, nil
+		case "BOOL", "NULL":
+			b, ok := value.(bool)
+			if !ok {
+				return nil, fmt.Errorf("%s must be a boolean", kind)
+			}
+			if kind == "BOOL" {
+				return &dynamodb.AttributeValue{BOOL: aws.Bool(b)}, nil
+			}
+			return &dynamodb.AttributeValue{NULL: aws.Bool(b)}, nil
+		case "SS", "NS", "BS":
+			list, ok := value.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%s must be a list", kind)
+			}
+			set := make([]string, len(list))
+			for i, member := range list {
+				if set[i], ok = member.(string); !ok {
+					return nil, fmt.Errorf("%s members must be strings", kind)
+				}
+			}
+			switch kind {
+			case "SS":
+				return &dynamodb.AttributeValue{SS: aws.StringSlice(set)}, nil
+			case "NS":
+				return &dynamodb.AttributeValue{NS: aws.StringSlice(set)}, nil
+			}
+			bs := make([][]byte, len(set))
+			for i, member := range set {
+				b, err := base64.StdEncoding.DecodeString(member)
+				if err != nil {
+					return nil, fmt.Errorf("BS must hold base64: %w", err)
+				}
+				bs[i] = b
+			}
+			return &dynamodb.AttributeValue{BS: bs}, nil
+		case "M":
+			m, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, errors.New("M must be an object")
+			}
+			decoded, err := decodeItem(m)
+			if err != nil {
+				return nil, err
+			}
+			return &dynamodb.AttributeValue{M: decoded}, nil
+		case "L":
+			list, ok := value.([]interface{})
+			if !ok {
+				return nil, errors.New("L must be a list")
+			}
+			decoded := make([]*dynamodb.AttributeValue, len(list))
+			for i, element := range list {
+				var err error
+				if decoded[i], err = decodeAttribute(element); err != nil {
+					return nil, err
+				}
+			}
+			return &dynamodb.AttributeValue{L: decoded}, nil
+		}
+	}
+	return nil, errAttributeType
+}
@@ -0,0 +1,247 @@
+package shareddiscovery
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/golang/mock/gomock"
+	"github.com/pgdevelopers/shareddiscovery/v2/mocks/mock_dynamodbiface"
+)
+
+func exportItems() []map[string]*dynamodb.AttributeValue {
+	return []map[string]*dynamodb.AttributeValue{
+		{
+			"apiToken":    {S: aws.String("abc")},
+			"countryCode": {S: aws.String("US")},
+			"version":     {N: aws.String("1.50")},
+			"locales":     {SS: aws.StringSlice([]string{"fr-CA", "en-US"})},
+			"logo":        {B: []byte{0xff, 0x00}},
+			"urls":        {M: map[string]*dynamodb.AttributeValue{"api": {S: aws.String("https://qa")}}},
+			"ports":       {L: []*dynamodb.AttributeValue{{N: aws.String("443")}, {NULL: aws.Bool(true)}}},
+		},
+		{
+			"apiToken":    {S: aws.String("def")},
+			"countryCode": {S: aws.String("CA")},
+			"enabled":     {BOOL: aws.Bool(false)},
+		},
+	}
+}
+
+// expectScan expects Export to scan workspace a page per item.
+func expectScan(mockDynamoDB *mock_dynamodbiface.MockDynamoDBAPI, workspace string, items []map[string]*dynamodb.AttributeValue) {
+	if len(items) == 0 {
+		mockDynamoDB.EXPECT().ScanWithContext(gomock.Any(), &dynamodb.ScanInput{TableName: aws.String(workspace)}).Return(&dynamodb.ScanOutput{}, nil)
+		return
+	}
+	var calls []*gomock.Call
+	for i, item := range items {
+		input := &dynamodb.ScanInput{TableName: aws.String(workspace)}
+		if i > 0 {
+			input.ExclusiveStartKey = items[i-1]
+		}
+		output := &dynamodb.ScanOutput{Items: []map[string]*dynamodb.AttributeValue{item}}
+		if i < len(items)-1 {
+			output.LastEvaluatedKey = item
+		}
+		calls = append(calls, mockDynamoDB.EXPECT().ScanWithContext(gomock.Any(), input).Return(output, nil))
+	}
+	gomock.InOrder(calls...)
+}
+
+func expectKeySchema(mockDynamoDB *mock_dynamodbiface.MockDynamoDBAPI, workspace string) {
+	mockDynamoDB.
+		EXPECT().
+		DescribeTableWithContext(gomock.Any(), &dynamodb.DescribeTableInput{TableName: aws.String(workspace)}).
+		Return(&dynamodb.DescribeTableOutput{Table: &dynamodb.TableDescription{KeySchema: []*dynamodb.KeySchemaElement{
+			{AttributeName: aws.String("apiToken"), KeyType: aws.String(dynamodb.KeyTypeHash)},
+			{AttributeName: aws.String("countryCode"), KeyType: aws.String(dynamodb.KeyTypeRange)},
+		}}}, nil)
+}
+
+func TestExport_JSONLines(t *testing.T) {
+	var (
+		ctx          = context.TODO()
+		mockDynamoDB = mock_dynamodbiface.NewMockDynamoDBAPI(gomock.NewController(t))
+		self         = New(mockDynamoDB)
+		out          bytes.Buffer
+	)
+	expectScan(mockDynamoDB, "apps", exportItems())
+
+	count, err := self.Export(ctx, "apps", &out, ExportJSONLines)
+
+	if err != nil || count != 2 {
+		t.Fatalf("Export(ctx, %q, w, %q) == %d, %v, want 2, nil", "apps", ExportJSONLines, count, err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	want := `{"apiToken":{"S":"abc"},"countryCode":{"S":"US"},"locales":{"SS":["en-US","fr-CA"]},"logo":{"B":"/wA="},"ports":{"L":[{"N":"443"},{"NULL":true}]},"urls":{"M":{"api":{"S":"https://qa"}}},"version":{"N":"1.50"}}`
+	if len(lines) != 2 || lines[0] != want {
+		t.Errorf("Export(ctx, %q, w, %q) wrote %q, want first line %q", "apps", ExportJSONLines, out.String(), want)
+	}
+}
+
+func TestExport_RetriesPage(t *testing.T) {
+	var (
+		ctx          = context.TODO()
+		mockDynamoDB = mock_dynamodbiface.NewMockDynamoDBAPI(gomock.NewController(t))
+		self         = New(mockDynamoDB)
+		items        = exportItems()
+		out          bytes.Buffer
+	)
+	self.Resilience = NewResilience(ResiliencePolicy{BaseDelay: time.Millisecond})
+
+	// the second page is throttled once and retried from the first page's key
+	gomock.InOrder(
+		mockDynamoDB.
+			EXPECT().
+			ScanWithContext(gomock.Any(), &dynamodb.ScanInput{TableName: aws.String("apps")}).
+			Return(&dynamodb.ScanOutput{Items: items[:1], LastEvaluatedKey: items[0]}, nil),
+		mockDynamoDB.
+			EXPECT().
+			ScanWithContext(gomock.Any(), &dynamodb.ScanInput{TableName: aws.String("apps"), ExclusiveStartKey: items[0]}).
+			Return(nil, errThrottled),
+		mockDynamoDB.
+			EXPECT().
+			ScanWithContext(gomock.Any(), &dynamodb.ScanInput{TableName: aws.String("apps"), ExclusiveStartKey: items[0]}).
+			Return(&dynamodb.ScanOutput{Items: items[1:]}, nil),
+	)
+
+	count, err := self.Export(ctx, "apps", &out, ExportJSONLines)
+
+	if lines := strings.Count(out.String(), "\n"); err != nil || count != 2 || lines != 2 {
+		t.Errorf("Export(ctx, %q, w, %q) == %d, %v with %d lines, want 2 items written once", "apps", ExportJSONLines, count, err, lines)
+	}
+}
+
+func TestExportImport_RoundTrip(t *testing.T) {
+	for _, format := range []ExportFormat{ExportJSONLines, ExportYAML} {
+		var (
+			ctx          = context.TODO()
+			mockDynamoDB = mock_dynamodbiface.NewMockDynamoDBAPI(gomock.NewController(t))
+			self         = New(mockDynamoDB)
+			items        = exportItems()
+			out          bytes.Buffer
+		)
+		expectScan(mockDynamoDB, "apps", items)
+		expectKeySchema(mockDynamoDB, "apps")
+		mockDynamoDB.
+			EXPECT().
+			GetItemWithContext(gomock.Any(), gomock.Any()).
+			Return(&dynamodb.GetItemOutput{Item: items[0]}, nil)
+		mockDynamoDB.
+			EXPECT().
+			GetItemWithContext(gomock.Any(), gomock.Any()).
+			Return(&dynamodb.GetItemOutput{Item: items[1]}, nil)
+
+		if _, err := self.Export(ctx, "apps", &out, format); err != nil {
+			t.Fatalf("Export(ctx, %q, w, %q) == %v, want nil", "apps", format, err)
+		}
+		changes, err := self.Import(ctx, "apps", &out, format, ImportOptions{})
+
+		if err != nil || len(changes) != 2 {
+			t.Fatalf("Import(ctx, %q, r, %q) == %v, %v, want 2 changes", "apps", format, changes, err)
+		}
+		for _, change := range changes {
+			if change.Action != ImportUnchanged || change.Err != nil {
+				t.Errorf("Import(ctx, %q, r, %q) change == %+v, want every exported item unchanged", "apps", format, change)
+			}
+		}
+	}
+}
+
+func TestImport_CreateAndUpdate(t *testing.T) {
+	var (
+		ctx          = context.TODO()
+		mockDynamoDB = mock_dynamodbiface.NewMockDynamoDBAPI(gomock.NewController(t))
+		self         = New(mockDynamoDB)
+		input        = `{"apiToken":{"S":"abc"},"countryCode":{"S":"US"},"name":{"S":"new"}}
+{"apiToken":{"S":"def"},"countryCode":{"S":"CA"},"name":{"S":"app"}}
+`
+	)
+	expectKeySchema(mockDynamoDB, "apps")
+	mockDynamoDB.
+		EXPECT().
+		GetItemWithContext(gomock.Any(), &dynamodb.GetItemInput{
+			TableName:      aws.String("apps"),
+			Key:            map[string]*dynamodb.AttributeValue{"apiToken": {S: aws.String("abc")}, "countryCode": {S: aws.String("US")}},
+			ConsistentRead: aws.Bool(true),
+		}).
+		Return(&dynamodb.GetItemOutput{Item: map[string]*dynamodb.AttributeValue{
+			"apiToken":    {S: aws.String("abc")},
+			"countryCode": {S: aws.String("US")},
+			"name":        {S: aws.String("old")},
+		}}, nil)
+	mockDynamoDB.
+		EXPECT().
+		GetItemWithContext(gomock.Any(), gomock.Any()).
+		Return(&dynamodb.GetItemOutput{}, nil)
+	mockDynamoDB.
+		EXPECT().
+		PutItemWithContext(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx aws.Context, input *dynamodb.PutItemInput, opts ...request.Option) (*dynamodb.PutItemOutput, error) {
+			if want := "#a0 = :a0 AND #a1 = :a1 AND #a2 = :a2"; aws.StringValue(input.ConditionExpression) != want {
+				t.Errorf("PutItem condition == %q, want %q", aws.StringValue(input.ConditionExpression), want)
+			}
+			if aws.StringValue(input.ExpressionAttributeValues[":a2"].S) != "old" {
+				t.Errorf("PutItem condition values == %v, want the stored name", input.ExpressionAttributeValues)
+			}
+			return &dynamodb.PutItemOutput{}, nil
+		})
+	mockDynamoDB.
+		EXPECT().
+		PutItemWithContext(gomock.Any(), gomock.Any()).
+		Return(nil, awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "exists", nil))
+
+	changes, err := self.Import(ctx, "apps", strings.NewReader(input), ExportJSONLines, ImportOptions{})
+
+	if err != nil || len(changes) != 2 {
+		t.Fatalf("Import(ctx, %q, r, %q) == %v, %v, want 2 changes", "apps", ExportJSONLines, changes, err)
+	}
+	if changes[0].Action != ImportUpdate || fmt.Sprint(changes[0].Changed) != "[name]" || changes[0].Err != nil {
+		t.Errorf("Import change[0] == %+v, want an update of name", changes[0])
+	}
+	if changes[1].Action != ImportCreate || changes[1].Err == nil {
+		t.Errorf("Import change[1] == %+v, want a create that lost a race", changes[1])
+	}
+}
+
+func TestImport_DryRun(t *testing.T) {
+	var (
+		ctx          = context.TODO()
+		mockDynamoDB = mock_dynamodbiface.NewMockDynamoDBAPI(gomock.NewController(t))
+		self         = New(mockDynamoDB)
+		input        = "apiToken:\n  S: abc\ncountryCode:\n  S: US\nversion:\n  N: \"2\"\n"
+	)
+	expectKeySchema(mockDynamoDB, "apps")
+	mockDynamoDB.
+		EXPECT().
+		GetItemWithContext(gomock.Any(), gomock.Any()).
+		Return(&dynamodb.GetItemOutput{}, nil)
+
+	changes, err := self.Import(ctx, "apps", strings.NewReader(input), ExportYAML, ImportOptions{DryRun: true})
+
+	if err != nil || len(changes) != 1 || changes[0].Action != ImportCreate || changes[0].Key.APIToken != "abc" {
+		t.Errorf("Import(ctx, %q, r, %q, DryRun) == %+v, %v, want one create and no writes", "apps", ExportYAML, changes, err)
+	}
+}
+
+func TestImport_InvalidItem(t *testing.T) {
+	var (
+		ctx          = context.TODO()
+		mockDynamoDB = mock_dynamodbiface.NewMockDynamoDBAPI(gomock.NewController(t))
+		self         = New(mockDynamoDB)
+		input        = `{"apiToken":"abc"}`
+	)
+	expectKeySchema(mockDynamoDB, "apps")
+
+	if _, err := self.Import(ctx, "apps", strings.NewReader(input), ExportJSONLines, ImportOptions{}); err == nil {
+		t.Errorf("Import(ctx, %q, %q) == nil, want an error for an untyped attribute", "apps", input)
+	}
+}
@@ -0,0 +1,167 @@
+package shareddiscovery
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// Region is a DynamoDB client for one replica of the global discovery tables.
+type Region struct {
+	// Name identifies the region in spans and metrics, such as "us-east-1".
+	Name     string
+	DynamoDB dynamodbiface.DynamoDBAPI
+}
+
+// Failover sends reads to an ordered list of regional replicas. Reads go to
+// the first region and move down the list when a region returns a retryable
+// error, times out or has an open circuit breaker. Set it on
+// SharedDiscovery.Failover to enable it; writes, Import and Watch keep using
+// DynamodbSvc.
+//
+// When Resilience is also set, each region is retried and circuit broken on
+// its own before the read fails over, so consider a low MaxAttempts.
+type Failover struct {
+	Regions []Region
+
+	// AttemptTimeout bounds the read against a single region so a hung
+	// region fails over instead of using up the caller's deadline. Zero
+	// bounds attempts by the caller's context only.
+	AttemptTimeout time.Duration
+
+	// HedgeAfter, when positive, sends the read to the next region as well
+	// if the current one has not answered within it. The first answer is
+	// used and the other requests are cancelled.
+	HedgeAfter time.Duration
+
+	// RetryableCodes lists the AWS error codes that fail over. Defaults to
+	// DefaultRetryableCodes.
+	RetryableCodes []string
+}
+
+type regionResult struct {
+	region string
+	output interface{}
+	err    error
+}
+
+// shouldFailOver reports whether err from a region is worth trying the next
+// region for. Errors caused by the caller's own context never are.
+func (failover *Failover) shouldFailOver(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if errors.Is(err, ErrCircuitOpen) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var awsErr awserr.Error
+	if !errors.As(err, &awsErr) {
+		return false
+	}
+	if awsErr.Code() == request.CanceledErrorCode {
+		// the SDK reports an expired AttemptTimeout as a cancelled request
+		return true
+	}
+	codes := failover.RetryableCodes
+	if codes == nil {
+		codes = DefaultRetryableCodes
+	}
+	for _, code := range codes {
+		if awsErr.Code() == code {
+			return true
+		}
+	}
+	return false
+}
+
+// read runs fn against DynamodbSvc, or against the Failover regions when
+// configured, and returns the output of the region that answered. The
+// serving region and number of regions tried are added to span and counted
+// in MetricRegion.
+func (service SharedDiscovery) read(ctx context.Context, span Span, operation, table string, fn func(ctx context.Context, db dynamodbiface.DynamoDBAPI) (interface{}, error)) (interface{}, error) {
+	failover := service.Failover
+	if failover == nil || len(failover.Regions) == 0 {
+		var output interface{}
+		err := service.Resilience.call(ctx, span, table, func() (err error) {
+			output, err = fn(ctx, service.DynamodbSvc)
+			return err
+		})
+		return output, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan regionResult, len(failover.Regions))
+	started, pending := 0, 0
+	start := func() {
+		region := failover.Regions[started]
+		started++
+		pending++
+		go func() {
+			results <- service.readRegion(ctx, region, table, fn)
+		}()
+	}
+
+	start()
+	for {
+		var hedge <-chan time.Time
+		timer := time.NewTimer(failover.HedgeAfter)
+		if failover.HedgeAfter > 0 && started < len(failover.Regions) {
+			hedge = timer.C
+		}
+
+		select {
+		case <-hedge:
+			span.AddField("failover.hedged", true)
+			start()
+			continue
+		case result := <-results:
+			timer.Stop()
+			pending--
+			if result.err != nil && failover.shouldFailOver(ctx, result.err) {
+				if started < len(failover.Regions) {
+					start()
+					continue
+				}
+				if pending > 0 {
+					continue
+				}
+			}
+
+			span.AddField("region", result.region)
+			span.AddField("failover.attempts", started)
+			service.metrics().Count(ctx, MetricRegion, 1, map[string]string{"operation": operation, "workspace": table, "region": result.region})
+			return result.output, result.err
+		}
+	}
+}
+
+// readRegion runs fn against region in a span of its own, as hedged reads
+// run concurrently.
+func (service SharedDiscovery) readRegion(ctx context.Context, region Region, table string, fn func(ctx context.Context, db dynamodbiface.DynamoDBAPI) (interface{}, error)) (result regionResult) {
+	ctx, span := service.startSpan(ctx, "readRegion")
+	defer func() { finishSpan(span, result.err) }()
+	span.AddField("region", region.Name)
+
+	if service.Failover.AttemptTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, service.Failover.AttemptTimeout)
+		defer cancel()
+	}
+
+	result.region = region.Name
+	result.err = service.Resilience.call(ctx, span, region.Name+"/"+table, func() (err error) {
+		result.output, err = fn(ctx, region.DynamoDB)
+		return err
+	})
+	if result.err != nil {
+		span.AddField("error.message", result.err.Error())
+	}
+	return result
+}
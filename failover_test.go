@@ -0,0 +1,170 @@
+package shareddiscovery
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/golang/mock/gomock"
+	"github.com/pgdevelopers/shareddiscovery/v2/mocks/mock_dynamodbiface"
+)
+
+var errUnavailable = awserr.New(dynamodb.ErrCodeInternalServerError, "region unavailable", nil)
+
+// newFailoverDiscovery returns a SharedDiscovery failing over from a primary
+// to a secondary mocked region.
+func newFailoverDiscovery(t *testing.T) (SharedDiscovery, *mock_dynamodbiface.MockDynamoDBAPI, *mock_dynamodbiface.MockDynamoDBAPI, *recordingMetrics, *recordingTracer) {
+	ctrl := gomock.NewController(t)
+	primary := mock_dynamodbiface.NewMockDynamoDBAPI(ctrl)
+	secondary := mock_dynamodbiface.NewMockDynamoDBAPI(ctrl)
+	metrics := &recordingMetrics{}
+	tracer := &recordingTracer{}
+
+	self := New(primary)
+	self.Metrics = metrics
+	self.Tracer = tracer
+	self.Failover = &Failover{Regions: []Region{
+		{Name: "us-east-1", DynamoDB: primary},
+		{Name: "us-west-2", DynamoDB: secondary},
+	}}
+	return self, primary, secondary, metrics, tracer
+}
+
+// blockUntilDone stands in for a region that never answers.
+func blockUntilDone(ctx aws.Context, _ *dynamodb.GetItemInput, _ ...request.Option) (*dynamodb.GetItemOutput, error) {
+	<-ctx.Done()
+	return nil, awserr.New(request.CanceledErrorCode, "request context canceled", ctx.Err())
+}
+
+var westConfig = &dynamodb.GetItemOutput{Item: map[string]*dynamodb.AttributeValue{"region": {S: aws.String("us-west-2")}}}
+
+func TestFailover_RetryableError(t *testing.T) {
+	var (
+		ctx                                       = context.TODO()
+		self, primary, secondary, metrics, tracer = newFailoverDiscovery(t)
+		query                                     = QueryInput{Workspace: "apps"}
+	)
+
+	primary.EXPECT().GetItemWithContext(gomock.Any(), gomock.Any()).Return(nil, errUnavailable)
+	secondary.EXPECT().GetItemWithContext(gomock.Any(), gomock.Any()).Return(westConfig, nil)
+
+	config, err := self.GetConfig(ctx, "apiToken", query)
+	if err != nil || config["region"] != "us-west-2" {
+		t.Fatalf("GetConfig(ctx, %q, %q) == %v, %v, want the us-west-2 config", "apiToken", query.Workspace, config, err)
+	}
+
+	span := tracer.find("GetConfig")
+	if span.fields["region"] != "us-west-2" || span.fields["failover.attempts"] != 2 {
+		t.Errorf("GetConfig span fields == %v, want region us-west-2 after 2 attempts", span.fields)
+	}
+	if got := metrics.sum(MetricRegion, map[string]string{"operation": "GetConfig", "workspace": "apps", "region": "us-west-2"}); got != 1 {
+		t.Errorf("%s{region: us-west-2} == %v, want 1", MetricRegion, got)
+	}
+	tracer.assertAllSentOnce(t)
+}
+
+func TestFailover_OtherErrors(t *testing.T) {
+	var (
+		ctx                         = context.TODO()
+		self, primary, _, _, tracer = newFailoverDiscovery(t)
+		query                       = QueryInput{Workspace: "apps"}
+		want                        = awserr.New(dynamodb.ErrCodeResourceNotFoundException, "no table", nil)
+	)
+
+	primary.EXPECT().GetItemWithContext(gomock.Any(), gomock.Any()).Return(nil, want)
+
+	if _, err := self.GetConfig(ctx, "apiToken", query); err != want {
+		t.Errorf("GetConfig(ctx, %q, %q) == %v, want %v", "apiToken", query.Workspace, err, want)
+	}
+	if got := tracer.find("GetConfig").fields["region"]; got != "us-east-1" {
+		t.Errorf("GetConfig span region == %v, want us-east-1", got)
+	}
+}
+
+func TestFailover_AllRegionsFail(t *testing.T) {
+	var (
+		ctx                            = context.TODO()
+		self, primary, secondary, _, _ = newFailoverDiscovery(t)
+		query                          = QueryInput{Workspace: "apps"}
+	)
+
+	primary.EXPECT().GetItemWithContext(gomock.Any(), gomock.Any()).Return(nil, errUnavailable)
+	secondary.EXPECT().GetItemWithContext(gomock.Any(), gomock.Any()).Return(nil, errUnavailable)
+
+	if _, err := self.GetConfig(ctx, "apiToken", query); err != errUnavailable {
+		t.Errorf("GetConfig(ctx, %q, %q) == %v, want %v", "apiToken", query.Workspace, err, errUnavailable)
+	}
+}
+
+func TestFailover_AttemptTimeout(t *testing.T) {
+	var (
+		ctx                            = context.TODO()
+		self, primary, secondary, _, _ = newFailoverDiscovery(t)
+		query                          = QueryInput{Workspace: "apps"}
+	)
+	self.Failover.AttemptTimeout = 10 * time.Millisecond
+
+	primary.EXPECT().GetItemWithContext(gomock.Any(), gomock.Any()).DoAndReturn(blockUntilDone)
+	secondary.EXPECT().GetItemWithContext(gomock.Any(), gomock.Any()).Return(westConfig, nil)
+
+	if config, err := self.GetConfig(ctx, "apiToken", query); err != nil || config["region"] != "us-west-2" {
+		t.Errorf("GetConfig(ctx, %q, %q) == %v, %v, want the us-west-2 config", "apiToken", query.Workspace, config, err)
+	}
+}
+
+func TestFailover_Hedge(t *testing.T) {
+	var (
+		ctx                                 = context.TODO()
+		self, primary, secondary, _, tracer = newFailoverDiscovery(t)
+		query                               = QueryInput{Workspace: "apps"}
+	)
+	self.Failover.HedgeAfter = 10 * time.Millisecond
+
+	primary.EXPECT().GetItemWithContext(gomock.Any(), gomock.Any()).DoAndReturn(blockUntilDone)
+	secondary.EXPECT().GetItemWithContext(gomock.Any(), gomock.Any()).Return(westConfig, nil)
+
+	if config, err := self.GetConfig(ctx, "apiToken", query); err != nil || config["region"] != "us-west-2" {
+		t.Errorf("GetConfig(ctx, %q, %q) == %v, %v, want the us-west-2 config", "apiToken", query.Workspace, config, err)
+	}
+	if got := tracer.find("GetConfig").fields["failover.hedged"]; got != true {
+		t.Errorf("GetConfig span failover.hedged == %v, want true", got)
+	}
+}
+
+func TestFailover_CallerCanceled(t *testing.T) {
+	var (
+		ctx, cancel            = context.WithTimeout(context.TODO(), 10*time.Millisecond)
+		self, primary, _, _, _ = newFailoverDiscovery(t)
+		query                  = QueryInput{Workspace: "apps"}
+	)
+	defer cancel()
+
+	primary.EXPECT().GetItemWithContext(gomock.Any(), gomock.Any()).DoAndReturn(blockUntilDone)
+
+	_, err := self.GetConfig(ctx, "apiToken", query)
+	if awsErr, ok := err.(awserr.Error); !ok || awsErr.Code() != request.CanceledErrorCode {
+		t.Errorf("GetConfig(expired ctx, %q, %q) == %v, want %s", "apiToken", query.Workspace, err, request.CanceledErrorCode)
+	}
+}
+
+func TestFailover_CircuitOpen(t *testing.T) {
+	var (
+		ctx                            = context.TODO()
+		self, primary, secondary, _, _ = newFailoverDiscovery(t)
+		query                          = QueryInput{Workspace: "apps"}
+	)
+	self.Resilience = NewResilience(ResiliencePolicy{MaxAttempts: 1, FailureThreshold: 1, OpenDuration: time.Minute})
+
+	primary.EXPECT().GetItemWithContext(gomock.Any(), gomock.Any()).Return(nil, errUnavailable)
+	secondary.EXPECT().GetItemWithContext(gomock.Any(), gomock.Any()).Return(westConfig, nil).Times(2)
+
+	for i := 0; i < 2; i++ {
+		if _, err := self.GetConfig(ctx, "apiToken", query); err != nil {
+			t.Errorf("GetConfig(ctx, %q, %q) #%d == %v, want nil", "apiToken", query.Workspace, i, err)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package shareddiscovery
+
+import "sort"
+
+// CountryFallback lets GetConfig serve a country without a row of its own
+// from the row of a region group, such as AT → DACH → EU → default. Groups
+// and the default are rows like any other, keyed by their name as the
+// countryCode. Configs served from a fallback are cached under the requested
+// country and aliased to the row served, so Watch evicts them when that row
+// changes. A Cache that is not an AliasCache keeps them until they expire.
+type CountryFallback struct {
+	// Groups defines region groups by their members, which are countries or
+	// other groups, such as "DACH": {"DE", "AT", "CH"} and "EU": {"DACH", "FR"}.
+	// A country falls back to the group containing it, then to the group
+	// containing that group and so on. When several groups contain the same
+	// member, the first by name is used.
+	Groups map[string][]string
+
+	// Chains overrides the groups of a country with the countries and
+	// groups to try after its own row, such as "AT": {"DE", "EU"}.
+	Chains map[string][]string
+
+	// Default is the countryCode of the row served when nothing else in the
+	// chain has one. Leave it empty to not fall back past the groups.
+	Default string
+}
+
+// Chain returns the countries GetConfig tries for country, most specific
+// first and starting with country itself.
+func (fallback CountryFallback) Chain(country string) []string {
+	chain := []string{country}
+	seen := map[string]bool{country: true}
+	add := func(name string) {
+		if name != "" && !seen[name] {
+			seen[name] = true
+			chain = append(chain, name)
+		}
+	}
+
+	if explicit, ok := fallback.Chains[country]; ok {
+		for _, name := range explicit {
+			add(name)
+		}
+	} else {
+		for name := fallback.group(country); name != "" && !seen[name]; name = fallback.group(name) {
+			add(name)
+		}
+	}
+	add(fallback.Default)
+	return chain
+}
+
+// group returns the first group by name that contains member.
+func (fallback CountryFallback) group(member string) string {
+	for _, name := range fallback.groupNames() {
+		for _, candidate := range fallback.Groups[name] {
+			if candidate == member {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// groupNames returns the names of the groups in order.
+func (fallback CountryFallback) groupNames() []string {
+	names := make([]string, 0, len(fallback.Groups))
+	for name := range fallback.Groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// countryChain returns the countries to try for query, which is just the
+// requested one unless the workspace has a CountryFallback.
+func (service SharedDiscovery) countryChain(query QueryInput) []string {
+	fallback, ok := service.CountryFallbacks[query.Workspace]
+	if !ok || query.Country == "" {
+		return []string{query.Country}
+	}
+	return fallback.Chain(query.Country)
+}
+
+// servedCountry returns the countryCode of a cached config, which is the row
+// it was read from, or requested for configs without one.
+func servedCountry(config map[string]interface{}, requested string) string {
+	if country, ok := config["countryCode"].(string); ok {
+		return country
+	}
+	return requested
+}
@@ -0,0 +1,73 @@
+package shareddiscovery
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/pgdevelopers/shareddiscovery/v2/discoverytest"
+)
+
+var dachFallback = CountryFallback{
+	Groups:  map[string][]string{"DACH": {"DE", "AT", "CH"}, "EU": {"DACH", "FR"}},
+	Chains:  map[string][]string{"LI": {"CH", "DACH"}},
+	Default: "default",
+}
+
+func TestCountryFallback_Chain(t *testing.T) {
+	for country, want := range map[string][]string{
+		"AT":      {"AT", "DACH", "EU", "default"},
+		"FR":      {"FR", "EU", "default"},
+		"LI":      {"LI", "CH", "DACH", "default"},
+		"US":      {"US", "default"},
+		"default": {"default"},
+	} {
+		if got := dachFallback.Chain(country); !reflect.DeepEqual(got, want) {
+			t.Errorf("Chain(%q) == %v, want %v", country, got, want)
+		}
+	}
+
+	cycle := CountryFallback{Groups: map[string][]string{"A": {"B", "AT"}, "B": {"A"}}}
+	if got, want := cycle.Chain("AT"), []string{"AT", "A", "B"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Chain(%q) with cyclic groups == %v, want %v", "AT", got, want)
+	}
+}
+
+func TestGetConfig_CountryFallback(t *testing.T) {
+	var (
+		ctx = context.TODO()
+		db  = discoverytest.NewDynamoDB()
+	)
+	for _, country := range []string{"DE", "DACH", "default"} {
+		db.Seed(t, WorkspaceTable.CreateTableInput("apps"), map[string]string{"apiToken": "abc", "countryCode": country, "name": "app " + country})
+	}
+	self := New(db)
+	self.Cache = NewMemoryCache(0)
+	self.CountryFallbacks = map[string]CountryFallback{"apps": dachFallback}
+
+	for _, test := range []struct {
+		country, served string
+	}{
+		{"DE", "DE"},
+		{"AT", "DACH"},
+		{"AT", "DACH"}, // from the Cache
+		{"US", "default"},
+	} {
+		var served string
+		config, err := self.GetConfig(ctx, "abc", QueryInput{Workspace: "apps", Country: test.country}, WithServedCountry(&served))
+		if err != nil || config["name"] != "app "+test.served || served != test.served {
+			t.Errorf("GetConfig(ctx, %q, %q) == %v, %v served %q, want the %s row", "abc", test.country, config, err, served, test.served)
+		}
+	}
+
+	// invalidating the DACH row, as Watch does when it changes, evicts AT
+	self.Cache.Invalidate(ConfigKey{Workspace: "apps", APIToken: "abc", Country: "DACH"})
+	if _, ok := self.Cache.Get(ConfigKey{Workspace: "apps", APIToken: "abc", Country: "AT"}); ok {
+		t.Errorf("Cache.Get(AT) after invalidating DACH found the stale config")
+	}
+
+	delete(self.CountryFallbacks, "apps")
+	if config, err := self.GetConfig(ctx, "abc", QueryInput{Workspace: "apps", Country: "US"}, WithoutCache()); config != nil || err != nil {
+		t.Errorf("GetConfig(ctx, %q, %q) without fallbacks == %v, %v, want nil, nil", "abc", "US", config, err)
+	}
+}
@@ -0,0 +1,277 @@
+package shareddiscovery
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// FlagsField is the config field holding flag definitions, keyed by flag.
+const FlagsField = "flags"
+
+// Flag is the definition of a feature flag. Boolean flags can leave
+// Variants empty to get an "on" variant that is true and an "off" variant
+// that is false. Evaluation goes through the checks below in order and
+// stops at the first that applies.
+type Flag struct {
+	// Enabled is false to serve OffVariant to everyone.
+	Enabled bool `json:"enabled"`
+
+	// Variants are the values the flag can take, by name.
+	Variants map[string]interface{} `json:"variants,omitempty"`
+
+	// OffVariant is served when the flag is disabled or the subject is
+	// denied. It defaults to "off".
+	OffVariant string `json:"offVariant,omitempty"`
+
+	// Deny lists subjects that are always served OffVariant.
+	Deny []string `json:"deny,omitempty"`
+
+	// Allow lists, by variant, subjects that are always served that variant.
+	Allow map[string][]string `json:"allow,omitempty"`
+
+	// Rollout serves variants to percentages of subjects. A subject's bucket
+	// comes from hashing Salt and its ID, so it keeps its variant between
+	// evaluations and growing the first percentage only adds subjects.
+	Rollout []FlagWeight `json:"rollout,omitempty"`
+
+	// Salt reshuffles the rollout buckets when changed. It defaults to the
+	// flag's key.
+	Salt string `json:"salt,omitempty"`
+
+	// Default is served to everyone else. It defaults to OffVariant.
+	Default string `json:"default,omitempty"`
+}
+
+// FlagWeight is the percentage of subjects, from 0 to 100, served Variant.
+type FlagWeight struct {
+	Variant string  `json:"variant"`
+	Percent float64 `json:"percent"`
+}
+
+// FlagReason is why a variant was chosen.
+type FlagReason string
+
+// The reasons a FlagEvaluation can have.
+const (
+	FlagDisabled FlagReason = "disabled"
+	FlagDenied   FlagReason = "denied"
+	FlagAllowed  FlagReason = "allowed"
+	FlagRollout  FlagReason = "rollout"
+	FlagDefault  FlagReason = "default"
+)
+
+// FlagEvaluation is the variant of a flag served to a subject. Err is set,
+// and the other fields but Key left empty, when the flag's definition is
+// invalid.
+type FlagEvaluation struct {
+	Key     string      `json:"key"`
+	Variant string      `json:"variant"`
+	Value   interface{} `json:"value"`
+	Reason  FlagReason  `json:"reason"`
+	Err     error       `json:"-"`
+}
+
+// rolloutBuckets is how finely Rollout percentages are split.
+const rolloutBuckets = 10000
+
+// Evaluate returns the variant of the flag key served to subject, a stable
+// device or user ID. Without a subject only Default is served after the
+// Enabled check.
+func (flag Flag) Evaluate(key, subject string) (FlagEvaluation, error) {
+	flag = flag.withDefaults()
+	if err := flag.validate(key); err != nil {
+		return FlagEvaluation{}, err
+	}
+	serve := func(variant string, reason FlagReason) (FlagEvaluation, error) {
+		return FlagEvaluation{Key: key, Variant: variant, Value: flag.Variants[variant], Reason: reason}, nil
+	}
+
+	if !flag.Enabled {
+		return serve(flag.OffVariant, FlagDisabled)
+	}
+	if subject == "" {
+		return serve(flag.Default, FlagDefault)
+	}
+	for _, denied := range flag.Deny {
+		if denied == subject {
+			return serve(flag.OffVariant, FlagDenied)
+		}
+	}
+	for _, variant := range sortedVariants(flag.Allow) {
+		for _, allowed := range flag.Allow[variant] {
+			if allowed == subject {
+				return serve(variant, FlagAllowed)
+			}
+		}
+	}
+
+	bucket := flag.bucket(key, subject)
+	var upTo float64
+	for _, weight := range flag.Rollout {
+		upTo += weight.Percent * rolloutBuckets / 100
+		if float64(bucket) < upTo {
+			return serve(weight.Variant, FlagRollout)
+		}
+	}
+	return serve(flag.Default, FlagDefault)
+}
+
+func (flag Flag) withDefaults() Flag {
+	if len(flag.Variants) == 0 {
+		flag.Variants = map[string]interface{}{"on": true, "off": false}
+	}
+	if flag.OffVariant == "" {
+		flag.OffVariant = "off"
+	}
+	if flag.Default == "" {
+		flag.Default = flag.OffVariant
+	}
+	return flag
+}
+
+func (flag Flag) validate(key string) error {
+	variants := []string{flag.OffVariant, flag.Default}
+	for variant := range flag.Allow {
+		variants = append(variants, variant)
+	}
+	var total float64
+	for _, weight := range flag.Rollout {
+		if weight.Percent < 0 {
+			return fmt.Errorf("flag %s: negative percentage for %q", key, weight.Variant)
+		}
+		total += weight.Percent
+		variants = append(variants, weight.Variant)
+	}
+	if total > 100 {
+		return fmt.Errorf("flag %s: rollout adds up to %v%%", key, total)
+	}
+	for _, variant := range variants {
+		if _, ok := flag.Variants[variant]; !ok {
+			return fmt.Errorf("flag %s: unknown variant %q", key, variant)
+		}
+	}
+	return nil
+}
+
+// bucket returns where subject falls in [0, rolloutBuckets).
+func (flag Flag) bucket(key, subject string) uint64 {
+	salt := flag.Salt
+	if salt == "" {
+		salt = key
+	}
+	sum := sha256.Sum256([]byte(salt + "/" + subject))
+	return binary.BigEndian.Uint64(sum[:8]) % rolloutBuckets
+}
+
+func sortedVariants(allow map[string][]string) []string {
+	variants := make([]string, 0, len(allow))
+	for variant := range allow {
+		variants = append(variants, variant)
+	}
+	sort.Strings(variants)
+	return variants
+}
+
+// EvaluateFlags evaluates every flag defined in the FlagsField of the
+// config GetConfig returns for apiToken and query, for subject. opts are
+// passed to GetConfig, so options narrowing the config such as WithFields
+// should not be used. Each flag is evaluated on its own, so an invalid
+// definition only sets the Err of its own evaluation.
+func (service SharedDiscovery) EvaluateFlags(ctx context.Context, apiToken string, query QueryInput, subject string, opts ...CallOption) (evaluations map[string]FlagEvaluation, err error) {
+	ctx, flagsSpan := service.startSpan(ctx, "EvaluateFlags")
+	defer func(start time.Time) {
+		service.observe(ctx, "EvaluateFlags", query.Workspace, start, err)
+		finishSpan(flagsSpan, err)
+	}(time.Now())
+	flagsSpan.AddField("workspace", query.Workspace)
+
+	flags, err := service.flags(ctx, apiToken, query, opts...)
+	if err != nil {
+		flagsSpan.AddField("error.message", err.Error())
+		return nil, err
+	}
+
+	evaluations = make(map[string]FlagEvaluation, len(flags))
+	failed := 0
+	for key, definition := range flags {
+		evaluation, err := evaluateFlag(key, definition, subject)
+		if err != nil {
+			failed++
+			flagsSpan.AddField("error.message", err.Error())
+			evaluation = FlagEvaluation{Key: key, Err: err}
+		}
+		evaluations[key] = evaluation
+	}
+	flagsSpan.AddField("flags.count", len(evaluations))
+	flagsSpan.AddField("flags.failed", failed)
+	return evaluations, nil
+}
+
+// EvaluateFlag evaluates the flag key for subject like EvaluateFlags, and
+// returns ErrNoResults when the config does not define it.
+func (service SharedDiscovery) EvaluateFlag(ctx context.Context, apiToken string, query QueryInput, key, subject string, opts ...CallOption) (evaluation FlagEvaluation, err error) {
+	ctx, flagSpan := service.startSpan(ctx, "EvaluateFlag")
+	defer func(start time.Time) {
+		service.observe(ctx, "EvaluateFlag", query.Workspace, start, err)
+		finishSpan(flagSpan, err)
+	}(time.Now())
+	flagSpan.AddField("workspace", query.Workspace)
+	flagSpan.AddField("flag.key", key)
+
+	flags, err := service.flags(ctx, apiToken, query, opts...)
+	if err != nil {
+		flagSpan.AddField("error.message", err.Error())
+		return FlagEvaluation{}, err
+	}
+	definition, ok := flags[key]
+	if !ok {
+		err = fmt.Errorf("%w: flag %s", ErrNoResults, key)
+		flagSpan.AddField("error.message", err.Error())
+		return FlagEvaluation{}, err
+	}
+	if evaluation, err = evaluateFlag(key, definition, subject); err != nil {
+		flagSpan.AddField("error.message", err.Error())
+		return FlagEvaluation{}, err
+	}
+	flagSpan.AddField("flag.variant", evaluation.Variant)
+	flagSpan.AddField("flag.reason", string(evaluation.Reason))
+	return evaluation, nil
+}
+
+// evaluateFlag decodes the definition of the flag key and evaluates it for
+// subject. Definitions are decoded one at a time so a malformed one only
+// affects its own flag.
+func evaluateFlag(key string, definition json.RawMessage, subject string) (FlagEvaluation, error) {
+	var flag Flag
+	if err := json.Unmarshal(definition, &flag); err != nil {
+		return FlagEvaluation{}, fmt.Errorf("decoding flag %s: %w", key, err)
+	}
+	return flag.Evaluate(key, subject)
+}
+
+// flags reads the flag definitions of a config. The whole config is read so
+// it is served from and kept in the Cache.
+func (service SharedDiscovery) flags(ctx context.Context, apiToken string, query QueryInput, opts ...CallOption) (map[string]json.RawMessage, error) {
+	config, err := service.GetConfig(ctx, apiToken, query, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNoResults, query.Workspace)
+	}
+	// round trip through JSON to decode the definitions like any client would
+	data, err := json.Marshal(config[FlagsField])
+	if err != nil {
+		return nil, err
+	}
+	var flags map[string]json.RawMessage
+	if err := json.Unmarshal(data, &flags); err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", FlagsField, err)
+	}
+	return flags, nil
+}
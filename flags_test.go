@@ -0,0 +1,122 @@
+package shareddiscovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/pgdevelopers/shareddiscovery/v2/discoverytest"
+)
+
+func TestFlag_Evaluate(t *testing.T) {
+	flag := Flag{
+		Enabled:  true,
+		Variants: map[string]interface{}{"control": "blue", "green": "green", "red": "red", "off": "none"},
+		Deny:     []string{"banned"},
+		Allow:    map[string][]string{"red": {"tester", "banned"}},
+		Rollout:  []FlagWeight{{Variant: "green", Percent: 100}},
+		Default:  "control",
+	}
+
+	tests := []struct {
+		name    string
+		flag    Flag
+		subject string
+		want    FlagEvaluation
+	}{
+		{"denied wins over allowed", flag, "banned", FlagEvaluation{Key: "color", Variant: "off", Value: "none", Reason: FlagDenied}},
+		{"allowed", flag, "tester", FlagEvaluation{Key: "color", Variant: "red", Value: "red", Reason: FlagAllowed}},
+		{"rollout", flag, "device", FlagEvaluation{Key: "color", Variant: "green", Value: "green", Reason: FlagRollout}},
+		{"no subject", flag, "", FlagEvaluation{Key: "color", Variant: "control", Value: "blue", Reason: FlagDefault}},
+		{"disabled", Flag{Variants: flag.Variants}, "tester", FlagEvaluation{Key: "color", Variant: "off", Value: "none", Reason: FlagDisabled}},
+		{"boolean", Flag{Enabled: true}, "device", FlagEvaluation{Key: "color", Variant: "off", Value: false, Reason: FlagDefault}},
+	}
+	for _, test := range tests {
+		if got, err := test.flag.Evaluate("color", test.subject); err != nil || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: Evaluate(%q, %q) == %+v, %v, want %+v", test.name, "color", test.subject, got, err, test.want)
+		}
+	}
+}
+
+func TestFlag_Evaluate_Rollout(t *testing.T) {
+	flag := Flag{Enabled: true, Rollout: []FlagWeight{{Variant: "on", Percent: 25}}}
+	grown := Flag{Enabled: true, Rollout: []FlagWeight{{Variant: "on", Percent: 50}}}
+
+	on := 0
+	for i := 0; i < 2000; i++ {
+		subject := fmt.Sprintf("device-%d", i)
+		first, _ := flag.Evaluate("beta", subject)
+		again, _ := flag.Evaluate("beta", subject)
+		if first != again {
+			t.Fatalf("Evaluate(%q, %q) == %+v then %+v, want the same variant", "beta", subject, first, again)
+		}
+		if first.Value == true {
+			on++
+			if after, _ := grown.Evaluate("beta", subject); after.Value != true {
+				t.Errorf("Evaluate(%q, %q) after growing the rollout == %+v, want it kept on", "beta", subject, after)
+			}
+		}
+	}
+	if on < 400 || on > 600 {
+		t.Errorf("a 25%% rollout served %d of 2000 subjects, want about 500", on)
+	}
+}
+
+func TestFlag_Evaluate_Invalid(t *testing.T) {
+	for _, flag := range []Flag{
+		{Enabled: true, Default: "missing"},
+		{Enabled: true, Allow: map[string][]string{"missing": {"tester"}}},
+		{Enabled: true, Rollout: []FlagWeight{{Variant: "on", Percent: 60}, {Variant: "off", Percent: 60}}},
+		{Enabled: true, Rollout: []FlagWeight{{Variant: "on", Percent: -1}}},
+	} {
+		if _, err := flag.Evaluate("beta", "device"); err == nil {
+			t.Errorf("Evaluate(%+v) == nil error, want the definition rejected", flag)
+		}
+	}
+}
+
+func TestEvaluateFlags(t *testing.T) {
+	var (
+		ctx   = context.TODO()
+		db    = discoverytest.NewDynamoDB()
+		query = QueryInput{Workspace: "apps"}
+	)
+	db.Seed(t, TableDefinition{HashKey: "apiToken"}.CreateTableInput("apps"), map[string]interface{}{
+		"apiToken": "abc",
+		"flags": map[string]interface{}{
+			"beta":   map[string]interface{}{"enabled": true, "allow": map[string][]string{"on": {"tester"}}},
+			"theme":  map[string]interface{}{"enabled": false, "variants": map[string]string{"off": "light", "dark": "dark"}},
+			"broken": map[string]interface{}{"enabled": true, "default": "missing"},
+			"typo":   map[string]interface{}{"enabled": "yes"},
+		},
+	})
+	self := New(db)
+
+	evaluations, err := self.EvaluateFlags(ctx, "abc", query, "tester")
+	// an invalid definition only fails its own flag
+	for _, key := range []string{"broken", "typo"} {
+		if evaluations[key].Err == nil || evaluations[key].Key != key {
+			t.Errorf("EvaluateFlags(ctx, %q, %q, %q)[%q] == %+v, want an error", "abc", query.Workspace, "tester", key, evaluations[key])
+		}
+		delete(evaluations, key)
+	}
+	want := map[string]FlagEvaluation{
+		"beta":  {Key: "beta", Variant: "on", Value: true, Reason: FlagAllowed},
+		"theme": {Key: "theme", Variant: "off", Value: "light", Reason: FlagDisabled},
+	}
+	if err != nil || !reflect.DeepEqual(evaluations, want) {
+		t.Errorf("EvaluateFlags(ctx, %q, %q, %q) == %+v, %v, want %+v", "abc", query.Workspace, "tester", evaluations, err, want)
+	}
+
+	if evaluation, err := self.EvaluateFlag(ctx, "abc", query, "beta", "someone"); err != nil || evaluation.Value != false || evaluation.Reason != FlagDefault {
+		t.Errorf("EvaluateFlag(ctx, %q, %q, %q) == %+v, %v, want off by default", "abc", "beta", "someone", evaluation, err)
+	}
+	if _, err := self.EvaluateFlag(ctx, "abc", query, "missing", "someone"); !errors.Is(err, ErrNoResults) {
+		t.Errorf("EvaluateFlag(ctx, %q, %q, %q) == %v, want %v", "abc", "missing", "someone", err, ErrNoResults)
+	}
+	if _, err := self.EvaluateFlags(ctx, "missing", query, "someone"); !errors.Is(err, ErrNoResults) {
+		t.Errorf("EvaluateFlags(ctx, %q, %q, %q) == %v, want %v", "missing", query.Workspace, "someone", err, ErrNoResults)
+	}
+}
@@ -1,11 +1,16 @@
-module github.com/pgdevelopers/shareddiscovery
+module github.com/pgdevelopers/shareddiscovery/v2
 
 go 1.17
 
 require (
+	github.com/aws/aws-lambda-go v1.34.1
 	github.com/aws/aws-sdk-go v1.40.59
 	github.com/golang/mock v1.6.0
 	github.com/honeycombio/beeline-go v1.2.0
+	go.opentelemetry.io/otel v1.7.0
+	go.opentelemetry.io/otel/metric v0.30.0
+	go.opentelemetry.io/otel/trace v1.7.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -19,7 +24,5 @@ require (
 	github.com/vmihailenco/msgpack/v5 v5.2.0 // indirect
 	github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
 	go.opentelemetry.io/contrib/propagators v0.21.0 // indirect
-	go.opentelemetry.io/otel v1.0.0-RC1 // indirect
-	go.opentelemetry.io/otel/trace v1.0.0-RC1 // indirect
 	gopkg.in/alexcesaro/statsd.v2 v2.0.0 // indirect
 )
@@ -0,0 +1,137 @@
+package shareddiscovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// ErrUnhealthy is returned by HealthCheck when any of its checks fails.
+var ErrUnhealthy = errors.New("unhealthy")
+
+// ErrNoHealthCheck is returned by the HealthCheck of a wrapper, such as
+// RateLimiter, when the IFace it wraps cannot report its health.
+var ErrNoHealthCheck = errors.New("health check not supported")
+
+// HealthReport is the outcome of HealthCheck. It encodes as JSON for
+// readiness endpoints.
+type HealthReport struct {
+	Healthy bool                `json:"healthy"`
+	Checks  []HealthCheckResult `json:"checks"`
+}
+
+// HealthCheckResult is the outcome of one check, such as "table:apps" or
+// "secrets". Problems describes what is wrong with a table that could be
+// described, and Error why a check could not be made.
+type HealthCheckResult struct {
+	Name     string   `json:"name"`
+	Healthy  bool     `json:"healthy"`
+	Status   string   `json:"status,omitempty"`
+	Problems []string `json:"problems,omitempty"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// HealthCheck verifies the service can serve requests: that discovery_app
+// and the Workspaces exist, are ACTIVE or UPDATING and have the key schema
+// and indexes the library queries, and that the secret provider of Secrets
+// is reachable. The checks run concurrently, and ErrUnhealthy is returned
+// along with the report when any fails, so it can back a readiness probe.
+func (service SharedDiscovery) HealthCheck(ctx context.Context) (report HealthReport, err error) {
+	ctx, healthSpan := service.startSpan(ctx, "HealthCheck")
+	defer func(start time.Time) {
+		service.observe(ctx, "HealthCheck", "", start, err)
+		finishSpan(healthSpan, err)
+	}(time.Now())
+
+	var checks []func(context.Context) HealthCheckResult
+	for _, table := range service.tables() {
+		table := table
+		checks = append(checks, func(ctx context.Context) HealthCheckResult {
+			return service.checkTable(ctx, table.name, table.definition)
+		})
+	}
+	if service.Secrets != nil {
+		checks = append(checks, service.checkSecrets)
+	}
+
+	report.Checks = make([]HealthCheckResult, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check func(context.Context) HealthCheckResult) {
+			defer wg.Done()
+			report.Checks[i] = check(ctx)
+		}(i, check)
+	}
+	wg.Wait()
+
+	var failed []string
+	for _, check := range report.Checks {
+		if !check.Healthy {
+			failed = append(failed, check.Name)
+		}
+	}
+	report.Healthy = len(failed) == 0
+	healthSpan.AddField("health.checks", len(report.Checks))
+	if !report.Healthy {
+		err = fmt.Errorf("%w: %s", ErrUnhealthy, strings.Join(failed, ", "))
+		healthSpan.AddField("error.message", err.Error())
+		return report, err
+	}
+	return report, nil
+}
+
+// checkTable describes table and compares it with definition.
+func (service SharedDiscovery) checkTable(ctx context.Context, table string, definition TableDefinition) HealthCheckResult {
+	result := HealthCheckResult{Name: "table:" + table}
+	output, err := service.DynamodbSvc.DescribeTableWithContext(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(table)})
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	description := output.Table
+	if description == nil {
+		result.Error = "table was not described"
+		return result
+	}
+	// an UPDATING table still serves reads, while capacity or indexes change
+	result.Status = aws.StringValue(description.TableStatus)
+	if result.Status != dynamodb.TableStatusActive && result.Status != dynamodb.TableStatusUpdating {
+		result.Problems = append(result.Problems, fmt.Sprintf("table is %s, want %s", result.Status, dynamodb.TableStatusActive))
+	}
+	result.Problems = append(result.Problems, compareKeys("table", definition.HashKey, definition.RangeKey, true, description.KeySchema)...)
+
+	indexes := map[string]*dynamodb.GlobalSecondaryIndexDescription{}
+	for _, index := range description.GlobalSecondaryIndexes {
+		indexes[aws.StringValue(index.IndexName)] = index
+	}
+	for _, index := range definition.Indexes {
+		found, ok := indexes[index.Name]
+		if !ok {
+			result.Problems = append(result.Problems, fmt.Sprintf("index %s is missing", index.Name))
+			continue
+		}
+		if status := aws.StringValue(found.IndexStatus); status != "" && status != dynamodb.IndexStatusActive && status != dynamodb.IndexStatusUpdating {
+			result.Problems = append(result.Problems, fmt.Sprintf("index %s is %s, want %s", index.Name, status, dynamodb.IndexStatusActive))
+		}
+		result.Problems = append(result.Problems, compareKeys("index "+index.Name, index.HashKey, index.RangeKey, false, found.KeySchema)...)
+	}
+	result.Healthy = len(result.Problems) == 0
+	return result
+}
+
+func (service SharedDiscovery) checkSecrets(ctx context.Context) HealthCheckResult {
+	result := HealthCheckResult{Name: "secrets"}
+	if err := service.Secrets.Ping(ctx); err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Healthy = true
+	return result
+}
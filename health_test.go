@@ -0,0 +1,116 @@
+package shareddiscovery
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/golang/mock/gomock"
+	"github.com/pgdevelopers/shareddiscovery/v2/discoverytest"
+	"github.com/pgdevelopers/shareddiscovery/v2/mocks/mock_dynamodbiface"
+	"github.com/pgdevelopers/shareddiscovery/v2/mocks/mock_secretsmanageriface"
+)
+
+func TestHealthCheck(t *testing.T) {
+	var (
+		ctx                = context.TODO()
+		db                 = discoverytest.NewDynamoDB()
+		self               = New(db)
+		mockSecretsManager = mock_secretsmanageriface.NewMockSecretsManagerAPI(gomock.NewController(t))
+	)
+	db.Seed(t, DiscoveryAppTable.CreateTableInput("discovery_app"))
+	db.Seed(t, &dynamodb.CreateTableInput{TableName: aws.String("apps"), KeySchema: keySchemaOf("apiToken", "")})
+	self.Workspaces = []string{"apps"}
+	self.Secrets = NewSecrets(SecretsManagerProvider{Client: mockSecretsManager}, 0)
+
+	mockSecretsManager.
+		EXPECT().
+		ListSecretsWithContext(gomock.Any(), &secretsmanager.ListSecretsInput{MaxResults: aws.Int64(1)}).
+		Return(&secretsmanager.ListSecretsOutput{}, nil)
+
+	report, err := self.HealthCheck(ctx)
+	want := HealthReport{Healthy: true, Checks: []HealthCheckResult{
+		{Name: "table:discovery_app", Healthy: true, Status: dynamodb.TableStatusActive},
+		{Name: "table:apps", Healthy: true, Status: dynamodb.TableStatusActive},
+		{Name: "secrets", Healthy: true},
+	}}
+	if err != nil || !reflect.DeepEqual(report, want) {
+		t.Errorf("HealthCheck(ctx) == %+v, %v, want %+v", report, err, want)
+	}
+}
+
+func TestHealthCheck_Unhealthy(t *testing.T) {
+	var (
+		ctx                = context.TODO()
+		db                 = discoverytest.NewDynamoDB()
+		self               = New(db)
+		mockSecretsManager = mock_secretsmanageriface.NewMockSecretsManagerAPI(gomock.NewController(t))
+	)
+	// no appNameCountryIndex, and apps is keyed by the wrong attribute
+	db.Seed(t, &dynamodb.CreateTableInput{TableName: aws.String("discovery_app"), KeySchema: keySchemaOf("apiToken", "countryCode")})
+	db.Seed(t, &dynamodb.CreateTableInput{TableName: aws.String("apps"), KeySchema: keySchemaOf("token", "country")})
+	self.Workspaces = []string{"apps", "aps"}
+	self.Secrets = NewSecrets(SecretsManagerProvider{Client: mockSecretsManager}, 0)
+
+	mockSecretsManager.
+		EXPECT().
+		ListSecretsWithContext(gomock.Any(), gomock.Any()).
+		Return(nil, awserr.New(secretsmanager.ErrCodeInternalServiceError, "unavailable", nil))
+
+	report, err := self.HealthCheck(ctx)
+	if !errors.Is(err, ErrUnhealthy) || report.Healthy {
+		t.Fatalf("HealthCheck(ctx) == %v, %v, want ErrUnhealthy", report.Healthy, err)
+	}
+	if class := ErrorClass(err); class != "unhealthy" {
+		t.Errorf("ErrorClass(%v) == %q, want %q", err, class, "unhealthy")
+	}
+	wantProblems := map[string][]string{
+		"table:discovery_app": {"index appNameCountryIndex is missing"},
+		"table:apps":          {`table hash key is "token", want "apiToken"`, `table range key is "country", want "countryCode"`},
+		"table:aps":           nil,
+		"secrets":             nil,
+	}
+	for _, check := range report.Checks {
+		want, ok := wantProblems[check.Name]
+		if !ok || check.Healthy || !reflect.DeepEqual(check.Problems, want) {
+			t.Errorf("check %s == %+v, want unhealthy with problems %q", check.Name, check, want)
+		}
+		if want == nil && check.Error == "" {
+			t.Errorf("check %s has no error, want why it could not be made", check.Name)
+		}
+	}
+	if len(report.Checks) != len(wantProblems) {
+		t.Errorf("HealthCheck(ctx) made %d checks, want %d", len(report.Checks), len(wantProblems))
+	}
+}
+
+func TestCheckTable_Status(t *testing.T) {
+	mockDynamoDB := mock_dynamodbiface.NewMockDynamoDBAPI(gomock.NewController(t))
+	self := New(mockDynamoDB)
+	definition := TableDefinition{HashKey: "apiToken"}
+	tests := []struct {
+		name    string
+		table   *dynamodb.TableDescription
+		healthy bool
+	}{
+		{"updating", &dynamodb.TableDescription{TableStatus: aws.String(dynamodb.TableStatusUpdating), KeySchema: keySchemaOf("apiToken", "")}, true},
+		{"creating", &dynamodb.TableDescription{TableStatus: aws.String(dynamodb.TableStatusCreating), KeySchema: keySchemaOf("apiToken", "")}, false},
+		{"not described", nil, false},
+	}
+
+	for _, test := range tests {
+		mockDynamoDB.
+			EXPECT().
+			DescribeTableWithContext(gomock.Any(), gomock.Any()).
+			Return(&dynamodb.DescribeTableOutput{Table: test.table}, nil)
+
+		if result := self.checkTable(context.TODO(), "apps", definition); result.Healthy != test.healthy {
+			t.Errorf("%s: checkTable(ctx, %q) == %+v, want healthy %v", test.name, "apps", result, test.healthy)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package shareddiscovery
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ValidationError reports a query value that cannot be used, such as a
+// country that is not an ISO 3166-1 code. Check for it with errors.As.
+type ValidationError struct {
+	Field  string
+	Value  string
+	Reason string
+}
+
+func (err *ValidationError) Error() string {
+	return fmt.Sprintf("invalid %s %q: %s", err.Field, err.Value, err.Reason)
+}
+
+// NormalizeCountry returns the upper case ISO 3166-1 alpha-2 code of
+// country, which may be an alpha-2 or alpha-3 code in any case or a BCP 47
+// locale with a region, such as "en-US" or "de_AT". An empty country stays
+// empty. Anything else is a *ValidationError.
+func NormalizeCountry(country string) (string, error) {
+	value := strings.TrimSpace(country)
+	if value == "" {
+		return "", nil
+	}
+	invalid := func(reason string) (string, error) {
+		return "", &ValidationError{Field: "countryCode", Value: country, Reason: reason}
+	}
+
+	if strings.ContainsAny(value, "-_") {
+		region, ok := localeRegion(value)
+		if !ok {
+			return invalid("locale has no country")
+		}
+		value = region
+	}
+
+	value = strings.ToUpper(value)
+	switch len(value) {
+	case 2:
+		if alpha2[value] {
+			return value, nil
+		}
+	case 3:
+		if code, ok := iso3166[value]; ok {
+			return code, nil
+		}
+	}
+	return invalid("not an ISO 3166-1 country code")
+}
+
+// localeRegion returns the region subtag of a BCP 47 language tag, which
+// follows the language and any extended language and script subtags.
+func localeRegion(tag string) (string, bool) {
+	subtags := strings.FieldsFunc(tag, func(r rune) bool { return r == '-' || r == '_' })
+	if len(subtags) < 2 {
+		return "", false
+	}
+	for _, subtag := range subtags[1:] {
+		switch {
+		case len(subtag) == 2 && isLetters(subtag):
+			return subtag, true
+		case len(subtag) == 3 && isLetters(subtag), len(subtag) == 4 && isLetters(subtag):
+			// extended language or script
+			continue
+		default:
+			// numeric regions are not countries and variants or
+			// extensions come after the region
+			return "", false
+		}
+	}
+	return "", false
+}
+
+func isLetters(value string) bool {
+	for _, r := range value {
+		if (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeQuery returns query with its country normalized by
+// normalizeCountry.
+func (service SharedDiscovery) normalizeQuery(query QueryInput) (QueryInput, error) {
+	country, err := service.normalizeCountry(query.Workspace, query.Country)
+	if err != nil {
+		return query, err
+	}
+	query.Country = country
+	return query, nil
+}
+
+// normalizeKey returns key with its country normalized by normalizeCountry.
+func (service SharedDiscovery) normalizeKey(key ConfigKey) (ConfigKey, error) {
+	country, err := service.normalizeCountry(key.Workspace, key.Country)
+	if err != nil {
+		return key, err
+	}
+	key.Country = country
+	return key, nil
+}
+
+// normalizeCountry returns country as rows of workspace are keyed. The
+// ExtraCountries and the groups and default of the workspace's
+// CountryFallback match in any case and are returned as configured. Anything
+// else is normalized by NormalizeCountry.
+func (service SharedDiscovery) normalizeCountry(workspace, country string) (string, error) {
+	value := strings.TrimSpace(country)
+	names := service.ExtraCountries
+	if fallback, ok := service.CountryFallbacks[workspace]; ok {
+		names = append(append([]string{fallback.Default}, names...), fallback.groupNames()...)
+	}
+	for _, name := range names {
+		if name != "" && strings.EqualFold(name, value) {
+			return name, nil
+		}
+	}
+	return NormalizeCountry(country)
+}
@@ -0,0 +1,143 @@
+package shareddiscovery
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/golang/mock/gomock"
+	"github.com/pgdevelopers/shareddiscovery/v2/mocks/mock_dynamodbiface"
+)
+
+func TestNormalizeCountry(t *testing.T) {
+	for country, want := range map[string]string{
+		"":               "",
+		"US":             "US",
+		"us":             "US",
+		" gb ":           "GB",
+		"USA":            "US",
+		"deu":            "DE",
+		"en-US":          "US",
+		"de_AT":          "AT",
+		"zh-Hant-TW":     "TW",
+		"sr-Latn-rs":     "RS",
+		"zh-yue-HK":      "HK",
+		"en-GB-oxendict": "GB",
+	} {
+		if got, err := NormalizeCountry(country); got != want || err != nil {
+			t.Errorf("NormalizeCountry(%q) == %q, %v, want %q", country, got, err, want)
+		}
+	}
+}
+
+func TestNormalizeCountry_Invalid(t *testing.T) {
+	for _, country := range []string{"U", "XX", "UK", "USAA", "ZZZ", "en", "es-419", "en-x-US", "United States", "U1"} {
+		var validationErr *ValidationError
+		if got, err := NormalizeCountry(country); !errors.As(err, &validationErr) || validationErr.Value != country || got != "" {
+			t.Errorf("NormalizeCountry(%q) == %q, %v, want a ValidationError", country, got, err)
+		}
+	}
+}
+
+func TestGetConfig_NormalizesCountry(t *testing.T) {
+	var (
+		ctx          = context.TODO()
+		mockDynamoDB = mock_dynamodbiface.NewMockDynamoDBAPI(gomock.NewController(t))
+		self         = New(mockDynamoDB)
+	)
+	self.Cache = NewMemoryCache(0)
+	self.ExtraCountries = []string{"UK"}
+
+	mockDynamoDB.
+		EXPECT().
+		GetItemWithContext(gomock.Any(), &dynamodb.GetItemInput{
+			TableName: aws.String("apps"),
+			Key:       map[string]*dynamodb.AttributeValue{"apiToken": {S: aws.String("abc")}, "countryCode": {S: aws.String("US")}},
+		}).
+		Return(&dynamodb.GetItemOutput{Item: map[string]*dynamodb.AttributeValue{"name": {S: aws.String("app")}}}, nil)
+	mockDynamoDB.
+		EXPECT().
+		GetItemWithContext(gomock.Any(), &dynamodb.GetItemInput{
+			TableName: aws.String("apps"),
+			Key:       map[string]*dynamodb.AttributeValue{"apiToken": {S: aws.String("abc")}, "countryCode": {S: aws.String("UK")}},
+		}).
+		Return(&dynamodb.GetItemOutput{Item: map[string]*dynamodb.AttributeValue{"name": {S: aws.String("uk app")}}}, nil)
+
+	// one read, as every spelling shares the cache entry for US
+	for _, country := range []string{"us", "USA", "en-US"} {
+		if config, err := self.GetConfig(ctx, "abc", QueryInput{Workspace: "apps", Country: country}); err != nil || config["name"] != "app" {
+			t.Errorf("GetConfig(ctx, %q, %q) == %v, %v, want the US config", "abc", country, config, err)
+		}
+	}
+
+	// extra countries are read as configured, whatever the case sent
+	if config, err := self.GetConfig(ctx, "abc", QueryInput{Workspace: "apps", Country: "uk"}); err != nil || config["name"] != "uk app" {
+		t.Errorf("GetConfig(ctx, %q, %q) == %v, %v, want the UK config", "abc", "uk", config, err)
+	}
+
+	var validationErr *ValidationError
+	if _, err := self.GetConfig(ctx, "abc", QueryInput{Workspace: "apps", Country: "America"}); !errors.As(err, &validationErr) {
+		t.Errorf("GetConfig(ctx, %q, %q) == %v, want a ValidationError", "abc", "America", err)
+	}
+	if _, err := self.GetValidation(ctx, QueryInput{AppName: "sonos", Country: "America"}); !errors.As(err, &validationErr) {
+		t.Errorf("GetValidation(ctx, %q) == %v, want a ValidationError", "America", err)
+	}
+}
+
+func TestSharedDiscovery_NormalizeCountry(t *testing.T) {
+	self := New(nil)
+	self.ExtraCountries = []string{"UK", "EU"}
+	self.CountryFallbacks = map[string]CountryFallback{
+		"apps": {Groups: map[string][]string{"DACH": {"DE", "AT", "CH"}}, Default: "default"},
+	}
+
+	for country, want := range map[string]string{
+		"usa":     "US",
+		"uk":      "UK",
+		" EU ":    "EU",
+		"dach":    "DACH",
+		"DEFAULT": "default",
+	} {
+		if got, err := self.normalizeCountry("apps", country); got != want || err != nil {
+			t.Errorf("normalizeCountry(%q, %q) == %q, %v, want %q", "apps", country, got, err, want)
+		}
+	}
+
+	// groups and defaults only apply to the workspace they are configured for
+	for _, country := range []string{"dach", "default", "en-XX", "America"} {
+		var validationErr *ValidationError
+		if got, err := self.normalizeCountry("firmware", country); !errors.As(err, &validationErr) {
+			t.Errorf("normalizeCountry(%q, %q) == %q, %v, want a ValidationError", "firmware", country, got, err)
+		}
+	}
+}
+
+func TestAdminGetAPIToken_NormalizesCountry(t *testing.T) {
+	var (
+		ctx          = context.TODO()
+		mockDynamoDB = mock_dynamodbiface.NewMockDynamoDBAPI(gomock.NewController(t))
+		self         = New(mockDynamoDB)
+		secretKey    = "secretKey"
+		query        = generateQueryWithoutAppName()
+	)
+	query.Country = "usa"
+	query.QueryString["countryCode"] = "usa"
+	query.Signature = Sign(secretKey, query)
+
+	mockDynamoDB.
+		EXPECT().
+		ScanWithContext(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx aws.Context, input *dynamodb.ScanInput, _ ...request.Option) (*dynamodb.ScanOutput, error) {
+			if got := aws.StringValue(input.ExpressionAttributeValues[":c"].S); got != "US" {
+				t.Errorf("AdminGetAPIToken scanned countryCode %q, want %q", got, "US")
+			}
+			return &dynamodb.ScanOutput{Items: []map[string]*dynamodb.AttributeValue{{"apiToken": {S: aws.String("abc")}}}}, nil
+		})
+
+	if token, err := self.AdminGetAPIToken(ctx, secretKey, query); err != nil || token != "abc" {
+		t.Errorf("AdminGetAPIToken(ctx, %q, %q) == %q, %v, want %q", secretKey, query.Country, token, err, "abc")
+	}
+}
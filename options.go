@@ -0,0 +1,192 @@
+package shareddiscovery
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/expression"
+)
+
+// CallOption changes how a single read is made. Options that do not apply
+// to a call are ignored, such as WithFields on GetValidation.
+type CallOption func(*callOptions)
+
+type callOptions struct {
+	consistentRead bool
+	fields         []string
+	bypassCache    bool
+	timeout        time.Duration
+	capacity       *float64
+	servedCountry  *string
+	brand          string
+}
+
+// WithConsistentRead makes a strongly consistent read, so a config written
+// just before is seen. Consistent reads skip the Cache, cost twice the read
+// capacity and are only consistent within the region that took the write.
+// Queries of the appNameCountryIndex cannot be consistent and ignore it.
+func WithConsistentRead() CallOption {
+	return func(options *callOptions) {
+		options.consistentRead = true
+	}
+}
+
+// WithFields only returns the named fields of a config. Nested fields are
+// separated by dots, such as "urls.api". Projected configs are not cached,
+// but are served from a cached full config when there is one.
+func WithFields(fields ...string) CallOption {
+	return func(options *callOptions) {
+		options.fields = append(options.fields, fields...)
+	}
+}
+
+// WithoutCache reads from DynamoDB even when the Cache has the config, and
+// refreshes the Cache with the result.
+func WithoutCache() CallOption {
+	return func(options *callOptions) {
+		options.bypassCache = true
+	}
+}
+
+// WithTimeout bounds the whole call, including retries and failover.
+func WithTimeout(timeout time.Duration) CallOption {
+	return func(options *callOptions) {
+		options.timeout = timeout
+	}
+}
+
+// WithConsumedCapacity asks DynamoDB for the capacity the call consumes and
+// adds the capacity units to units. Cache hits consume nothing.
+func WithConsumedCapacity(units *float64) CallOption {
+	return func(options *callOptions) {
+		options.capacity = units
+	}
+}
+
+// WithServedCountry sets country to the countryCode of the row GetConfig
+// served, which differs from the requested one when it fell back to a
+// region group.
+func WithServedCountry(country *string) CallOption {
+	return func(options *callOptions) {
+		options.servedCountry = country
+	}
+}
+
+// WithBrand names the brand a BatchGetConfig is made for, so a RateLimiter
+// charges it to the brand's bucket as it charges GetConfig to query.Brand.
+func WithBrand(brand string) CallOption {
+	return func(options *callOptions) {
+		options.brand = brand
+	}
+}
+
+func newCallOptions(opts []CallOption) callOptions {
+	var options callOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
+// context applies the timeout of the options to ctx.
+func (options callOptions) context(ctx context.Context) (context.Context, context.CancelFunc) {
+	if options.timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, options.timeout)
+}
+
+// readCache reports whether a cached config may be returned.
+func (options callOptions) readCache() bool {
+	return !options.bypassCache && !options.consistentRead
+}
+
+// writeCache reports whether a config read from DynamoDB may be cached.
+func (options callOptions) writeCache() bool {
+	return len(options.fields) == 0
+}
+
+func (options callOptions) consistent() *bool {
+	if !options.consistentRead {
+		return nil
+	}
+	return aws.Bool(true)
+}
+
+func (options callOptions) returnCapacity() *string {
+	if options.capacity == nil {
+		return nil
+	}
+	return aws.String(dynamodb.ReturnConsumedCapacityTotal)
+}
+
+func (options callOptions) addCapacity(capacity ...*dynamodb.ConsumedCapacity) {
+	if options.capacity == nil {
+		return
+	}
+	for _, consumed := range capacity {
+		if consumed != nil {
+			*options.capacity += aws.Float64Value(consumed.CapacityUnits)
+		}
+	}
+}
+
+func (options callOptions) serve(country string) {
+	if options.servedCountry != nil {
+		*options.servedCountry = country
+	}
+}
+
+// projection returns the projection expression of the fields and any extra
+// attributes needed to make sense of the items, or nil names without fields.
+func (options callOptions) projection(extra ...string) (*string, map[string]*string, error) {
+	if len(options.fields) == 0 {
+		return nil, nil, nil
+	}
+	var names []expression.NameBuilder
+	for _, field := range append(append([]string{}, options.fields...), extra...) {
+		names = append(names, expression.Name(field))
+	}
+	expr, err := expression.NewBuilder().WithProjection(expression.NamesList(names[0], names[1:]...)).Build()
+	if err != nil {
+		return nil, nil, err
+	}
+	return expr.Projection(), expr.Names(), nil
+}
+
+// mask returns the fields of config, so cached and projected configs look
+// the same. It returns config itself without fields.
+func (options callOptions) mask(config map[string]interface{}) map[string]interface{} {
+	if len(options.fields) == 0 || config == nil {
+		return config
+	}
+	masked := map[string]interface{}{}
+	for _, field := range options.fields {
+		copyField(masked, config, strings.Split(field, "."))
+	}
+	return masked
+}
+
+func copyField(to, from map[string]interface{}, path []string) {
+	value, ok := from[path[0]]
+	if !ok {
+		return
+	}
+	if len(path) == 1 {
+		to[path[0]] = value
+		return
+	}
+	nested, ok := value.(map[string]interface{})
+	if !ok {
+		return
+	}
+	child, ok := to[path[0]].(map[string]interface{})
+	if !ok {
+		child = map[string]interface{}{}
+		to[path[0]] = child
+	}
+	copyField(child, nested, path[1:])
+}
@@ -0,0 +1,144 @@
+package shareddiscovery
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/golang/mock/gomock"
+	"github.com/pgdevelopers/shareddiscovery/v2/mocks/mock_dynamodbiface"
+)
+
+func TestGetConfig_CallOptions(t *testing.T) {
+	var (
+		ctx          = context.TODO()
+		mockDynamoDB = mock_dynamodbiface.NewMockDynamoDBAPI(gomock.NewController(t))
+		self         = New(mockDynamoDB)
+		query        = QueryInput{Workspace: "apps"}
+		units        float64
+	)
+	self.Cache = NewMemoryCache(0)
+	self.Cache.Set(configKeyFor("apiToken", query), map[string]interface{}{"name": "stale"})
+
+	mockDynamoDB.
+		EXPECT().
+		GetItemWithContext(gomock.Any(), &dynamodb.GetItemInput{
+			TableName:                aws.String("apps"),
+			Key:                      map[string]*dynamodb.AttributeValue{"apiToken": {S: aws.String("apiToken")}},
+			ConsistentRead:           aws.Bool(true),
+			ProjectionExpression:     aws.String("#0, #1.#2"),
+			ExpressionAttributeNames: map[string]*string{"#0": aws.String("name"), "#1": aws.String("urls"), "#2": aws.String("api")},
+			ReturnConsumedCapacity:   aws.String(dynamodb.ReturnConsumedCapacityTotal),
+		}).
+		Return(&dynamodb.GetItemOutput{
+			Item: map[string]*dynamodb.AttributeValue{
+				"name": {S: aws.String("fresh")},
+				"urls": {M: map[string]*dynamodb.AttributeValue{"api": {S: aws.String("https://api")}}},
+			},
+			ConsumedCapacity: &dynamodb.ConsumedCapacity{CapacityUnits: aws.Float64(1)},
+		}, nil)
+
+	config, err := self.GetConfig(ctx, "apiToken", query, WithConsistentRead(), WithFields("name", "urls.api"), WithConsumedCapacity(&units))
+
+	if err != nil || config["name"] != "fresh" {
+		t.Errorf("GetConfig(ctx, %q, %q, consistent) == %v, %v, want the fresh config", "apiToken", query.Workspace, config, err)
+	}
+	if units != 1 {
+		t.Errorf("GetConfig(ctx, %q, %q) consumed %v units, want 1", "apiToken", query.Workspace, units)
+	}
+	if cached, _ := self.Cache.Get(configKeyFor("apiToken", query)); cached["name"] != "stale" {
+		t.Errorf("Cache.Get after a projected GetConfig == %v, want the cached config untouched", cached)
+	}
+}
+
+func TestGetConfig_WithoutCache(t *testing.T) {
+	var (
+		ctx          = context.TODO()
+		mockDynamoDB = mock_dynamodbiface.NewMockDynamoDBAPI(gomock.NewController(t))
+		self         = New(mockDynamoDB)
+		query        = QueryInput{Workspace: "apps"}
+	)
+	self.Cache = NewMemoryCache(0)
+	self.Cache.Set(configKeyFor("apiToken", query), map[string]interface{}{"name": "stale"})
+
+	mockDynamoDB.
+		EXPECT().
+		GetItemWithContext(gomock.Any(), gomock.Any()).
+		Return(&dynamodb.GetItemOutput{Item: map[string]*dynamodb.AttributeValue{"name": {S: aws.String("fresh")}}}, nil)
+
+	if config, err := self.GetConfig(ctx, "apiToken", query, WithoutCache()); err != nil || config["name"] != "fresh" {
+		t.Errorf("GetConfig(ctx, %q, %q, WithoutCache()) == %v, %v, want the fresh config", "apiToken", query.Workspace, config, err)
+	}
+	if config, err := self.GetConfig(ctx, "apiToken", query, WithFields("name")); err != nil || config["name"] != "fresh" {
+		t.Errorf("GetConfig(ctx, %q, %q, WithFields(name)) == %v, %v, want the refreshed cache entry", "apiToken", query.Workspace, config, err)
+	}
+}
+
+func TestGetConfig_WithTimeout(t *testing.T) {
+	var (
+		ctx          = context.TODO()
+		mockDynamoDB = mock_dynamodbiface.NewMockDynamoDBAPI(gomock.NewController(t))
+		self         = New(mockDynamoDB)
+		query        = QueryInput{Workspace: "apps"}
+	)
+
+	mockDynamoDB.
+		EXPECT().
+		GetItemWithContext(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx aws.Context, _ *dynamodb.GetItemInput, _ ...request.Option) (*dynamodb.GetItemOutput, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		})
+
+	if _, err := self.GetConfig(ctx, "apiToken", query, WithTimeout(time.Millisecond)); err != context.DeadlineExceeded {
+		t.Errorf("GetConfig(ctx, %q, %q, WithTimeout(1ms)) == %v, want %v", "apiToken", query.Workspace, err, context.DeadlineExceeded)
+	}
+}
+
+func TestBatchGetConfig_WithFields(t *testing.T) {
+	var (
+		ctx          = context.TODO()
+		mockDynamoDB = mock_dynamodbiface.NewMockDynamoDBAPI(gomock.NewController(t))
+		self         = New(mockDynamoDB)
+		key          = ConfigKey{Workspace: "apps", APIToken: "abc"}
+	)
+
+	mockDynamoDB.
+		EXPECT().
+		BatchGetItemWithContext(gomock.Any(), &dynamodb.BatchGetItemInput{RequestItems: map[string]*dynamodb.KeysAndAttributes{
+			"apps": {
+				Keys:                     []map[string]*dynamodb.AttributeValue{{"apiToken": {S: aws.String("abc")}}},
+				ConsistentRead:           aws.Bool(true),
+				ProjectionExpression:     aws.String("#0, #1, #2"),
+				ExpressionAttributeNames: map[string]*string{"#0": aws.String("name"), "#1": aws.String("apiToken"), "#2": aws.String("countryCode")},
+			},
+		}}).
+		Return(&dynamodb.BatchGetItemOutput{Responses: map[string][]map[string]*dynamodb.AttributeValue{
+			"apps": {{"apiToken": {S: aws.String("abc")}, "name": {S: aws.String("app")}}},
+		}}, nil)
+
+	results := self.BatchGetConfig(ctx, []ConfigKey{key}, WithFields("name"), WithConsistentRead())
+
+	want := map[string]interface{}{"name": "app"}
+	if len(results) != 1 || results[0].Err != nil || !reflect.DeepEqual(results[0].Config, want) {
+		t.Errorf("BatchGetConfig(ctx, %v, WithFields(name)) == %+v, want %v", key, results, want)
+	}
+}
+
+func TestCallOptions_Mask(t *testing.T) {
+	options := newCallOptions([]CallOption{WithFields("name", "urls.api", "urls.missing", "missing.field")})
+	config := map[string]interface{}{
+		"name":  "app",
+		"other": true,
+		"urls":  map[string]interface{}{"api": "https://api", "cdn": "https://cdn"},
+	}
+
+	want := map[string]interface{}{"name": "app", "urls": map[string]interface{}{"api": "https://api"}}
+	if got := options.mask(config); !reflect.DeepEqual(got, want) {
+		t.Errorf("mask(%v) == %v, want %v", config, got, want)
+	}
+}
@@ -0,0 +1,405 @@
+package shareddiscovery
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrConflict is returned by Promote when the target no longer holds the
+// values a change set was diffed against.
+var ErrConflict = errors.New("shareddiscovery: target changed since the diff")
+
+// IdentityFields identify a config row rather than configure it, so Diff
+// leaves them out and Promote never writes them.
+var IdentityFields = []string{"apiToken", "countryCode", "environment"}
+
+// DiffType is how a path differs between two configs.
+type DiffType string
+
+// The kinds of change Diff reports.
+const (
+	DiffAdded   DiffType = "added"
+	DiffRemoved DiffType = "removed"
+	DiffChanged DiffType = "changed"
+)
+
+// Change is a difference at Path, the dot separated path of a field in the
+// config, in which dots and backslashes within a name are escaped with a
+// backslash. From is the value the target holds and To the value of the source;
+// From is nil for added paths and To for removed ones. Values keep their
+// DynamoDB types and are encoded like Export does.
+type Change struct {
+	Path string
+	Type DiffType
+	From *dynamodb.AttributeValue
+	To   *dynamodb.AttributeValue
+}
+
+// ChangeSet is what Promote applies to Target so it matches Source. It can be
+// saved as JSON or YAML for review and edited down before promoting.
+type ChangeSet struct {
+	Source  ConfigKey `json:"source" yaml:"source"`
+	Target  ConfigKey `json:"target" yaml:"target"`
+	Changes []Change  `json:"changes" yaml:"changes"`
+}
+
+// changeDocument is how a Change is written to JSON and YAML.
+type changeDocument struct {
+	Path string      `json:"path" yaml:"path"`
+	Type DiffType    `json:"type" yaml:"type"`
+	From interface{} `json:"from,omitempty" yaml:"from,omitempty"`
+	To   interface{} `json:"to,omitempty" yaml:"to,omitempty"`
+}
+
+func (change Change) document() changeDocument {
+	document := changeDocument{Path: change.Path, Type: change.Type}
+	if change.From != nil {
+		document.From = encodeAttribute(change.From)
+	}
+	if change.To != nil {
+		document.To = encodeAttribute(change.To)
+	}
+	return document
+}
+
+func (change *Change) fromDocument(document changeDocument) (err error) {
+	*change = Change{Path: document.Path, Type: document.Type}
+	if document.From != nil {
+		if change.From, err = decodeAttribute(document.From); err != nil {
+			return fmt.Errorf("shareddiscovery: change %s: from: %w", document.Path, err)
+		}
+	}
+	if document.To != nil {
+		if change.To, err = decodeAttribute(document.To); err != nil {
+			return fmt.Errorf("shareddiscovery: change %s: to: %w", document.Path, err)
+		}
+	}
+	return nil
+}
+
+// MarshalJSON encodes the values of change in DynamoDB JSON.
+func (change Change) MarshalJSON() ([]byte, error) {
+	return json.Marshal(change.document())
+}
+
+// UnmarshalJSON decodes a change written by MarshalJSON.
+func (change *Change) UnmarshalJSON(data []byte) error {
+	var document changeDocument
+	if err := json.Unmarshal(data, &document); err != nil {
+		return err
+	}
+	return change.fromDocument(document)
+}
+
+// MarshalYAML encodes the values of change in DynamoDB JSON.
+func (change Change) MarshalYAML() (interface{}, error) {
+	return change.document(), nil
+}
+
+// UnmarshalYAML decodes a change written by MarshalYAML.
+func (change *Change) UnmarshalYAML(node *yaml.Node) error {
+	var document changeDocument
+	if err := node.Decode(&document); err != nil {
+		return err
+	}
+	return change.fromDocument(document)
+}
+
+// Diff compares the configs of source and target, which may differ in
+// workspace, apiToken or country, and returns the changes that would make
+// target match source. Nested maps are compared field by field and lists and
+// sets are compared whole. A change's path joins the field names with dots,
+// escaping dots within a name as `\.` and backslashes as `\\`. Both configs
+// are read consistently from DynamodbSvc, and ErrNoResults is returned when
+// either is missing.
+func (service SharedDiscovery) Diff(ctx context.Context, source, target ConfigKey) (changes ChangeSet, err error) {
+	ctx, diffSpan := service.startSpan(ctx, "Diff")
+	defer func(start time.Time) {
+		service.observe(ctx, "Diff", target.Workspace, start, err)
+		finishSpan(diffSpan, err)
+	}(time.Now())
+	diffSpan.AddField("workspace", target.Workspace)
+	diffSpan.AddField("source.workspace", source.Workspace)
+
+	from, err := service.getItem(ctx, diffSpan, source)
+	if err != nil {
+		diffSpan.AddField("error.message", err.Error())
+		return ChangeSet{}, err
+	}
+	to, err := service.getItem(ctx, diffSpan, target)
+	if err != nil {
+		diffSpan.AddField("error.message", err.Error())
+		return ChangeSet{}, err
+	}
+
+	for _, name := range IdentityFields {
+		delete(from, name)
+		delete(to, name)
+	}
+	if err = service.dropEqualEncrypted(ctx, source, from, target, to); err != nil {
+		diffSpan.AddField("error.message", err.Error())
+		return ChangeSet{}, err
+	}
+	changes = ChangeSet{Source: source, Target: target, Changes: []Change{}}
+	diffItems(nil, to, from, &changes.Changes)
+	sort.Slice(changes.Changes, func(i, j int) bool { return changes.Changes[i].Path < changes.Changes[j].Path })
+	diffSpan.AddField("changes.count", len(changes.Changes))
+	return changes, nil
+}
+
+// Promote applies changes to their Target in a single conditional update, so
+// nothing is written when any changed path no longer holds its From value.
+// That is reported as ErrConflict; diff again and review the new change set.
+func (service SharedDiscovery) Promote(ctx context.Context, changes ChangeSet) (err error) {
+	ctx, promoteSpan := service.startSpan(ctx, "Promote")
+	defer func(start time.Time) {
+		service.observe(ctx, "Promote", changes.Target.Workspace, start, err)
+		finishSpan(promoteSpan, err)
+	}(time.Now())
+	promoteSpan.AddField("workspace", changes.Target.Workspace)
+	promoteSpan.AddField("changes.count", len(changes.Changes))
+
+	if len(changes.Changes) == 0 {
+		return nil
+	}
+	if changes, err = service.encryptChanges(ctx, changes); err != nil {
+		promoteSpan.AddField("error.message", err.Error())
+		return err
+	}
+	input, err := promoteInput(changes)
+	if err != nil {
+		promoteSpan.AddField("error.message", err.Error())
+		return err
+	}
+
+	err = service.Resilience.call(ctx, promoteSpan, changes.Target.Workspace, func() (err error) {
+		_, err = service.DynamodbSvc.UpdateItemWithContext(ctx, input)
+		return err
+	})
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) && awsErr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		err = ErrConflict
+	}
+	if err != nil {
+		promoteSpan.AddField("error.message", err.Error())
+		return err
+	}
+	if service.Cache != nil {
+		service.Cache.Invalidate(changes.Target)
+	}
+	return nil
+}
+
+func (service SharedDiscovery) getItem(ctx context.Context, span Span, key ConfigKey) (map[string]*dynamodb.AttributeValue, error) {
+	var output *dynamodb.GetItemOutput
+	err := service.Resilience.call(ctx, span, key.Workspace, func() (err error) {
+		output, err = service.DynamodbSvc.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+			TableName:      aws.String(key.Workspace),
+			Key:            keyAttributes(key),
+			ConsistentRead: aws.Bool(true),
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(output.Item) == 0 {
+		return nil, fmt.Errorf("%w: %s %s", ErrNoResults, key.Workspace, key.Country)
+	}
+	return output.Item, nil
+}
+
+// dropEqualEncrypted removes the encrypted attributes of from, the source
+// row, and to, the target row, that decrypt to the same value, as their
+// ciphertexts always differ. Encrypted attributes that differ are left
+// encrypted, so change sets never hold plaintexts of them.
+func (service SharedDiscovery) dropEqualEncrypted(ctx context.Context, source ConfigKey, from map[string]*dynamodb.AttributeValue, target ConfigKey, to map[string]*dynamodb.AttributeValue) error {
+	plainFrom, err := service.decryptItem(ctx, source, from)
+	if err != nil {
+		return err
+	}
+	plainTo, err := service.decryptItem(ctx, target, to)
+	if err != nil {
+		return err
+	}
+	for name, value := range from {
+		if !isEncrypted(value) && !isEncrypted(to[name]) {
+			continue
+		}
+		if _, ok := to[name]; ok && reflect.DeepEqual(encodeAttribute(plainFrom[name]), encodeAttribute(plainTo[name])) {
+			delete(from, name)
+			delete(to, name)
+		}
+	}
+	return nil
+}
+
+// encryptChanges returns changes with the To values encrypted for the
+// target row. Values encrypted for the source row are decrypted and
+// encrypted again, as ciphertexts are bound to their row, and plaintexts of
+// attributes marked in the target workspace are encrypted.
+func (service SharedDiscovery) encryptChanges(ctx context.Context, changes ChangeSet) (ChangeSet, error) {
+	if service.Encryption == nil {
+		return changes, nil
+	}
+	encrypted := make([]Change, len(changes.Changes))
+	for i, change := range changes.Changes {
+		encrypted[i] = change
+		segments := splitPath(change.Path)
+		if change.To == nil || len(segments) != 1 {
+			continue
+		}
+		name, value := segments[0], change.To
+		if !isEncrypted(value) {
+			if !service.Encryption.marked(changes.Target.Workspace, name) {
+				continue
+			}
+		} else {
+			plain, err := service.Encryption.DecryptItem(ctx, changes.Source, map[string]*dynamodb.AttributeValue{name: value})
+			if err != nil {
+				return changes, err
+			}
+			value = plain[name]
+		}
+		var err error
+		if encrypted[i].To, err = service.Encryption.encrypt(ctx, changes.Target, name, value); err != nil {
+			return changes, fmt.Errorf("encrypting %s: %w", name, err)
+		}
+	}
+	changes.Changes = encrypted
+	return changes, nil
+}
+
+// diffItems appends the changes turning from into to, with names below path.
+func diffItems(path []string, from, to map[string]*dynamodb.AttributeValue, changes *[]Change) {
+	for name, value := range to {
+		below := append(append([]string{}, path...), name)
+		at := joinPath(below)
+		old, ok := from[name]
+		switch {
+		case !ok:
+			*changes = append(*changes, Change{Path: at, Type: DiffAdded, To: value})
+		case old.M != nil && value.M != nil && !isEncrypted(old) && !isEncrypted(value):
+			diffItems(below, old.M, value.M, changes)
+		case !reflect.DeepEqual(encodeAttribute(old), encodeAttribute(value)):
+			*changes = append(*changes, Change{Path: at, Type: DiffChanged, From: old, To: value})
+		}
+	}
+	for name, old := range from {
+		if _, ok := to[name]; !ok {
+			at := joinPath(append(append([]string{}, path...), name))
+			*changes = append(*changes, Change{Path: at, Type: DiffRemoved, From: old})
+		}
+	}
+}
+
+// joinPath joins segments with dots, escaping dots and backslashes within
+// them so splitPath can tell them apart.
+func joinPath(segments []string) string {
+	escaped := make([]string, len(segments))
+	for i, segment := range segments {
+		escaped[i] = pathEscaper.Replace(segment)
+	}
+	return strings.Join(escaped, ".")
+}
+
+var pathEscaper = strings.NewReplacer(`\`, `\\`, ".", `\.`)
+
+// splitPath splits a path made by joinPath into its segments.
+func splitPath(path string) []string {
+	var segments []string
+	var segment strings.Builder
+	for i := 0; i < len(path); i++ {
+		switch {
+		case path[i] == '\\' && i+1 < len(path):
+			i++
+			segment.WriteByte(path[i])
+		case path[i] == '.':
+			segments = append(segments, segment.String())
+			segment.Reset()
+		default:
+			segment.WriteByte(path[i])
+		}
+	}
+	return append(segments, segment.String())
+}
+
+// promoteInput builds the update for changes. Every path is conditioned on
+// still holding its From value, or on not existing for added paths.
+func promoteInput(changes ChangeSet) (*dynamodb.UpdateItemInput, error) {
+	input := &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(changes.Target.Workspace),
+		Key:                       keyAttributes(changes.Target),
+		ExpressionAttributeNames:  map[string]*string{},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{},
+	}
+	identity := map[string]bool{}
+	for _, name := range IdentityFields {
+		identity[name] = true
+	}
+
+	var sets, removes, conditions []string
+	for i, change := range changes.Changes {
+		segments := splitPath(change.Path)
+		if change.Path == "" || identity[segments[0]] {
+			return nil, fmt.Errorf("shareddiscovery: cannot promote %q", change.Path)
+		}
+		placeholders := make([]string, len(segments))
+		for j, segment := range segments {
+			placeholders[j] = fmt.Sprintf("#p%d_%d", i, j)
+			input.ExpressionAttributeNames[placeholders[j]] = aws.String(segment)
+		}
+		path := strings.Join(placeholders, ".")
+
+		switch change.Type {
+		case DiffAdded:
+			conditions = append(conditions, fmt.Sprintf("attribute_not_exists(%s)", path))
+		case DiffChanged, DiffRemoved:
+			if change.From == nil {
+				return nil, fmt.Errorf("shareddiscovery: change %s has no from value", change.Path)
+			}
+			input.ExpressionAttributeValues[fmt.Sprintf(":from%d", i)] = change.From
+			conditions = append(conditions, fmt.Sprintf("%s = :from%d", path, i))
+		default:
+			return nil, fmt.Errorf("shareddiscovery: change %s has unknown type %q", change.Path, change.Type)
+		}
+
+		if change.Type == DiffRemoved {
+			removes = append(removes, path)
+			continue
+		}
+		if change.To == nil {
+			return nil, fmt.Errorf("shareddiscovery: change %s has no to value", change.Path)
+		}
+		input.ExpressionAttributeValues[fmt.Sprintf(":to%d", i)] = change.To
+		sets = append(sets, fmt.Sprintf("%s = :to%d", path, i))
+	}
+
+	var update []string
+	if len(sets) > 0 {
+		update = append(update, "SET "+strings.Join(sets, ", "))
+	}
+	if len(removes) > 0 {
+		update = append(update, "REMOVE "+strings.Join(removes, ", "))
+	}
+	input.UpdateExpression = aws.String(strings.Join(update, " "))
+	// the target itself must still exist
+	input.ExpressionAttributeNames["#key"] = aws.String("apiToken")
+	conditions = append([]string{"attribute_exists(#key)"}, conditions...)
+	input.ConditionExpression = aws.String(strings.Join(conditions, " AND "))
+	if len(input.ExpressionAttributeValues) == 0 {
+		input.ExpressionAttributeValues = nil
+	}
+	return input, nil
+}
@@ -0,0 +1,194 @@
+package shareddiscovery
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/pgdevelopers/shareddiscovery/v2/discoverytest"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	qaKey        = ConfigKey{Workspace: "apps", APIToken: "qa-token"}
+	prodKey      = ConfigKey{Workspace: "apps", APIToken: "prod-token"}
+	promoteTable = TableDefinition{HashKey: "apiToken"}.CreateTableInput("apps")
+)
+
+// newPromoteDiscovery returns a SharedDiscovery on an in-memory apps
+// workspace holding a qa and a prod config.
+func newPromoteDiscovery(t *testing.T) (SharedDiscovery, *discoverytest.DynamoDB) {
+	db := discoverytest.NewDynamoDB()
+	db.Seed(t, promoteTable,
+		map[string]interface{}{"apiToken": "qa-token", "environment": "qa", "name": "app", "timeout": 5, "beta": true, "urls": map[string]string{"api": "https://qa", "cdn": "https://cdn"}},
+		map[string]interface{}{"apiToken": "prod-token", "environment": "prod", "name": "app", "timeout": 3, "legacy": true, "urls": map[string]string{"api": "https://prod", "cdn": "https://cdn"}},
+	)
+	return New(db), db
+}
+
+func TestDiff(t *testing.T) {
+	var (
+		ctx     = context.TODO()
+		self, _ = newPromoteDiscovery(t)
+	)
+
+	changes, err := self.Diff(ctx, qaKey, prodKey)
+	if err != nil {
+		t.Fatalf("Diff(ctx, qa, prod) == %v, want nil", err)
+	}
+
+	want := []Change{
+		{Path: "beta", Type: DiffAdded, To: &dynamodb.AttributeValue{BOOL: aws.Bool(true)}},
+		{Path: "legacy", Type: DiffRemoved, From: &dynamodb.AttributeValue{BOOL: aws.Bool(true)}},
+		{Path: "timeout", Type: DiffChanged, From: &dynamodb.AttributeValue{N: aws.String("3")}, To: &dynamodb.AttributeValue{N: aws.String("5")}},
+		{Path: "urls.api", Type: DiffChanged, From: &dynamodb.AttributeValue{S: aws.String("https://prod")}, To: &dynamodb.AttributeValue{S: aws.String("https://qa")}},
+	}
+	if !reflect.DeepEqual(changes.Changes, want) || changes.Source != qaKey || changes.Target != prodKey {
+		t.Errorf("Diff(ctx, qa, prod) == %+v, want %+v", changes, want)
+	}
+}
+
+func TestDiff_Missing(t *testing.T) {
+	self, _ := newPromoteDiscovery(t)
+
+	if _, err := self.Diff(context.TODO(), qaKey, ConfigKey{Workspace: "apps", APIToken: "missing"}); !errors.Is(err, ErrNoResults) {
+		t.Errorf("Diff(ctx, qa, missing) == %v, want %v", err, ErrNoResults)
+	}
+}
+
+func TestPromote(t *testing.T) {
+	var (
+		ctx     = context.TODO()
+		self, _ = newPromoteDiscovery(t)
+	)
+	self.Cache = NewMemoryCache(0)
+	self.Cache.Set(prodKey, map[string]interface{}{"stale": true})
+
+	changes, err := self.Diff(ctx, qaKey, prodKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := self.Promote(ctx, changes); err != nil {
+		t.Fatalf("Promote(ctx, qa to prod) == %v, want nil", err)
+	}
+
+	config, err := self.GetConfig(ctx, prodKey.APIToken, QueryInput{Workspace: "apps"})
+	want := map[string]interface{}{
+		"apiToken": "prod-token", "environment": "prod", "name": "app", "timeout": float64(5), "beta": true,
+		"urls": map[string]interface{}{"api": "https://qa", "cdn": "https://cdn"},
+	}
+	if err != nil || !reflect.DeepEqual(config, want) {
+		t.Errorf("GetConfig(prod) after Promote == %v, %v, want %v", config, err, want)
+	}
+	if again, _ := self.Diff(ctx, qaKey, prodKey); len(again.Changes) != 0 {
+		t.Errorf("Diff(ctx, qa, prod) after Promote == %+v, want no changes", again.Changes)
+	}
+}
+
+func TestPromote_Conflict(t *testing.T) {
+	var (
+		ctx      = context.TODO()
+		self, db = newPromoteDiscovery(t)
+	)
+
+	changes, err := self.Diff(ctx, qaKey, prodKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// someone edits prod after the change set was reviewed
+	if _, err := db.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName:                 aws.String("apps"),
+		Key:                       keyAttributes(prodKey),
+		UpdateExpression:          aws.String("SET timeout = :t"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":t": {N: aws.String("10")}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := self.Promote(ctx, changes); err != ErrConflict {
+		t.Errorf("Promote(ctx, stale change set) == %v, want %v", err, ErrConflict)
+	}
+	if config, _ := self.GetConfig(ctx, prodKey.APIToken, QueryInput{Workspace: "apps"}); config["beta"] != nil {
+		t.Errorf("GetConfig(prod) after a conflicting Promote == %v, want nothing promoted", config)
+	}
+}
+
+func TestPromote_DottedNames(t *testing.T) {
+	var (
+		ctx      = context.TODO()
+		self, db = newPromoteDiscovery(t)
+	)
+	db.Seed(t, promoteTable,
+		map[string]interface{}{"apiToken": "qa-token", "feature.beta": true, "urls": map[string]string{"v2.api": "https://qa"}},
+		map[string]interface{}{"apiToken": "prod-token", "feature.beta": false, "urls": map[string]string{"v2.api": "https://prod"}},
+	)
+
+	changes, err := self.Diff(ctx, qaKey, prodKey)
+	paths := map[string]bool{}
+	for _, change := range changes.Changes {
+		paths[change.Path] = true
+	}
+	if err != nil || !paths[`feature\.beta`] || !paths[`urls.v2\.api`] {
+		t.Fatalf("Diff(ctx, qa, prod) == %+v, %v, want escaped dotted names", changes.Changes, err)
+	}
+	if err := self.Promote(ctx, changes); err != nil {
+		t.Fatalf("Promote(ctx, qa to prod) == %v, want nil", err)
+	}
+
+	config, err := self.GetConfig(ctx, prodKey.APIToken, QueryInput{Workspace: "apps"})
+	want := map[string]interface{}{"apiToken": "prod-token", "feature.beta": true, "urls": map[string]interface{}{"v2.api": "https://qa"}}
+	if err != nil || !reflect.DeepEqual(config, want) {
+		t.Errorf("GetConfig(prod) after Promote == %v, %v, want %v", config, err, want)
+	}
+}
+
+func TestSplitPath(t *testing.T) {
+	for _, segments := range [][]string{{"urls", "api"}, {"feature.beta"}, {`back\slash`, "a.b.", ""}} {
+		if got := splitPath(joinPath(segments)); !reflect.DeepEqual(got, segments) {
+			t.Errorf("splitPath(joinPath(%q)) == %q, want %q", segments, got, segments)
+		}
+	}
+}
+
+func TestPromote_IdentityFields(t *testing.T) {
+	self, _ := newPromoteDiscovery(t)
+	changes := ChangeSet{Target: prodKey, Changes: []Change{
+		{Path: "environment", Type: DiffChanged, From: &dynamodb.AttributeValue{S: aws.String("prod")}, To: &dynamodb.AttributeValue{S: aws.String("qa")}},
+	}}
+
+	if err := self.Promote(context.TODO(), changes); err == nil {
+		t.Errorf("Promote(ctx, environment change) == nil, want an error")
+	}
+}
+
+func TestChangeSet_Encoding(t *testing.T) {
+	changes := ChangeSet{Source: qaKey, Target: prodKey, Changes: []Change{
+		{Path: "tags", Type: DiffChanged, From: &dynamodb.AttributeValue{SS: aws.StringSlice([]string{"a"})}, To: &dynamodb.AttributeValue{SS: aws.StringSlice([]string{"a", "b"})}},
+		{Path: "beta", Type: DiffAdded, To: &dynamodb.AttributeValue{BOOL: aws.Bool(true)}},
+	}}
+
+	data, err := json.Marshal(changes)
+	want := `{"source":{"workspace":"apps","apiToken":"qa-token"},"target":{"workspace":"apps","apiToken":"prod-token"},"changes":[` +
+		`{"path":"tags","type":"changed","from":{"SS":["a"]},"to":{"SS":["a","b"]}},{"path":"beta","type":"added","to":{"BOOL":true}}]}`
+	if err != nil || string(data) != want {
+		t.Errorf("json.Marshal(changes) == %s, %v, want %s", data, err, want)
+	}
+
+	var decoded ChangeSet
+	if err := json.Unmarshal(data, &decoded); err != nil || !reflect.DeepEqual(decoded, changes) {
+		t.Errorf("json.Unmarshal(%s) == %+v, %v, want %+v", data, decoded, err, changes)
+	}
+
+	data, err = yaml.Marshal(changes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded = ChangeSet{}
+	if err := yaml.Unmarshal(data, &decoded); err != nil || !reflect.DeepEqual(decoded, changes) {
+		t.Errorf("yaml.Unmarshal(%s) == %+v, %v, want %+v", data, decoded, err, changes)
+	}
+}
@@ -0,0 +1,404 @@
+package shareddiscovery
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// Limit is a token bucket refilled at Rate requests per second and holding
+// at most Burst. A zero Rate is unlimited, and a zero Burst holds one
+// second of Rate, rounded up.
+type Limit struct {
+	Rate  float64 `json:"rate" yaml:"rate"`
+	Burst int     `json:"burst" yaml:"burst"`
+}
+
+// withBurst returns limit with Burst defaulted when it is not positive.
+func (limit Limit) withBurst() Limit {
+	if limit.Burst <= 0 {
+		limit.Burst = int(math.Max(1, math.Ceil(limit.Rate)))
+	}
+	return limit
+}
+
+// RateLimits are the limits a RateLimiter applies. Every apiToken and every
+// brand has a bucket of its own, limited by the override for it or else by
+// the default. They can be decoded from JSON or YAML.
+type RateLimits struct {
+	Token  Limit            `json:"token" yaml:"token"`
+	Brand  Limit            `json:"brand" yaml:"brand"`
+	Tokens map[string]Limit `json:"tokens,omitempty" yaml:"tokens,omitempty"`
+	Brands map[string]Limit `json:"brands,omitempty" yaml:"brands,omitempty"`
+}
+
+func (limits RateLimits) forToken(apiToken string) Limit {
+	if limit, ok := limits.Tokens[apiToken]; ok {
+		return limit
+	}
+	return limits.Token
+}
+
+func (limits RateLimits) forBrand(brand string) Limit {
+	if limit, ok := limits.Brands[brand]; ok {
+		return limit
+	}
+	return limits.Brand
+}
+
+// RateLimitError is returned when a call exceeds the limit of its bucket,
+// named like "apiToken:sha256:ba7816bf8f01" or "brand:oralb". Token buckets
+// are named by a digest of the apiToken so errors can be shown to callers
+// and logged. RetryAfter is how long until the call would be allowed.
+type RateLimitError struct {
+	Bucket     string
+	Limit      Limit
+	RetryAfter time.Duration
+}
+
+func (err *RateLimitError) Error() string {
+	return fmt.Sprintf("rate limit of %v/s exceeded for %s, retry after %v", err.Limit.Rate, err.Bucket, err.RetryAfter)
+}
+
+// RateLimitStore keeps the buckets of a RateLimiter. Take removes n tokens
+// from the bucket key and returns false and how long until they would be
+// available when it holds too few. Refund puts back n tokens taken from key
+// for a call that another bucket then refused.
+type RateLimitStore interface {
+	Take(ctx context.Context, key string, limit Limit, n int) (ok bool, retryAfter time.Duration, err error)
+	Refund(ctx context.Context, key string, limit Limit, n int) error
+}
+
+// RateLimiter is an IFace that limits the calls made to the IFace it wraps,
+// so one client cannot drive up the DynamoDB cost of everyone. Calls are
+// charged to the bucket of their apiToken and of their brand, and a
+// BatchGetConfig is charged once per key.
+type RateLimiter struct {
+	IFace
+	Limits RateLimits
+	Store  RateLimitStore
+
+	// FailClosed rejects calls when the Store fails. By default they are
+	// let through, so the limiter does not take the service down with it.
+	FailClosed bool
+}
+
+// NewRateLimiter returns a RateLimiter applying limits to next.
+func NewRateLimiter(next IFace, limits RateLimits, store RateLimitStore) *RateLimiter {
+	return &RateLimiter{IFace: next, Limits: limits, Store: store}
+}
+
+// GetValidation is charged to the brand of query.
+func (limiter *RateLimiter) GetValidation(ctx context.Context, query QueryInput, opts ...CallOption) (bool, error) {
+	if err := limiter.take(ctx, "", query.Brand, 1); err != nil {
+		return false, err
+	}
+	return limiter.IFace.GetValidation(ctx, query, opts...)
+}
+
+// GetConfig is charged to apiToken and the brand of query.
+func (limiter *RateLimiter) GetConfig(ctx context.Context, apiToken string, query QueryInput, opts ...CallOption) (map[string]interface{}, error) {
+	if err := limiter.take(ctx, apiToken, query.Brand, 1); err != nil {
+		return nil, err
+	}
+	return limiter.IFace.GetConfig(ctx, apiToken, query, opts...)
+}
+
+// BatchGetConfig charges every apiToken once per key, and the brand named
+// by WithBrand once per key its tokens allow. Keys over a limit get the
+// RateLimitError as their Err and are not read.
+func (limiter *RateLimiter) BatchGetConfig(ctx context.Context, keys []ConfigKey, opts ...CallOption) []ConfigResult {
+	counts := map[string]int{}
+	var tokens []string
+	for _, key := range keys {
+		if counts[key.APIToken] == 0 {
+			tokens = append(tokens, key.APIToken)
+		}
+		counts[key.APIToken]++
+	}
+	limited := map[string]error{}
+	allowed := 0
+	for _, token := range tokens {
+		if err := limiter.take(ctx, token, "", counts[token]); err != nil {
+			limited[token] = err
+			continue
+		}
+		allowed += counts[token]
+	}
+	if brand := newCallOptions(opts).brand; brand != "" && allowed > 0 {
+		if err := limiter.take(ctx, "", brand, allowed); err != nil {
+			for _, token := range tokens {
+				if limited[token] == nil {
+					limiter.refund(ctx, limiter.buckets(token, ""), counts[token])
+					limited[token] = err
+				}
+			}
+		}
+	}
+
+	var unlimited []ConfigKey
+	for _, key := range keys {
+		if limited[key.APIToken] == nil {
+			unlimited = append(unlimited, key)
+		}
+	}
+	var read []ConfigResult
+	if len(unlimited) > 0 {
+		read = limiter.IFace.BatchGetConfig(ctx, unlimited, opts...)
+	}
+
+	results := make([]ConfigResult, len(keys))
+	for i, key := range keys {
+		if err := limited[key.APIToken]; err != nil {
+			results[i] = ConfigResult{Key: key, Err: err}
+			continue
+		}
+		results[i], read = read[0], read[1:]
+	}
+	return results
+}
+
+// AdminGetAPIToken is charged to the brand of query.
+func (limiter *RateLimiter) AdminGetAPIToken(ctx context.Context, secretKey string, query QueryInput, opts ...CallOption) (string, error) {
+	if err := limiter.take(ctx, "", query.Brand, 1); err != nil {
+		return "", err
+	}
+	return limiter.IFace.AdminGetAPIToken(ctx, secretKey, query, opts...)
+}
+
+// rateBucket is a bucket of the store and the limit it is kept at.
+type rateBucket struct {
+	key   string
+	limit Limit
+}
+
+// HealthCheck is not limited, and reports the health of the wrapped IFace,
+// or ErrNoHealthCheck when it cannot report.
+func (limiter *RateLimiter) HealthCheck(ctx context.Context) (HealthReport, error) {
+	checker, ok := limiter.IFace.(interface {
+		HealthCheck(ctx context.Context) (HealthReport, error)
+	})
+	if !ok {
+		return HealthReport{}, ErrNoHealthCheck
+	}
+	return checker.HealthCheck(ctx)
+}
+
+// buckets returns the limited buckets of apiToken and brand, skipping
+// empty ones.
+func (limiter *RateLimiter) buckets(apiToken, brand string) []rateBucket {
+	var buckets []rateBucket
+	if limit := limiter.Limits.forToken(apiToken).withBurst(); apiToken != "" && limit.Rate > 0 {
+		buckets = append(buckets, rateBucket{"apiToken:" + tokenDigest(apiToken), limit})
+	}
+	if limit := limiter.Limits.forBrand(brand).withBurst(); brand != "" && limit.Rate > 0 {
+		buckets = append(buckets, rateBucket{"brand:" + brand, limit})
+	}
+	return buckets
+}
+
+// take charges n to the buckets of apiToken and brand. A call refused by
+// the brand is refunded to its apiToken, so it is not charged for a call it
+// never made.
+func (limiter *RateLimiter) take(ctx context.Context, apiToken, brand string, n int) error {
+	var taken []rateBucket
+	for _, bucket := range limiter.buckets(apiToken, brand) {
+		ok, retryAfter, err := limiter.Store.Take(ctx, bucket.key, bucket.limit, n)
+		if err != nil {
+			if limiter.FailClosed {
+				limiter.refund(ctx, taken, n)
+				return err
+			}
+			continue
+		}
+		if !ok {
+			limiter.refund(ctx, taken, n)
+			return &RateLimitError{Bucket: bucket.key, Limit: bucket.limit, RetryAfter: retryAfter}
+		}
+		taken = append(taken, bucket)
+	}
+	return nil
+}
+
+// refund puts n back in the buckets taken. A failed refund only leaves the
+// bucket short until it refills, so errors are ignored.
+func (limiter *RateLimiter) refund(ctx context.Context, taken []rateBucket, n int) {
+	for _, bucket := range taken {
+		_ = limiter.Store.Refund(ctx, bucket.key, bucket.limit, n)
+	}
+}
+
+// tokenDigest names the bucket of apiToken without revealing it, in the
+// same form the Redactor hashes span fields.
+func tokenDigest(apiToken string) string {
+	sum := sha256.Sum256([]byte(apiToken))
+	return "sha256:" + hex.EncodeToString(sum[:])[:12]
+}
+
+// memoryBucketSweep is how often MemoryRateLimitStore drops idle buckets.
+const memoryBucketSweep = time.Minute
+
+// MemoryRateLimitStore keeps token buckets in process, so each instance of
+// a service limits on its own. Buckets that have refilled are dropped, as a
+// new bucket would be full too, so tokens that stop calling do not stay in
+// memory.
+type MemoryRateLimitStore struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+	swept   time.Time
+}
+
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+	// full is when the bucket will have refilled to Burst
+	full time.Time
+}
+
+func (bucket *tokenBucket) set(tokens float64, limit Limit, now time.Time) {
+	bucket.tokens = tokens
+	bucket.updated = now
+	bucket.full = now.Add(time.Duration((float64(limit.Burst) - tokens) / limit.Rate * float64(time.Second)))
+}
+
+// NewMemoryRateLimitStore returns an empty MemoryRateLimitStore.
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{buckets: map[string]*tokenBucket{}}
+}
+
+// Take refills the bucket key for the time since it was last used and
+// removes n tokens when it holds enough.
+func (store *MemoryRateLimitStore) Take(ctx context.Context, key string, limit Limit, n int) (bool, time.Duration, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	now := time.Now()
+	store.sweep(now)
+	bucket, ok := store.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: float64(limit.Burst), updated: now}
+		store.buckets[key] = bucket
+	}
+	tokens := math.Min(float64(limit.Burst), bucket.tokens+now.Sub(bucket.updated).Seconds()*limit.Rate)
+
+	if tokens < float64(n) {
+		bucket.set(tokens, limit, now)
+		missing := float64(n) - tokens
+		return false, time.Duration(missing / limit.Rate * float64(time.Second)), nil
+	}
+	bucket.set(tokens-float64(n), limit, now)
+	return true, 0, nil
+}
+
+// Refund adds n tokens back to the bucket key, up to Burst.
+func (store *MemoryRateLimitStore) Refund(ctx context.Context, key string, limit Limit, n int) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if bucket, ok := store.buckets[key]; ok {
+		bucket.set(math.Min(float64(limit.Burst), bucket.tokens+float64(n)), limit, bucket.updated)
+	}
+	return nil
+}
+
+// sweep drops the buckets that have refilled, at most once a
+// memoryBucketSweep.
+func (store *MemoryRateLimitStore) sweep(now time.Time) {
+	if now.Sub(store.swept) < memoryBucketSweep {
+		return
+	}
+	store.swept = now
+	for key, bucket := range store.buckets {
+		if !now.Before(bucket.full) {
+			delete(store.buckets, key)
+		}
+	}
+}
+
+// DynamoDBRateLimitStore counts requests in a DynamoDB table shared by every
+// instance of a service. It approximates each bucket with fixed windows of
+// Burst/Rate seconds allowing Burst requests, so a check is a single
+// conditional update. The table is keyed by a string attribute "key" and
+// should have time to live enabled on the "expires" attribute.
+type DynamoDBRateLimitStore struct {
+	DynamoDB dynamodbiface.DynamoDBAPI
+	Table    string
+}
+
+// Take adds n to the counter of the current window of key unless that
+// would go over Burst.
+func (store DynamoDBRateLimitStore) Take(ctx context.Context, key string, limit Limit, n int) (bool, time.Duration, error) {
+	now := time.Now()
+	start, window := store.window(limit, now)
+	end := start.Add(window)
+
+	_, err := store.DynamoDB.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+		TableName:                aws.String(store.Table),
+		Key:                      store.key(key, start, window),
+		UpdateExpression:         aws.String("ADD #count :n SET #expires = if_not_exists(#expires, :expires)"),
+		ConditionExpression:      aws.String("attribute_not_exists(#count) OR #count <= :max"),
+		ExpressionAttributeNames: map[string]*string{"#count": aws.String("count"), "#expires": aws.String("expires")},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":n":       {N: aws.String(strconv.Itoa(n))},
+			":max":     {N: aws.String(strconv.Itoa(limit.Burst - n))},
+			":expires": {N: aws.String(strconv.FormatInt(end.Add(window).Unix(), 10))},
+		},
+	})
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) && awsErr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return false, end.Sub(now), nil
+	}
+	if err != nil {
+		return false, 0, err
+	}
+	return true, 0, nil
+}
+
+// Refund subtracts n from the counter of the current window of key. Once
+// the window the tokens were taken in has ended there is nothing to refund.
+func (store DynamoDBRateLimitStore) Refund(ctx context.Context, key string, limit Limit, n int) error {
+	start, window := store.window(limit, time.Now())
+	_, err := store.DynamoDB.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+		TableName:                aws.String(store.Table),
+		Key:                      store.key(key, start, window),
+		UpdateExpression:         aws.String("ADD #count :refund"),
+		ConditionExpression:      aws.String("#count >= :n"),
+		ExpressionAttributeNames: map[string]*string{"#count": aws.String("count")},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":refund": {N: aws.String(strconv.Itoa(-n))},
+			":n":      {N: aws.String(strconv.Itoa(n))},
+		},
+	})
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) && awsErr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return nil
+	}
+	return err
+}
+
+// window returns the start and length of the window of limit holding now.
+func (store DynamoDBRateLimitStore) window(limit Limit, now time.Time) (time.Time, time.Duration) {
+	window := time.Duration(float64(limit.Burst) / limit.Rate * float64(time.Second))
+	if window <= 0 {
+		window = time.Second
+	}
+	return now.Truncate(window), window
+}
+
+// key returns the item key of the window of key starting at start. Windows
+// are numbered by how many of them fit before start, so windows shorter
+// than a second each have their own counter.
+func (store DynamoDBRateLimitStore) key(key string, start time.Time, window time.Duration) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"key": {S: aws.String(key + "#" + strconv.FormatInt(start.UnixNano()/int64(window), 10))},
+	}
+}
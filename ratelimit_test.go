@@ -0,0 +1,336 @@
+package shareddiscovery
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/golang/mock/gomock"
+	"github.com/pgdevelopers/shareddiscovery/v2/mocks/mock_dynamodbiface"
+)
+
+// countingDiscovery is an IFace that counts the calls that reach it.
+type countingDiscovery struct {
+	calls int
+}
+
+func (counting *countingDiscovery) GetValidation(ctx context.Context, query QueryInput, opts ...CallOption) (bool, error) {
+	counting.calls++
+	return true, nil
+}
+
+func (counting *countingDiscovery) GetConfig(ctx context.Context, apiToken string, query QueryInput, opts ...CallOption) (map[string]interface{}, error) {
+	counting.calls++
+	return map[string]interface{}{"apiToken": apiToken}, nil
+}
+
+func (counting *countingDiscovery) BatchGetConfig(ctx context.Context, keys []ConfigKey, opts ...CallOption) []ConfigResult {
+	counting.calls++
+	results := make([]ConfigResult, len(keys))
+	for i, key := range keys {
+		results[i] = ConfigResult{Key: key, Config: map[string]interface{}{"apiToken": key.APIToken}}
+	}
+	return results
+}
+
+func (counting *countingDiscovery) AdminGetAPIToken(ctx context.Context, secretKey string, query QueryInput, opts ...CallOption) (string, error) {
+	counting.calls++
+	return "abc", nil
+}
+
+// failingStore is a RateLimitStore that cannot be reached.
+type failingStore struct{}
+
+func (failingStore) Take(ctx context.Context, key string, limit Limit, n int) (bool, time.Duration, error) {
+	return false, 0, errors.New("store unavailable")
+}
+
+func (failingStore) Refund(ctx context.Context, key string, limit Limit, n int) error {
+	return errors.New("store unavailable")
+}
+
+func TestRateLimiter_GetConfig(t *testing.T) {
+	var (
+		ctx     = context.TODO()
+		next    = &countingDiscovery{}
+		limits  = RateLimits{Token: Limit{Rate: 0.001, Burst: 1}, Tokens: map[string]Limit{"vip": {Rate: 0.001, Burst: 3}}}
+		limiter = NewRateLimiter(next, limits, NewMemoryRateLimitStore())
+		query   = QueryInput{Workspace: "apps"}
+	)
+
+	if _, err := limiter.GetConfig(ctx, "abc", query); err != nil {
+		t.Fatalf("GetConfig(ctx, %q) == %v, want allowed", "abc", err)
+	}
+	_, err := limiter.GetConfig(ctx, "abc", query)
+	var limited *RateLimitError
+	if !errors.As(err, &limited) || limited.Bucket != "apiToken:"+tokenDigest("abc") || limited.RetryAfter <= 0 {
+		t.Fatalf("GetConfig(ctx, %q) == %v, want a RateLimitError for the bucket of abc", "abc", err)
+	}
+	if class := ErrorClass(err); class != "rate_limited" {
+		t.Errorf("ErrorClass(%v) == %q, want %q", err, class, "rate_limited")
+	}
+
+	// the override gives vip a bucket of its own with a larger burst
+	for i := 0; i < 3; i++ {
+		if _, err := limiter.GetConfig(ctx, "vip", query); err != nil {
+			t.Errorf("GetConfig(ctx, %q) #%d == %v, want allowed", "vip", i, err)
+		}
+	}
+	if next.calls != 4 {
+		t.Errorf("%d calls reached the wrapped IFace, want 4", next.calls)
+	}
+}
+
+func TestRateLimiter_HidesToken(t *testing.T) {
+	var (
+		ctx     = context.TODO()
+		limiter = NewRateLimiter(&countingDiscovery{}, RateLimits{Token: Limit{Rate: 0.001, Burst: 1}}, NewMemoryRateLimitStore())
+		token   = "someApiToken"
+	)
+
+	limiter.GetConfig(ctx, token, QueryInput{Workspace: "apps"})
+	if _, err := limiter.GetConfig(ctx, token, QueryInput{Workspace: "apps"}); err == nil || strings.Contains(err.Error(), token) {
+		t.Errorf("GetConfig(ctx, %q) == %v, want a RateLimitError without the token", token, err)
+	}
+}
+
+func TestRateLimiter_DefaultBurst(t *testing.T) {
+	var (
+		ctx     = context.TODO()
+		limiter = NewRateLimiter(&countingDiscovery{}, RateLimits{Token: Limit{Rate: 2.5}}, NewMemoryRateLimitStore())
+		query   = QueryInput{Workspace: "apps"}
+	)
+
+	// a burst of one second of the rate, rounded up
+	for i := 0; i < 3; i++ {
+		if _, err := limiter.GetConfig(ctx, "abc", query); err != nil {
+			t.Errorf("GetConfig(ctx, %q) #%d == %v, want allowed", "abc", i, err)
+		}
+	}
+	if _, err := limiter.GetConfig(ctx, "abc", query); !errors.As(err, new(*RateLimitError)) {
+		t.Errorf("GetConfig(ctx, %q) #3 == %v, want a RateLimitError", "abc", err)
+	}
+	if limit := (Limit{Rate: 0.1}).withBurst(); limit.Burst != 1 {
+		t.Errorf("Limit{Rate: 0.1}.withBurst().Burst == %d, want 1", limit.Burst)
+	}
+}
+
+func TestRateLimiter_Brand(t *testing.T) {
+	var (
+		ctx     = context.TODO()
+		next    = &countingDiscovery{}
+		limits  = RateLimits{Brand: Limit{Rate: 0.001, Burst: 2}, Brands: map[string]Limit{"braun": {}}}
+		limiter = NewRateLimiter(next, limits, NewMemoryRateLimitStore())
+	)
+
+	oralb := QueryInput{Workspace: "apps", Brand: "oralb"}
+	limiter.GetValidation(ctx, oralb)
+	limiter.AdminGetAPIToken(ctx, "secretKey", oralb)
+	if _, err := limiter.GetConfig(ctx, "abc", oralb); !errors.As(err, new(*RateLimitError)) {
+		t.Errorf("GetConfig(ctx, %q) == %v, want a RateLimitError for brand:oralb", "abc", err)
+	}
+
+	// a zero override leaves braun unlimited
+	braun := QueryInput{Workspace: "apps", Brand: "braun"}
+	for i := 0; i < 5; i++ {
+		if _, err := limiter.GetValidation(ctx, braun); err != nil {
+			t.Errorf("GetValidation(ctx, braun) #%d == %v, want allowed", i, err)
+		}
+	}
+}
+
+func TestRateLimiter_RefundsToken(t *testing.T) {
+	var (
+		ctx     = context.TODO()
+		limits  = RateLimits{Token: Limit{Rate: 0.001, Burst: 1}, Brand: Limit{Rate: 0.001, Burst: 1}}
+		limiter = NewRateLimiter(&countingDiscovery{}, limits, NewMemoryRateLimitStore())
+	)
+
+	limiter.GetConfig(ctx, "abc", QueryInput{Workspace: "apps", Brand: "oralb"})
+	if _, err := limiter.GetConfig(ctx, "def", QueryInput{Workspace: "apps", Brand: "oralb"}); !errors.As(err, new(*RateLimitError)) {
+		t.Fatalf("GetConfig(ctx, %q) == %v, want a RateLimitError for brand:oralb", "def", err)
+	}
+	// the call refused by oralb did not use up the bucket of def
+	if _, err := limiter.GetConfig(ctx, "def", QueryInput{Workspace: "apps", Brand: "braun"}); err != nil {
+		t.Errorf("GetConfig(ctx, %q) == %v, want allowed", "def", err)
+	}
+}
+
+func TestRateLimiter_BatchGetConfig(t *testing.T) {
+	var (
+		ctx     = context.TODO()
+		next    = &countingDiscovery{}
+		limits  = RateLimits{Token: Limit{Rate: 0.001, Burst: 2}}
+		limiter = NewRateLimiter(next, limits, NewMemoryRateLimitStore())
+		keys    = []ConfigKey{
+			{Workspace: "apps", APIToken: "abc"},
+			{Workspace: "apps", APIToken: "def"},
+			{Workspace: "apps", APIToken: "abc"},
+			{Workspace: "apps", APIToken: "abc"},
+		}
+	)
+
+	// abc needs three tokens from a bucket of two, so only def is read
+	results := limiter.BatchGetConfig(ctx, keys)
+	if len(results) != len(keys) {
+		t.Fatalf("BatchGetConfig(ctx, keys) returned %d results, want %d", len(results), len(keys))
+	}
+	for i, result := range results {
+		limited := errors.As(result.Err, new(*RateLimitError))
+		if result.Key != keys[i] || limited != (keys[i].APIToken == "abc") {
+			t.Errorf("BatchGetConfig(ctx, keys)[%d] == %+v, want abc rate limited", i, result)
+		}
+		if !limited && result.Config["apiToken"] != keys[i].APIToken {
+			t.Errorf("BatchGetConfig(ctx, keys)[%d].Config == %v, want config for %v", i, result.Config, keys[i])
+		}
+	}
+}
+
+func TestRateLimiter_BatchGetConfigBrand(t *testing.T) {
+	var (
+		ctx     = context.TODO()
+		next    = &countingDiscovery{}
+		limits  = RateLimits{Token: Limit{Rate: 0.001, Burst: 5}, Brand: Limit{Rate: 0.001, Burst: 3}}
+		limiter = NewRateLimiter(next, limits, NewMemoryRateLimitStore())
+		keys    = []ConfigKey{{Workspace: "apps", APIToken: "abc"}, {Workspace: "apps", APIToken: "def"}}
+	)
+
+	// oralb allows three keys, so the second batch of two is refused
+	for i, want := range []bool{false, true} {
+		for _, result := range limiter.BatchGetConfig(ctx, keys, WithBrand("oralb")) {
+			if limited := errors.As(result.Err, new(*RateLimitError)); limited != want {
+				t.Errorf("BatchGetConfig(ctx, keys, oralb) #%d == %+v, want limited %v", i, result, want)
+			}
+		}
+	}
+	// and refunded to the tokens, which still hold three calls each
+	for i := 0; i < 3; i++ {
+		if _, err := limiter.GetConfig(ctx, "abc", QueryInput{Workspace: "apps"}); err != nil {
+			t.Errorf("GetConfig(ctx, %q) #%d == %v, want allowed", "abc", i, err)
+		}
+	}
+	if next.calls != 4 {
+		t.Errorf("%d calls reached the wrapped IFace, want 4", next.calls)
+	}
+}
+
+func TestRateLimiter_StoreFailure(t *testing.T) {
+	var (
+		ctx     = context.TODO()
+		limiter = NewRateLimiter(&countingDiscovery{}, RateLimits{Token: Limit{Rate: 1, Burst: 1}}, failingStore{})
+		query   = QueryInput{Workspace: "apps"}
+	)
+
+	if _, err := limiter.GetConfig(ctx, "abc", query); err != nil {
+		t.Errorf("GetConfig(ctx, %q) == %v, want the call let through", "abc", err)
+	}
+	limiter.FailClosed = true
+	if _, err := limiter.GetConfig(ctx, "abc", query); err == nil {
+		t.Errorf("GetConfig(ctx, %q) == nil, want the store error", "abc")
+	}
+}
+
+func TestMemoryRateLimitStore_DropsIdleBuckets(t *testing.T) {
+	var (
+		ctx   = context.TODO()
+		store = NewMemoryRateLimitStore()
+		limit = Limit{Rate: 0.001, Burst: 2}
+	)
+	store.Take(ctx, "apiToken:idle", limit, 1)
+	store.Take(ctx, "apiToken:busy", limit, 1)
+
+	// idle has refilled, and the next sweep is due
+	store.buckets["apiToken:idle"].full = time.Now().Add(-time.Second)
+	store.swept = time.Now().Add(-memoryBucketSweep)
+	store.Take(ctx, "apiToken:new", limit, 1)
+
+	if _, ok := store.buckets["apiToken:idle"]; ok || len(store.buckets) != 2 {
+		t.Errorf("buckets == %v, want idle dropped and busy and new kept", store.buckets)
+	}
+}
+
+func TestDynamoDBRateLimitStore(t *testing.T) {
+	var (
+		ctx          = context.TODO()
+		mockDynamoDB = mock_dynamodbiface.NewMockDynamoDBAPI(gomock.NewController(t))
+		store        = DynamoDBRateLimitStore{DynamoDB: mockDynamoDB, Table: "ratelimits"}
+		limit        = Limit{Rate: 10, Burst: 100}
+	)
+
+	gomock.InOrder(
+		mockDynamoDB.
+			EXPECT().
+			UpdateItemWithContext(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ aws.Context, input *dynamodb.UpdateItemInput, _ ...interface{}) (*dynamodb.UpdateItemOutput, error) {
+				if aws.StringValue(input.TableName) != "ratelimits" || !strings.HasPrefix(aws.StringValue(input.Key["key"].S), "apiToken:abc#") {
+					t.Errorf("UpdateItem(%v), want the window of apiToken:abc in ratelimits", input)
+				}
+				if max := aws.StringValue(input.ExpressionAttributeValues[":max"].N); max != "98" {
+					t.Errorf("UpdateItem :max == %s, want 98", max)
+				}
+				return &dynamodb.UpdateItemOutput{}, nil
+			}),
+		mockDynamoDB.
+			EXPECT().
+			UpdateItemWithContext(gomock.Any(), gomock.Any()).
+			Return(nil, awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "full", nil)),
+	)
+
+	if ok, _, err := store.Take(ctx, "apiToken:abc", limit, 2); !ok || err != nil {
+		t.Errorf("Take(ctx, apiToken:abc) == %v, %v, want allowed", ok, err)
+	}
+	ok, retryAfter, err := store.Take(ctx, "apiToken:abc", limit, 2)
+	if ok || err != nil || retryAfter <= 0 || retryAfter > 10*time.Second {
+		t.Errorf("Take(ctx, apiToken:abc) == %v, %v, %v, want limited until the window ends", ok, retryAfter, err)
+	}
+}
+
+func TestDynamoDBRateLimitStore_SubSecondWindows(t *testing.T) {
+	var (
+		store = DynamoDBRateLimitStore{}
+		// a Burst below the Rate makes windows of 100ms
+		limit = Limit{Rate: 10, Burst: 1}
+		now   = time.Unix(1700000000, 0)
+	)
+
+	start, window := store.window(limit, now)
+	if window != 100*time.Millisecond {
+		t.Fatalf("window(%+v) == %v, want 100ms", limit, window)
+	}
+	next, _ := store.window(limit, now.Add(window))
+	first := aws.StringValue(store.key("apiToken:abc", start, window)["key"].S)
+	second := aws.StringValue(store.key("apiToken:abc", next, window)["key"].S)
+	if first == second {
+		t.Errorf("key() of adjacent 100ms windows == %q both times, want a counter per window", first)
+	}
+	same, _ := store.window(limit, now.Add(window/2))
+	if third := aws.StringValue(store.key("apiToken:abc", same, window)["key"].S); third != first {
+		t.Errorf("key() within one window == %q and %q, want the same counter", first, third)
+	}
+}
+
+func TestDynamoDBRateLimitStore_Refund(t *testing.T) {
+	var (
+		mockDynamoDB = mock_dynamodbiface.NewMockDynamoDBAPI(gomock.NewController(t))
+		store        = DynamoDBRateLimitStore{DynamoDB: mockDynamoDB, Table: "ratelimits"}
+	)
+
+	mockDynamoDB.
+		EXPECT().
+		UpdateItemWithContext(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ aws.Context, input *dynamodb.UpdateItemInput, _ ...interface{}) (*dynamodb.UpdateItemOutput, error) {
+			if refund := aws.StringValue(input.ExpressionAttributeValues[":refund"].N); refund != "-2" {
+				t.Errorf("UpdateItem :refund == %s, want -2", refund)
+			}
+			return nil, awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "new window", nil)
+		})
+
+	if err := store.Refund(context.TODO(), "apiToken:abc", Limit{Rate: 10, Burst: 100}, 2); err != nil {
+		t.Errorf("Refund(ctx, apiToken:abc) in a new window == %v, want nil", err)
+	}
+}
@@ -0,0 +1,159 @@
+package shareddiscovery
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Redacted replaces masked values in telemetry.
+const Redacted = "[REDACTED]"
+
+// Redactor masks or hashes sensitive values before they are added to a span,
+// whichever Tracer is in use. A SharedDiscovery without a Redactor uses
+// DefaultRedactor.
+type Redactor struct {
+	// SensitiveKeys are matched case-insensitively against field names,
+	// QueryInput fields, query-string keys and config keys. A key is
+	// sensitive when it contains any of them.
+	SensitiveKeys []string
+
+	// SafeQueryKeys are the query-string keys whose values may be emitted.
+	// Every other QueryString value is treated as sensitive, since callers
+	// can put anything in there.
+	SafeQueryKeys []string
+
+	// Hash replaces sensitive values with a short SHA-256 digest instead of
+	// Redacted, so equal values can still be correlated.
+	Hash bool
+}
+
+// DefaultRedactor returns the Redactor used when none is configured. It
+// covers signatures, apiTokens and secret-bearing keys, and masks the signed
+// query-string message.
+func DefaultRedactor() *Redactor {
+	return &Redactor{
+		SensitiveKeys: []string{
+			"signature",
+			"token",
+			"secret",
+			"password",
+			"apikey",
+			"api_key",
+			"credential",
+			"private",
+			"query.string",
+		},
+		SafeQueryKeys: []string{"appName", "brand", "countryCode", "environment"},
+	}
+}
+
+// IsSensitive reports whether values stored under key must not be emitted.
+func (redactor *Redactor) IsSensitive(key string) bool {
+	key = strings.ToLower(key)
+	for _, sensitive := range redactor.SensitiveKeys {
+		if strings.Contains(key, strings.ToLower(sensitive)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Redact returns val with every sensitive part masked. key is the name val
+// is stored under.
+func (redactor *Redactor) Redact(key string, val interface{}) interface{} {
+	if redactor.IsSensitive(key) {
+		return redactor.mask(val)
+	}
+
+	switch v := val.(type) {
+	case QueryInput:
+		return redactor.redactQuery(v)
+	case *QueryInput:
+		if v == nil {
+			return v
+		}
+		return redactor.redactQuery(*v)
+	case ConfigKey:
+		v.APIToken = fmt.Sprint(redactor.mask(v.APIToken))
+		return v
+	case map[string]string:
+		redacted := make(map[string]string, len(v))
+		for k, value := range v {
+			redacted[k] = fmt.Sprint(redactor.Redact(k, value))
+		}
+		return redacted
+	case map[string]interface{}:
+		redacted := make(map[string]interface{}, len(v))
+		for k, value := range v {
+			redacted[k] = redactor.Redact(k, value)
+		}
+		return redacted
+	case []interface{}:
+		redacted := make([]interface{}, len(v))
+		for i, value := range v {
+			redacted[i] = redactor.Redact(key, value)
+		}
+		return redacted
+	default:
+		return val
+	}
+}
+
+// redactQuery masks the signature and every query-string value that is not
+// known to be safe. It returns a string so no unredacted copy escapes.
+func (redactor *Redactor) redactQuery(query QueryInput) string {
+	if query.Signature != "" {
+		query.Signature = fmt.Sprint(redactor.mask(query.Signature))
+	}
+	queryString := make(map[string]string, len(query.QueryString))
+	for key, value := range query.QueryString {
+		if redactor.isSafeQueryKey(key) && !redactor.IsSensitive(key) {
+			queryString[key] = value
+		} else {
+			queryString[key] = fmt.Sprint(redactor.mask(value))
+		}
+	}
+	query.QueryString = queryString
+	return fmt.Sprintf("%+v", query)
+}
+
+func (redactor *Redactor) isSafeQueryKey(key string) bool {
+	for _, safe := range redactor.SafeQueryKeys {
+		if strings.EqualFold(safe, key) {
+			return true
+		}
+	}
+	return false
+}
+
+func (redactor *Redactor) mask(val interface{}) interface{} {
+	if val == nil {
+		return nil
+	}
+	if !redactor.Hash {
+		return Redacted
+	}
+	sum := sha256.Sum256([]byte(fmt.Sprint(val)))
+	return "sha256:" + hex.EncodeToString(sum[:])[:12]
+}
+
+// redactingSpan redacts every field before handing it to the wrapped span.
+type redactingSpan struct {
+	Span
+	redactor *Redactor
+}
+
+func (span redactingSpan) AddField(key string, val interface{}) {
+	span.Span.AddField(key, span.redactor.Redact(key, val))
+}
+
+var defaultRedactor = DefaultRedactor()
+
+func (service SharedDiscovery) redactor() *Redactor {
+	if service.Redactor == nil {
+		return defaultRedactor
+	}
+	return service.Redactor
+}
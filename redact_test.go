@@ -0,0 +1,89 @@
+package shareddiscovery
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/golang/mock/gomock"
+	"github.com/pgdevelopers/shareddiscovery/v2/mocks/mock_dynamodbiface"
+)
+
+func TestRedaction_NothingSecretEmitted(t *testing.T) {
+	var (
+		ctx          = context.TODO()
+		mockDynamoDB = mock_dynamodbiface.NewMockDynamoDBAPI(gomock.NewController(t))
+		tracer       = &recordingTracer{}
+		self         = New(mockDynamoDB)
+		valid        = generateQueryWithAppName()
+		custom       = generateQueryWithAppName()
+		secrets      = []string{valid.Signature, "tok-1234567890", "hunter2", "client-secret-value"}
+	)
+	self.Tracer = tracer
+	custom.QueryString["apiToken"] = "tok-1234567890"
+	custom.QueryString["password"] = "hunter2"
+	custom.QueryString["clientId"] = "client-secret-value"
+
+	mockDynamoDB.
+		EXPECT().
+		QueryWithContext(gomock.Any(), gomock.Any()).
+		Return(&dynamodb.QueryOutput{Items: []map[string]*dynamodb.AttributeValue{
+			{"apiToken": {S: aws.String("tok-1234567890")}},
+		}}, nil)
+
+	_, _ = self.AdminGetAPIToken(ctx, "secretKey", valid)
+	_, _ = self.AdminGetAPIToken(ctx, "secretKey", custom)
+
+	for _, span := range tracer.spans {
+		for key, val := range span.fields {
+			emitted := fmt.Sprintf("%+v", val)
+			for _, secret := range secrets {
+				if strings.Contains(emitted, secret) {
+					t.Errorf("%s span field %s == %q, leaks %q", span.name, key, emitted, secret)
+				}
+			}
+		}
+	}
+	if query := tracer.find("validateSignature").fields["query.object"]; !strings.Contains(fmt.Sprint(query), "brand:oralb") {
+		t.Errorf("validateSignature span query.object == %v, want safe query-string values kept", query)
+	}
+}
+
+func TestRedactor_Redact(t *testing.T) {
+	redactor := DefaultRedactor()
+	tests := []struct {
+		key  string
+		val  interface{}
+		want interface{}
+	}{
+		{"workspace", "apps", "apps"},
+		{"apiToken", "abc", Redacted},
+		{"query.string", "oralbqaUS", Redacted},
+		{"key", ConfigKey{Workspace: "apps", APIToken: "abc"}, ConfigKey{Workspace: "apps", APIToken: Redacted}},
+		{
+			"config",
+			map[string]interface{}{"name": "app", "thirdParty": map[string]interface{}{"clientSecret": "abc", "url": "https://"}},
+			map[string]interface{}{"name": "app", "thirdParty": map[string]interface{}{"clientSecret": Redacted, "url": "https://"}},
+		},
+	}
+
+	for _, test := range tests {
+		if got := redactor.Redact(test.key, test.val); fmt.Sprint(got) != fmt.Sprint(test.want) {
+			t.Errorf("Redact(%q, %v) == %v, want %v", test.key, test.val, got, test.want)
+		}
+	}
+}
+
+func TestRedactor_Hash(t *testing.T) {
+	redactor := DefaultRedactor()
+	redactor.Hash = true
+
+	first := redactor.Redact("signature", "abc")
+	second := redactor.Redact("signature", "abc")
+	if first != second || first == "abc" || !strings.HasPrefix(fmt.Sprint(first), "sha256:") {
+		t.Errorf("Redact(%q, %q) == %v then %v, want the same sha256 digest", "signature", "abc", first, second)
+	}
+}
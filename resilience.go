@@ -0,0 +1,288 @@
+package shareddiscovery
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// ErrCircuitOpen is returned without calling DynamoDB while the circuit
+// breaker of a table is open.
+var ErrCircuitOpen = errors.New("shareddiscovery: circuit breaker open")
+
+// DefaultRetryableCodes are the AWS error codes retried when a
+// ResiliencePolicy does not list its own.
+var DefaultRetryableCodes = []string{
+	dynamodb.ErrCodeProvisionedThroughputExceededException,
+	dynamodb.ErrCodeRequestLimitExceeded,
+	dynamodb.ErrCodeInternalServerError,
+	dynamodb.ErrCodeTransactionInProgressException,
+	"ThrottlingException",
+	"ServiceUnavailable",
+	"RequestTimeout",
+}
+
+// ResiliencePolicy configures retries and circuit breaking around DynamoDB.
+// Zero values fall back to the values of DefaultResiliencePolicy.
+type ResiliencePolicy struct {
+	// MaxAttempts is the total number of attempts per call, including the first.
+	MaxAttempts int
+	// BaseDelay is the backoff before the first retry. It doubles on every
+	// retry, is capped at MaxDelay and is jittered.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// RetryableCodes lists the AWS error codes worth retrying.
+	RetryableCodes []string
+
+	// BudgetRatio is how many retries each successful call earns, and
+	// BudgetMax caps the retries that can be saved up. Once the budget is
+	// spent, failures are returned without retrying so a throttled table
+	// is not hit even harder.
+	BudgetRatio float64
+	BudgetMax   float64
+
+	// FailureThreshold is the number of consecutive failed calls that opens
+	// the circuit breaker of a table. The breaker stays open for OpenDuration
+	// and then lets a single call through to probe the table.
+	FailureThreshold int
+	OpenDuration     time.Duration
+}
+
+// DefaultResiliencePolicy returns the policy used for unset ResiliencePolicy fields.
+func DefaultResiliencePolicy() ResiliencePolicy {
+	return ResiliencePolicy{
+		MaxAttempts:      4,
+		BaseDelay:        25 * time.Millisecond,
+		MaxDelay:         time.Second,
+		RetryableCodes:   DefaultRetryableCodes,
+		BudgetRatio:      0.1,
+		BudgetMax:        10,
+		FailureThreshold: 5,
+		OpenDuration:     30 * time.Second,
+	}
+}
+
+// Resilience retries and circuit breaks DynamoDB calls made by a
+// SharedDiscovery. Set it on SharedDiscovery.Resilience to enable it. A
+// single Resilience should be shared so budgets and breakers see all traffic.
+//
+// The AWS SDK retries on its own as well, so consider lowering MaxRetries on
+// the DynamoDB client when enabling this.
+type Resilience struct {
+	policy    ResiliencePolicy
+	retryable map[string]bool
+
+	mu       sync.Mutex
+	budget   float64
+	breakers map[string]*breaker
+	now      func() time.Time
+}
+
+type breakerState string
+
+const (
+	breakerClosed   breakerState = "closed"
+	breakerOpen     breakerState = "open"
+	breakerHalfOpen breakerState = "half-open"
+)
+
+type breaker struct {
+	state    breakerState
+	failures int
+	openedAt time.Time
+}
+
+// NewResilience returns a Resilience for policy.
+func NewResilience(policy ResiliencePolicy) *Resilience {
+	defaults := DefaultResiliencePolicy()
+	if policy.MaxAttempts <= 0 {
+		policy.MaxAttempts = defaults.MaxAttempts
+	}
+	if policy.BaseDelay <= 0 {
+		policy.BaseDelay = defaults.BaseDelay
+	}
+	if policy.MaxDelay <= 0 {
+		policy.MaxDelay = defaults.MaxDelay
+	}
+	if policy.RetryableCodes == nil {
+		policy.RetryableCodes = defaults.RetryableCodes
+	}
+	if policy.BudgetRatio <= 0 {
+		policy.BudgetRatio = defaults.BudgetRatio
+	}
+	if policy.BudgetMax <= 0 {
+		policy.BudgetMax = defaults.BudgetMax
+	}
+	if policy.FailureThreshold <= 0 {
+		policy.FailureThreshold = defaults.FailureThreshold
+	}
+	if policy.OpenDuration <= 0 {
+		policy.OpenDuration = defaults.OpenDuration
+	}
+
+	retryable := map[string]bool{}
+	for _, code := range policy.RetryableCodes {
+		retryable[code] = true
+	}
+
+	return &Resilience{
+		policy:    policy,
+		retryable: retryable,
+		budget:    policy.BudgetMax,
+		breakers:  map[string]*breaker{},
+		now:       time.Now,
+	}
+}
+
+// IsRetryable reports whether err carries one of the policy's retryable AWS error codes.
+func (resilience *Resilience) IsRetryable(err error) bool {
+	var awsErr awserr.Error
+	if !errors.As(err, &awsErr) {
+		return false
+	}
+	return resilience.retryable[awsErr.Code()]
+}
+
+// call runs fn against table, retrying retryable errors and honouring the
+// table's circuit breaker. A nil Resilience simply runs fn once.
+func (resilience *Resilience) call(ctx context.Context, span Span, table string, fn func() error) error {
+	if resilience == nil {
+		return fn()
+	}
+
+	if !resilience.allow(table) {
+		span.AddField("circuit.state", string(breakerOpen))
+		return ErrCircuitOpen
+	}
+
+	var err error
+	attempt := 1
+	for ; ; attempt++ {
+		err = fn()
+		if err == nil || !resilience.IsRetryable(err) {
+			break
+		}
+
+		var awsErr awserr.Error
+		if errors.As(err, &awsErr) {
+			span.AddField("retry.last_error_code", awsErr.Code())
+		}
+		if attempt >= resilience.policy.MaxAttempts {
+			break
+		}
+		if !resilience.spendBudget() {
+			span.AddField("retry.budget_exhausted", true)
+			break
+		}
+		if sleepErr := sleepWithContext(ctx, resilience.delay(attempt)); sleepErr != nil {
+			break
+		}
+	}
+
+	span.AddField("retry.attempts", attempt)
+	span.AddField("circuit.state", string(resilience.record(table, err)))
+	return err
+}
+
+func (resilience *Resilience) delay(attempt int) time.Duration {
+	return backoffDelay(resilience.policy.BaseDelay, resilience.policy.MaxDelay, attempt)
+}
+
+func (resilience *Resilience) spendBudget() bool {
+	resilience.mu.Lock()
+	defer resilience.mu.Unlock()
+	if resilience.budget < 1 {
+		return false
+	}
+	resilience.budget--
+	return true
+}
+
+// allow reports whether a call against table may proceed, moving an open
+// breaker to half-open once OpenDuration has passed.
+func (resilience *Resilience) allow(table string) bool {
+	resilience.mu.Lock()
+	defer resilience.mu.Unlock()
+
+	b := resilience.breaker(table)
+	switch b.state {
+	case breakerOpen:
+		if resilience.now().Sub(b.openedAt) < resilience.policy.OpenDuration {
+			return false
+		}
+		b.state = breakerHalfOpen
+		return true
+	case breakerHalfOpen:
+		// a probe is already in flight
+		return false
+	default:
+		return true
+	}
+}
+
+// record updates the breaker and budget of table with the outcome of a call.
+// Only failures of the table count against the breaker; a bad request says
+// nothing about its health.
+func (resilience *Resilience) record(table string, err error) breakerState {
+	resilience.mu.Lock()
+	defer resilience.mu.Unlock()
+
+	b := resilience.breaker(table)
+	if err == nil || !resilience.isFailure(err) {
+		b.state = breakerClosed
+		b.failures = 0
+		if err == nil {
+			resilience.budget += resilience.policy.BudgetRatio
+			if resilience.budget > resilience.policy.BudgetMax {
+				resilience.budget = resilience.policy.BudgetMax
+			}
+		}
+		return b.state
+	}
+
+	b.failures++
+	if b.state == breakerHalfOpen || b.failures >= resilience.policy.FailureThreshold {
+		b.state = breakerOpen
+		b.openedAt = resilience.now()
+	}
+	return b.state
+}
+
+// isFailure reports whether err says the table is unhealthy: a retryable
+// error, a timeout or a network error. Calls canceled by the caller do not
+// count.
+func (resilience *Resilience) isFailure(err error) bool {
+	if resilience.IsRetryable(err) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) {
+		switch awsErr.Code() {
+		case request.ErrCodeRequestError, request.ErrCodeResponseTimeout:
+			return true
+		case request.CanceledErrorCode:
+			// the SDK reports an expired context as a canceled request
+			return errors.Is(awsErr.OrigErr(), context.DeadlineExceeded)
+		}
+	}
+	return false
+}
+
+func (resilience *Resilience) breaker(table string) *breaker {
+	b, ok := resilience.breakers[table]
+	if !ok {
+		b = &breaker{state: breakerClosed}
+		resilience.breakers[table] = b
+	}
+	return b
+}
@@ -0,0 +1,166 @@
+package shareddiscovery
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/golang/mock/gomock"
+	"github.com/pgdevelopers/shareddiscovery/v2/mocks/mock_dynamodbiface"
+)
+
+var errThrottled = awserr.New(dynamodb.ErrCodeProvisionedThroughputExceededException, "slow down", nil)
+
+func TestResilience_RetriesThrottling(t *testing.T) {
+	var (
+		ctx          = context.TODO()
+		mockDynamoDB = mock_dynamodbiface.NewMockDynamoDBAPI(gomock.NewController(t))
+		self         = New(mockDynamoDB)
+		token        = "apiToken"
+		value        = "value"
+		query        = QueryInput{Workspace: "apps"}
+	)
+	self.Resilience = NewResilience(ResiliencePolicy{BaseDelay: time.Millisecond})
+
+	gomock.InOrder(
+		mockDynamoDB.EXPECT().GetItemWithContext(gomock.Any(), gomock.Any()).Return(nil, errThrottled).Times(2),
+		mockDynamoDB.EXPECT().GetItemWithContext(gomock.Any(), gomock.Any()).Return(&dynamodb.GetItemOutput{Item: map[string]*dynamodb.AttributeValue{
+			"field": {S: &value},
+		}}, nil),
+	)
+
+	if _, err := self.GetConfig(ctx, token, query); err != nil {
+		t.Errorf("GetConfig(ctx, %q, %q) == %q, want nil", token, query.Workspace, err)
+	}
+}
+
+func TestResilience_DoesNotRetryOtherErrors(t *testing.T) {
+	var (
+		ctx          = context.TODO()
+		mockDynamoDB = mock_dynamodbiface.NewMockDynamoDBAPI(gomock.NewController(t))
+		self         = New(mockDynamoDB)
+		query        = QueryInput{Workspace: "apps"}
+		want         = awserr.New("ValidationException", "bad key", nil)
+	)
+	self.Resilience = NewResilience(ResiliencePolicy{BaseDelay: time.Millisecond})
+
+	mockDynamoDB.EXPECT().GetItemWithContext(gomock.Any(), gomock.Any()).Return(nil, want).Times(1)
+
+	if _, err := self.GetConfig(ctx, "apiToken", query); err != want {
+		t.Errorf("GetConfig(ctx, %q, %q) == %v, want %q", "apiToken", query.Workspace, err, want)
+	}
+}
+
+func TestResilience_GivesUpAfterMaxAttempts(t *testing.T) {
+	var (
+		ctx          = context.TODO()
+		mockDynamoDB = mock_dynamodbiface.NewMockDynamoDBAPI(gomock.NewController(t))
+		self         = New(mockDynamoDB)
+		query        = QueryInput{Workspace: "apps"}
+	)
+	self.Resilience = NewResilience(ResiliencePolicy{MaxAttempts: 3, BaseDelay: time.Millisecond})
+
+	mockDynamoDB.EXPECT().GetItemWithContext(gomock.Any(), gomock.Any()).Return(nil, errThrottled).Times(3)
+
+	if _, err := self.GetConfig(ctx, "apiToken", query); err != errThrottled {
+		t.Errorf("GetConfig(ctx, %q, %q) == %v, want %q", "apiToken", query.Workspace, err, errThrottled)
+	}
+}
+
+func TestResilience_RetryBudget(t *testing.T) {
+	var (
+		ctx          = context.TODO()
+		mockDynamoDB = mock_dynamodbiface.NewMockDynamoDBAPI(gomock.NewController(t))
+		self         = New(mockDynamoDB)
+		query        = QueryInput{Workspace: "apps"}
+	)
+	self.Resilience = NewResilience(ResiliencePolicy{MaxAttempts: 5, BaseDelay: time.Millisecond, BudgetMax: 2, FailureThreshold: 100})
+
+	// the first call spends the budget of two retries, the second cannot retry at all
+	mockDynamoDB.EXPECT().GetItemWithContext(gomock.Any(), gomock.Any()).Return(nil, errThrottled).Times(4)
+
+	for i := 0; i < 2; i++ {
+		if _, err := self.GetConfig(ctx, "apiToken", query); err != errThrottled {
+			t.Errorf("GetConfig(ctx, %q, %q) == %v, want %q", "apiToken", query.Workspace, err, errThrottled)
+		}
+	}
+}
+
+func TestResilience_CircuitBreaker(t *testing.T) {
+	var (
+		ctx          = context.TODO()
+		mockDynamoDB = mock_dynamodbiface.NewMockDynamoDBAPI(gomock.NewController(t))
+		self         = New(mockDynamoDB)
+		query        = QueryInput{Workspace: "apps"}
+		other        = QueryInput{Workspace: "firmware"}
+		now          = time.Now()
+	)
+	self.Resilience = NewResilience(ResiliencePolicy{MaxAttempts: 1, FailureThreshold: 2, OpenDuration: time.Minute})
+	self.Resilience.now = func() time.Time { return now }
+
+	mockDynamoDB.EXPECT().GetItemWithContext(gomock.Any(), &dynamodb.GetItemInput{TableName: &query.Workspace, Key: keyAttributes(ConfigKey{APIToken: "apiToken"})}).Return(nil, errThrottled).Times(2)
+	for i := 0; i < 3; i++ {
+		_, _ = self.GetConfig(ctx, "apiToken", query)
+	}
+	if _, err := self.GetConfig(ctx, "apiToken", query); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("GetConfig(ctx, %q, %q) == %v, want %q", "apiToken", query.Workspace, err, ErrCircuitOpen)
+	}
+
+	// other tables are unaffected
+	mockDynamoDB.EXPECT().GetItemWithContext(gomock.Any(), &dynamodb.GetItemInput{TableName: &other.Workspace, Key: keyAttributes(ConfigKey{APIToken: "apiToken"})}).Return(&dynamodb.GetItemOutput{}, nil)
+	if _, err := self.GetConfig(ctx, "apiToken", other); err != nil {
+		t.Errorf("GetConfig(ctx, %q, %q) == %q, want nil", "apiToken", other.Workspace, err)
+	}
+
+	// once OpenDuration passes a probe is let through and closes the breaker
+	now = now.Add(time.Minute)
+	mockDynamoDB.EXPECT().GetItemWithContext(gomock.Any(), gomock.Any()).Return(&dynamodb.GetItemOutput{}, nil).Times(2)
+	for i := 0; i < 2; i++ {
+		if _, err := self.GetConfig(ctx, "apiToken", query); err != nil {
+			t.Errorf("GetConfig(ctx, %q, %q) == %q, want nil", "apiToken", query.Workspace, err)
+		}
+	}
+}
+
+func TestBackoffDelay_Capped(t *testing.T) {
+	for _, attempt := range []int{1, 5, 40, 64, 1000} {
+		delay := backoffDelay(25*time.Millisecond, time.Second, attempt)
+		if delay < 0 || delay > time.Second {
+			t.Errorf("backoffDelay(25ms, 1s, %d) == %v, want at most 1s", attempt, delay)
+		}
+	}
+	if delay := backoffDelay(25*time.Millisecond, time.Second, 1000); delay < time.Second/2 {
+		t.Errorf("backoffDelay(25ms, 1s, 1000) == %v, want at least 500ms", delay)
+	}
+}
+
+func TestResilience_TimeoutsOpenBreaker(t *testing.T) {
+	resilience := NewResilience(ResiliencePolicy{MaxAttempts: 1, FailureThreshold: 2})
+	failures := []error{
+		context.DeadlineExceeded,
+		awserr.New(request.CanceledErrorCode, "request context canceled", context.DeadlineExceeded),
+		awserr.New(request.ErrCodeRequestError, "send request failed", errors.New("connection reset")),
+	}
+
+	for _, failure := range failures {
+		resilience.breakers = map[string]*breaker{}
+		for i := 0; i < 2; i++ {
+			resilience.record("apps", failure)
+		}
+		if state := resilience.breaker("apps").state; state != breakerOpen {
+			t.Errorf("breaker after two %v == %s, want %s", failure, state, breakerOpen)
+		}
+	}
+
+	resilience.breakers = map[string]*breaker{}
+	for i := 0; i < 2; i++ {
+		resilience.record("apps", context.Canceled)
+	}
+	if state := resilience.breaker("apps").state; state != breakerClosed {
+		t.Errorf("breaker after two cancellations == %s, want %s", state, breakerClosed)
+	}
+}
@@ -2,3 +2,7 @@ aws_sdk_version="v1.40.59"
 
 
 mockgen -source=${GOPATH}/pkg/mod/github.com/aws/aws-sdk-go@${aws_sdk_version}/service/dynamodb/dynamodbiface/interface.go -destination=mocks/mock_dynamodbiface/main.go
+mockgen -source=${GOPATH}/pkg/mod/github.com/aws/aws-sdk-go@${aws_sdk_version}/service/dynamodbstreams/dynamodbstreamsiface/interface.go -destination=mocks/mock_dynamodbstreamsiface/main.go
+mockgen -source=${GOPATH}/pkg/mod/github.com/aws/aws-sdk-go@${aws_sdk_version}/service/secretsmanager/secretsmanageriface/interface.go -destination=mocks/mock_secretsmanageriface/main.go
+mockgen -source=${GOPATH}/pkg/mod/github.com/aws/aws-sdk-go@${aws_sdk_version}/service/ssm/ssmiface/interface.go -destination=mocks/mock_ssmiface/main.go
+mockgen -source=${GOPATH}/pkg/mod/github.com/aws/aws-sdk-go@${aws_sdk_version}/service/kms/kmsiface/interface.go -destination=mocks/mock_kmsiface/main.go
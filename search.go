@@ -0,0 +1,516 @@
+package shareddiscovery
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go/service/dynamodb/expression"
+)
+
+// Operator compares an attribute in a Predicate.
+type Operator string
+
+// The operators a Predicate can use. The comparisons, OpBetween and
+// OpBeginsWith can also serve as the range key condition of a query.
+const (
+	OpEqual            Operator = "="
+	OpNotEqual         Operator = "<>"
+	OpLessThan         Operator = "<"
+	OpLessThanEqual    Operator = "<="
+	OpGreaterThan      Operator = ">"
+	OpGreaterThanEqual Operator = ">="
+	OpBetween          Operator = "between"
+	OpBeginsWith       Operator = "begins_with"
+	OpContains         Operator = "contains"
+	OpExists           Operator = "exists"
+)
+
+// Predicate is a condition on one attribute of the items Search returns.
+// Build predicates with Equal, BeginsWith and the other constructors.
+type Predicate struct {
+	Attribute string        `json:"attribute"`
+	Op        Operator      `json:"op"`
+	Values    []interface{} `json:"values,omitempty"`
+}
+
+// Equal matches items whose attribute equals value.
+func Equal(attribute string, value interface{}) Predicate {
+	return Predicate{Attribute: attribute, Op: OpEqual, Values: []interface{}{value}}
+}
+
+// NotEqual matches items whose attribute is missing or differs from value.
+func NotEqual(attribute string, value interface{}) Predicate {
+	return Predicate{Attribute: attribute, Op: OpNotEqual, Values: []interface{}{value}}
+}
+
+// LessThan matches items whose attribute is less than value.
+func LessThan(attribute string, value interface{}) Predicate {
+	return Predicate{Attribute: attribute, Op: OpLessThan, Values: []interface{}{value}}
+}
+
+// LessThanEqual matches items whose attribute is at most value.
+func LessThanEqual(attribute string, value interface{}) Predicate {
+	return Predicate{Attribute: attribute, Op: OpLessThanEqual, Values: []interface{}{value}}
+}
+
+// GreaterThan matches items whose attribute is greater than value.
+func GreaterThan(attribute string, value interface{}) Predicate {
+	return Predicate{Attribute: attribute, Op: OpGreaterThan, Values: []interface{}{value}}
+}
+
+// GreaterThanEqual matches items whose attribute is at least value.
+func GreaterThanEqual(attribute string, value interface{}) Predicate {
+	return Predicate{Attribute: attribute, Op: OpGreaterThanEqual, Values: []interface{}{value}}
+}
+
+// Between matches items whose attribute is from lower to upper inclusive.
+func Between(attribute string, lower, upper interface{}) Predicate {
+	return Predicate{Attribute: attribute, Op: OpBetween, Values: []interface{}{lower, upper}}
+}
+
+// BeginsWith matches items whose string attribute starts with prefix.
+func BeginsWith(attribute, prefix string) Predicate {
+	return Predicate{Attribute: attribute, Op: OpBeginsWith, Values: []interface{}{prefix}}
+}
+
+// Contains matches items whose string attribute contains substr, or whose
+// set or list attribute has the member substr.
+func Contains(attribute, substr string) Predicate {
+	return Predicate{Attribute: attribute, Op: OpContains, Values: []interface{}{substr}}
+}
+
+// Exists matches items that have the attribute.
+func Exists(attribute string) Predicate {
+	return Predicate{Attribute: attribute, Op: OpExists}
+}
+
+// SearchInput selects the items of Workspace matching every predicate.
+// Limit caps the items of one page and defaults to 100. Cursor continues
+// from the page a previous Search with the same workspace and predicates
+// returned.
+type SearchInput struct {
+	Workspace  string
+	Predicates []Predicate
+	Limit      int64
+	Cursor     string
+}
+
+// SearchResult is a page of Search results. Cursor is empty on the last
+// page. Index names the index that was queried, and is empty when the table
+// itself was queried or Scan reports the search fell back to a scan.
+type SearchResult struct {
+	Items  []map[string]interface{} `json:"items"`
+	Cursor string                   `json:"cursor,omitempty"`
+	Index  string                   `json:"index,omitempty"`
+	Scan   bool                     `json:"scan,omitempty"`
+}
+
+const defaultSearchLimit = 100
+
+// Search returns the items of a workspace matching every predicate of
+// input, a page at a time. When the predicates fix the hash key of the
+// table or of one of its indexes, and possibly constrain its range key,
+// that is queried and the other predicates filter the results. Otherwise
+// the workspace is scanned, which reads every item. Items are returned as
+// stored, so encrypted attributes stay encrypted and cannot be matched.
+//
+// The cursor of each page is encrypted with a key derived from CursorKey and
+// is only accepted for the search that returned it.
+func (service SharedDiscovery) Search(ctx context.Context, input SearchInput, opts ...CallOption) (result SearchResult, err error) {
+	ctx, searchSpan := service.startSpan(ctx, "Search")
+	defer func(start time.Time) {
+		service.observe(ctx, "Search", input.Workspace, start, err)
+		finishSpan(searchSpan, err)
+	}(time.Now())
+	options := newCallOptions(opts)
+	ctx, cancel := options.context(ctx)
+	defer cancel()
+	searchSpan.AddField("search.predicates", len(input.Predicates))
+
+	plan, err := service.planSearch(input)
+	if err != nil {
+		searchSpan.AddField("error.message", err.Error())
+		return SearchResult{}, err
+	}
+	result.Index, result.Scan = plan.index, plan.scan
+	searchSpan.AddField("search.index", plan.index)
+	searchSpan.AddField("search.scan", plan.scan)
+
+	startKey, err := service.openCursor(input.Cursor, plan.fingerprint)
+	if err != nil {
+		searchSpan.AddField("error.message", err.Error())
+		return SearchResult{}, err
+	}
+
+	limit := input.Limit
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	result.Items = []map[string]interface{}{}
+	for {
+		// DynamoDB limits the items evaluated rather than matched, so a page
+		// never holds more than the items still wanted
+		items, lastKey, err := service.searchPage(ctx, searchSpan, input.Workspace, plan, startKey, limit-int64(len(result.Items)), options)
+		if err != nil {
+			searchSpan.AddField("error.message", err.Error())
+			return SearchResult{}, err
+		}
+		var page []map[string]interface{}
+		if err = dynamodbattribute.UnmarshalListOfMaps(items, &page); err != nil {
+			searchSpan.AddField("error.message", err.Error())
+			return SearchResult{}, err
+		}
+		result.Items = append(result.Items, page...)
+		startKey = lastKey
+		if len(lastKey) == 0 || int64(len(result.Items)) >= limit {
+			break
+		}
+	}
+	if len(startKey) > 0 {
+		if result.Cursor, err = service.sealCursor(startKey, plan.fingerprint); err != nil {
+			searchSpan.AddField("error.message", err.Error())
+			return SearchResult{}, err
+		}
+	}
+	searchSpan.AddField("search.items", len(result.Items))
+	return result, nil
+}
+
+// searchPlan is how Search reads a workspace. fingerprint identifies the
+// search so cursors cannot be replayed against another.
+type searchPlan struct {
+	index       string
+	scan        bool
+	keyCond     *expression.KeyConditionBuilder
+	filter      *expression.ConditionBuilder
+	fingerprint []byte
+}
+
+// planSearch picks the table or index whose keys the predicates constrain
+// most, preferring the table on a tie, and compiles the predicates.
+func (service SharedDiscovery) planSearch(input SearchInput) (searchPlan, error) {
+	if input.Workspace == "" {
+		return searchPlan{}, &ValidationError{Field: "workspace", Reason: "is required"}
+	}
+	for _, predicate := range input.Predicates {
+		if err := predicate.validate(); err != nil {
+			return searchPlan{}, err
+		}
+	}
+	encoded, err := json.Marshal(struct {
+		Workspace  string
+		Predicates []Predicate
+	}{input.Workspace, input.Predicates})
+	if err != nil {
+		return searchPlan{}, err
+	}
+	sum := sha256.Sum256(encoded)
+	plan := searchPlan{fingerprint: sum[:]}
+
+	definition := WorkspaceTable
+	for _, table := range service.tables() {
+		if table.name == input.Workspace {
+			definition = table.definition
+		}
+	}
+	candidates := []IndexDefinition{{HashKey: definition.HashKey, RangeKey: definition.RangeKey}}
+	candidates = append(candidates, definition.Indexes...)
+
+	best, bestHash, bestRange, bestScore := IndexDefinition{}, -1, -1, 0
+	for _, candidate := range candidates {
+		hash, rng, score := matchKeys(input.Predicates, candidate)
+		if score > bestScore {
+			best, bestHash, bestRange, bestScore = candidate, hash, rng, score
+		}
+	}
+
+	var filter []expression.ConditionBuilder
+	for i, predicate := range input.Predicates {
+		if i != bestHash && i != bestRange {
+			filter = append(filter, predicate.condition())
+		}
+	}
+	switch len(filter) {
+	case 0:
+	case 1:
+		plan.filter = &filter[0]
+	default:
+		combined := expression.And(filter[0], filter[1], filter[2:]...)
+		plan.filter = &combined
+	}
+
+	if bestScore == 0 {
+		plan.scan = true
+		return plan, nil
+	}
+	plan.index = best.Name
+	keyCond := expression.Key(best.HashKey).Equal(expression.Value(input.Predicates[bestHash].Values[0]))
+	if bestRange >= 0 {
+		keyCond = keyCond.And(input.Predicates[bestRange].keyCondition())
+	}
+	plan.keyCond = &keyCond
+	return plan, nil
+}
+
+// matchKeys returns the predicates fixing the hash key of index and
+// constraining its range key, or -1, and how useful they are: 0 when the
+// hash key is not fixed, 1 for the hash key and 2 with the range key.
+func matchKeys(predicates []Predicate, index IndexDefinition) (hash, rng, score int) {
+	hash, rng = -1, -1
+	for i, predicate := range predicates {
+		switch {
+		case hash < 0 && predicate.Attribute == index.HashKey && predicate.Op == OpEqual:
+			hash = i
+		case rng < 0 && index.RangeKey != "" && predicate.Attribute == index.RangeKey && predicate.keyOperator():
+			rng = i
+		}
+	}
+	switch {
+	case hash < 0:
+		return -1, -1, 0
+	case rng < 0:
+		return hash, rng, 1
+	default:
+		return hash, rng, 2
+	}
+}
+
+func (predicate Predicate) validate() error {
+	invalid := func(reason string) error {
+		return &ValidationError{Field: "predicate", Value: predicate.Attribute, Reason: reason}
+	}
+	if predicate.Attribute == "" {
+		return invalid("names no attribute")
+	}
+	want := 1
+	switch predicate.Op {
+	case OpEqual, OpNotEqual, OpLessThan, OpLessThanEqual, OpGreaterThan, OpGreaterThanEqual:
+	case OpBetween:
+		want = 2
+	case OpBeginsWith, OpContains:
+		if len(predicate.Values) == 1 {
+			if _, ok := predicate.Values[0].(string); !ok {
+				return invalid(fmt.Sprintf("%s needs a string", predicate.Op))
+			}
+		}
+	case OpExists:
+		want = 0
+	default:
+		return invalid(fmt.Sprintf("unknown operator %q", predicate.Op))
+	}
+	if len(predicate.Values) != want {
+		return invalid(fmt.Sprintf("%s takes %d values, got %d", predicate.Op, want, len(predicate.Values)))
+	}
+	return nil
+}
+
+// keyOperator reports whether predicate can be a range key condition.
+func (predicate Predicate) keyOperator() bool {
+	switch predicate.Op {
+	case OpEqual, OpLessThan, OpLessThanEqual, OpGreaterThan, OpGreaterThanEqual, OpBetween, OpBeginsWith:
+		return true
+	}
+	return false
+}
+
+func (predicate Predicate) keyCondition() expression.KeyConditionBuilder {
+	key := expression.Key(predicate.Attribute)
+	values := predicate.Values
+	switch predicate.Op {
+	case OpLessThan:
+		return key.LessThan(expression.Value(values[0]))
+	case OpLessThanEqual:
+		return key.LessThanEqual(expression.Value(values[0]))
+	case OpGreaterThan:
+		return key.GreaterThan(expression.Value(values[0]))
+	case OpGreaterThanEqual:
+		return key.GreaterThanEqual(expression.Value(values[0]))
+	case OpBetween:
+		return key.Between(expression.Value(values[0]), expression.Value(values[1]))
+	case OpBeginsWith:
+		return key.BeginsWith(values[0].(string))
+	default: // OpEqual
+		return key.Equal(expression.Value(values[0]))
+	}
+}
+
+func (predicate Predicate) condition() expression.ConditionBuilder {
+	name := expression.Name(predicate.Attribute)
+	values := predicate.Values
+	switch predicate.Op {
+	case OpNotEqual:
+		return name.NotEqual(expression.Value(values[0]))
+	case OpLessThan:
+		return name.LessThan(expression.Value(values[0]))
+	case OpLessThanEqual:
+		return name.LessThanEqual(expression.Value(values[0]))
+	case OpGreaterThan:
+		return name.GreaterThan(expression.Value(values[0]))
+	case OpGreaterThanEqual:
+		return name.GreaterThanEqual(expression.Value(values[0]))
+	case OpBetween:
+		return name.Between(expression.Value(values[0]), expression.Value(values[1]))
+	case OpBeginsWith:
+		return name.BeginsWith(values[0].(string))
+	case OpContains:
+		return name.Contains(values[0].(string))
+	case OpExists:
+		return name.AttributeExists()
+	default: // OpEqual
+		return name.Equal(expression.Value(values[0]))
+	}
+}
+
+// searchPage reads up to limit items of plan starting after startKey.
+func (service SharedDiscovery) searchPage(ctx context.Context, span Span, workspace string, plan searchPlan, startKey map[string]*dynamodb.AttributeValue, limit int64, options callOptions) ([]map[string]*dynamodb.AttributeValue, map[string]*dynamodb.AttributeValue, error) {
+	builder := expression.NewBuilder()
+	if plan.keyCond != nil {
+		builder = builder.WithKeyCondition(*plan.keyCond)
+	}
+	if plan.filter != nil {
+		builder = builder.WithFilter(*plan.filter)
+	}
+	var projection *string
+	if len(options.fields) > 0 {
+		var names []expression.NameBuilder
+		for _, field := range options.fields {
+			names = append(names, expression.Name(field))
+		}
+		builder = builder.WithProjection(expression.NamesList(names[0], names[1:]...))
+	}
+	var expr expression.Expression
+	if plan.keyCond != nil || plan.filter != nil || len(options.fields) > 0 {
+		var err error
+		if expr, err = builder.Build(); err != nil {
+			return nil, nil, err
+		}
+		projection = expr.Projection()
+	}
+
+	output, err := service.read(ctx, span, "Search", workspace, func(ctx context.Context, db dynamodbiface.DynamoDBAPI) (interface{}, error) {
+		if plan.scan {
+			return db.ScanWithContext(ctx, &dynamodb.ScanInput{
+				TableName:                 aws.String(workspace),
+				FilterExpression:          expr.Filter(),
+				ProjectionExpression:      projection,
+				ExpressionAttributeNames:  expr.Names(),
+				ExpressionAttributeValues: expr.Values(),
+				ExclusiveStartKey:         startKey,
+				Limit:                     aws.Int64(limit),
+				ConsistentRead:            options.consistent(),
+				ReturnConsumedCapacity:    options.returnCapacity(),
+			})
+		}
+		input := &dynamodb.QueryInput{
+			TableName:                 aws.String(workspace),
+			KeyConditionExpression:    expr.KeyCondition(),
+			FilterExpression:          expr.Filter(),
+			ProjectionExpression:      projection,
+			ExpressionAttributeNames:  expr.Names(),
+			ExpressionAttributeValues: expr.Values(),
+			ExclusiveStartKey:         startKey,
+			Limit:                     aws.Int64(limit),
+			ReturnConsumedCapacity:    options.returnCapacity(),
+		}
+		// global secondary indexes cannot be read consistently
+		if plan.index != "" {
+			input.IndexName = aws.String(plan.index)
+		} else {
+			input.ConsistentRead = options.consistent()
+		}
+		return db.QueryWithContext(ctx, input)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if plan.scan {
+		page := output.(*dynamodb.ScanOutput)
+		service.observeCapacity(ctx, "Search", page.ConsumedCapacity)
+		options.addCapacity(page.ConsumedCapacity)
+		return page.Items, page.LastEvaluatedKey, nil
+	}
+	page := output.(*dynamodb.QueryOutput)
+	service.observeCapacity(ctx, "Search", page.ConsumedCapacity)
+	options.addCapacity(page.ConsumedCapacity)
+	return page.Items, page.LastEvaluatedKey, nil
+}
+
+var (
+	processCursorKey     []byte
+	processCursorKeyOnce sync.Once
+)
+
+// cursorKey returns CursorKey, or a random key made once per process when it
+// is not set.
+func (service SharedDiscovery) cursorKey() []byte {
+	if len(service.CursorKey) > 0 {
+		return service.CursorKey
+	}
+	processCursorKeyOnce.Do(func() {
+		processCursorKey = make([]byte, 32)
+		if _, err := rand.Read(processCursorKey); err != nil {
+			panic(fmt.Sprintf("shareddiscovery: generating cursor key: %v", err))
+		}
+	})
+	return processCursorKey
+}
+
+// cursorLabel derives the cursor encryption key from the cursor key, so
+// CursorKey can be any length and is never used as an AES key directly.
+const cursorLabel = "shareddiscovery search cursor"
+
+// cursorCipherKey returns the AES-256 key cursors are encrypted with.
+func (service SharedDiscovery) cursorCipherKey() []byte {
+	mac := hmac.New(sha256.New, service.cursorKey())
+	mac.Write([]byte(cursorLabel))
+	return mac.Sum(nil)
+}
+
+// sealCursor encrypts key with AES-GCM, authenticating the fingerprint of
+// the search as additional data, so a cursor reveals nothing of the item it
+// continues after and cannot be replayed against another search.
+func (service SharedDiscovery) sealCursor(key map[string]*dynamodb.AttributeValue, fingerprint []byte) (string, error) {
+	payload, err := json.Marshal(key)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := seal(service.cursorCipherKey(), payload, fingerprint)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// openCursor returns the key cursor continues after, or nil for an empty
+// cursor. A cursor that was altered, encrypted with another key or returned
+// by another search is a *ValidationError.
+func (service SharedDiscovery) openCursor(cursor string, fingerprint []byte) (map[string]*dynamodb.AttributeValue, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+	invalid := &ValidationError{Field: "cursor", Value: cursor, Reason: "not issued for this search"}
+	sealed, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, invalid
+	}
+	payload, err := open(service.cursorCipherKey(), sealed, fingerprint)
+	if err != nil {
+		return nil, invalid
+	}
+	var key map[string]*dynamodb.AttributeValue
+	if err := json.Unmarshal(payload, &key); err != nil {
+		return nil, invalid
+	}
+	return key, nil
+}
@@ -0,0 +1,172 @@
+package shareddiscovery
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/pgdevelopers/shareddiscovery/v2/discoverytest"
+)
+
+// newSearchDiscovery returns a SharedDiscovery on an in-memory discovery_app
+// holding apps on two SDK versions.
+func newSearchDiscovery(t *testing.T) SharedDiscovery {
+	db := discoverytest.NewDynamoDB()
+	db.Seed(t, DiscoveryAppTable.CreateTableInput("discovery_app"),
+		map[string]interface{}{"apiToken": "a", "appName": "sonos", "countryCode": "US", "environment": "prod", "sdkVersion": "2.1", "build": 7},
+		map[string]interface{}{"apiToken": "b", "appName": "sonos", "countryCode": "DE", "environment": "prod", "sdkVersion": "1.9", "build": 3},
+		map[string]interface{}{"apiToken": "c", "appName": "razor", "countryCode": "US", "environment": "qa", "sdkVersion": "2.1", "build": 5},
+		map[string]interface{}{"apiToken": "d", "appName": "brush", "countryCode": "FR", "environment": "prod", "sdkVersion": "2.1", "build": 9},
+		map[string]interface{}{"apiToken": "e", "appName": "floss", "countryCode": "US", "environment": "prod", "sdkVersion": "2.0", "build": 1},
+	)
+	self := New(db)
+	self.CursorKey = []byte("cursorKey")
+	return self
+}
+
+func tokensOf(items []map[string]interface{}) []string {
+	tokens := []string{}
+	for _, item := range items {
+		tokens = append(tokens, item["apiToken"].(string))
+	}
+	sort.Strings(tokens)
+	return tokens
+}
+
+func TestSearch(t *testing.T) {
+	self := newSearchDiscovery(t)
+	tests := []struct {
+		name       string
+		predicates []Predicate
+		index      string
+		scan       bool
+		tokens     []string
+	}{
+		{"by index", []Predicate{Equal("appName", "sonos")}, "appNameCountryIndex", false, []string{"a", "b"}},
+		{"by index and range key", []Predicate{Equal("appName", "sonos"), BeginsWith("countryCode", "U")}, "appNameCountryIndex", false, []string{"a"}},
+		{"by table key", []Predicate{Equal("apiToken", "c"), Equal("environment", "qa")}, "", false, []string{"c"}},
+		{"scan", []Predicate{Equal("sdkVersion", "2.1"), GreaterThan("build", 5)}, "", true, []string{"a", "d"}},
+		{"scan everything", nil, "", true, []string{"a", "b", "c", "d", "e"}},
+		{"filters", []Predicate{Between("build", 2, 8), NotEqual("environment", "qa"), Contains("sdkVersion", "."), Exists("appName")}, "", true, []string{"a", "b"}},
+	}
+
+	for _, test := range tests {
+		result, err := self.Search(context.TODO(), SearchInput{Workspace: "discovery_app", Predicates: test.predicates})
+		if err != nil {
+			t.Errorf("%s: Search(ctx, %v) == %v", test.name, test.predicates, err)
+			continue
+		}
+		if result.Index != test.index || result.Scan != test.scan || result.Cursor != "" {
+			t.Errorf("%s: Search(ctx, %v) read index %q, scan %v, cursor %q, want index %q, scan %v and no cursor", test.name, test.predicates, result.Index, result.Scan, result.Cursor, test.index, test.scan)
+		}
+		if tokens := tokensOf(result.Items); !equalStrings(tokens, test.tokens) {
+			t.Errorf("%s: Search(ctx, %v) found %v, want %v", test.name, test.predicates, tokens, test.tokens)
+		}
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSearch_Cursor(t *testing.T) {
+	var (
+		ctx   = context.TODO()
+		self  = newSearchDiscovery(t)
+		input = SearchInput{Workspace: "discovery_app", Predicates: []Predicate{Equal("sdkVersion", "2.1")}, Limit: 2}
+		found []map[string]interface{}
+		pages int
+	)
+
+	for {
+		result, err := self.Search(ctx, input)
+		if err != nil {
+			t.Fatalf("Search(ctx, page %d) == %v", pages, err)
+		}
+		if len(result.Items) > 2 {
+			t.Errorf("Search(ctx, page %d) returned %d items, want at most 2", pages, len(result.Items))
+		}
+		found = append(found, result.Items...)
+		pages++
+		if result.Cursor == "" {
+			break
+		}
+		input.Cursor = result.Cursor
+	}
+	if tokens, want := tokensOf(found), []string{"a", "c", "d"}; !equalStrings(tokens, want) || pages < 2 {
+		t.Errorf("Search(ctx) found %v in %d pages, want %v in several", tokens, pages, want)
+	}
+}
+
+func TestSearch_CursorIsEncrypted(t *testing.T) {
+	self := newSearchDiscovery(t)
+	result, err := self.Search(context.TODO(), SearchInput{Workspace: "discovery_app", Predicates: []Predicate{Exists("appName")}, Limit: 1})
+	if err != nil || result.Cursor == "" {
+		t.Fatalf("Search(ctx) == %+v, %v, want a cursor", result, err)
+	}
+
+	decoded, _ := base64.RawURLEncoding.DecodeString(result.Cursor)
+	if strings.Contains(string(decoded), "apiToken") || strings.Contains(result.Cursor, "apiToken") {
+		t.Errorf("Search(ctx) cursor %q reveals the key it continues after", result.Cursor)
+	}
+}
+
+func TestSearch_InvalidCursor(t *testing.T) {
+	var (
+		ctx   = context.TODO()
+		self  = newSearchDiscovery(t)
+		input = SearchInput{Workspace: "discovery_app", Predicates: []Predicate{Exists("appName")}, Limit: 1}
+	)
+	first, err := self.Search(ctx, input)
+	if err != nil || first.Cursor == "" {
+		t.Fatalf("Search(ctx) == %+v, %v, want a cursor", first, err)
+	}
+
+	other := New(self.DynamodbSvc)
+	other.CursorKey = []byte("otherKey")
+	tests := []struct {
+		name    string
+		service SharedDiscovery
+		input   SearchInput
+	}{
+		{"tampered", self, SearchInput{Workspace: "discovery_app", Predicates: input.Predicates, Cursor: "x" + first.Cursor}},
+		{"malformed", self, SearchInput{Workspace: "discovery_app", Predicates: input.Predicates, Cursor: "abc"}},
+		{"another search", self, SearchInput{Workspace: "discovery_app", Predicates: []Predicate{Exists("sdkVersion")}, Cursor: first.Cursor}},
+		{"another key", other, SearchInput{Workspace: "discovery_app", Predicates: input.Predicates, Cursor: first.Cursor}},
+	}
+
+	for _, test := range tests {
+		_, err := test.service.Search(ctx, test.input)
+		var validationErr *ValidationError
+		if !errors.As(err, &validationErr) || validationErr.Field != "cursor" {
+			t.Errorf("%s: Search(ctx, cursor) == %v, want a cursor ValidationError", test.name, err)
+		}
+	}
+}
+
+func TestSearch_InvalidPredicate(t *testing.T) {
+	self := newSearchDiscovery(t)
+
+	for _, predicate := range []Predicate{
+		{Attribute: "appName", Op: "like", Values: []interface{}{"s%"}},
+		{Attribute: "build", Op: OpBetween, Values: []interface{}{1}},
+		{Attribute: "build", Op: OpBeginsWith, Values: []interface{}{1}},
+		{Op: OpExists},
+	} {
+		_, err := self.Search(context.TODO(), SearchInput{Workspace: "discovery_app", Predicates: []Predicate{predicate}})
+		if class := ErrorClass(err); class != "invalid_input" {
+			t.Errorf("Search(ctx, %+v) == %v, want invalid_input", predicate, err)
+		}
+	}
+}
@@ -0,0 +1,309 @@
+package shareddiscovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+)
+
+// SecretReference is the key of a config value referencing a secret, such
+// as {"$secret": "brand/env/name"}. The map must have no other keys.
+const SecretReference = "$secret"
+
+// SecretProvider looks up the value of a secret by name.
+type SecretProvider interface {
+	GetSecret(ctx context.Context, name string) (string, error)
+}
+
+// SecretsManagerProvider reads secrets from AWS Secrets Manager, using the
+// reference as the secret ID.
+type SecretsManagerProvider struct {
+	Client secretsmanageriface.SecretsManagerAPI
+}
+
+// GetSecret returns the SecretString of the secret name.
+func (provider SecretsManagerProvider) GetSecret(ctx context.Context, name string) (string, error) {
+	output, err := provider.Client.GetSecretValueWithContext(ctx, &secretsmanager.GetSecretValueInput{SecretId: aws.String(name)})
+	if err != nil {
+		return "", err
+	}
+	if output.SecretString == nil {
+		return "", errors.New("secret has no string value")
+	}
+	return *output.SecretString, nil
+}
+
+// SSMProvider reads secrets from SSM Parameter Store, decrypting
+// SecureString parameters. The parameter name is Prefix followed by the
+// reference, so a Prefix of "/discovery/" reads "brand/env/name" from
+// "/discovery/brand/env/name".
+type SSMProvider struct {
+	Client ssmiface.SSMAPI
+	Prefix string
+}
+
+// GetSecret returns the value of the parameter Prefix + name.
+func (provider SSMProvider) GetSecret(ctx context.Context, name string) (string, error) {
+	output, err := provider.Client.GetParameterWithContext(ctx, &ssm.GetParameterInput{
+		Name:           aws.String(provider.Prefix + name),
+		WithDecryption: aws.Bool(true),
+	})
+	if err != nil {
+		return "", err
+	}
+	if output.Parameter == nil || output.Parameter.Value == nil {
+		return "", errors.New("parameter has no value")
+	}
+	return *output.Parameter.Value, nil
+}
+
+// Pinger is implemented by secret providers that can check they are
+// reachable without reading a secret.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// Ping lists at most one secret to check Secrets Manager is reachable and
+// the caller may use it.
+func (provider SecretsManagerProvider) Ping(ctx context.Context) error {
+	_, err := provider.Client.ListSecretsWithContext(ctx, &secretsmanager.ListSecretsInput{MaxResults: aws.Int64(1)})
+	return err
+}
+
+// Ping describes at most one parameter to check Parameter Store is
+// reachable and the caller may use it.
+func (provider SSMProvider) Ping(ctx context.Context) error {
+	_, err := provider.Client.DescribeParametersWithContext(ctx, &ssm.DescribeParametersInput{MaxResults: aws.Int64(1)})
+	return err
+}
+
+// SecretError is returned when a config references a secret that cannot be
+// resolved. Path is the dot separated path of the reference in the config,
+// and both Path and Name are empty when the Scope of the config failed.
+type SecretError struct {
+	Path string
+	Name string
+	Err  error
+}
+
+func (err *SecretError) Error() string {
+	if err.Name == "" {
+		return fmt.Sprintf("unresolved secrets: %v", err.Err)
+	}
+	return fmt.Sprintf("unresolved secret %q at %s: %v", err.Name, err.Path, err.Err)
+}
+
+func (err *SecretError) Unwrap() error {
+	return err.Err
+}
+
+// SecretScope returns the prefix every secret reference in a row must start
+// with, such as "oralb/qa/", so a row can only reference the secrets of its
+// own brand and environment. It is only called for rows with a reference,
+// and is given the whole row even when a call asks for some fields.
+type SecretScope func(row map[string]interface{}) (string, error)
+
+// AttributeScope returns a SecretScope of the values of attributes in the
+// row joined with "/". A row missing any of them may reference no secrets.
+func AttributeScope(attributes ...string) SecretScope {
+	return func(row map[string]interface{}) (string, error) {
+		prefix := ""
+		for _, attribute := range attributes {
+			value, _ := row[attribute].(string)
+			if value == "" {
+				return "", fmt.Errorf("config has no %s to scope its secrets", attribute)
+			}
+			prefix += value + "/"
+		}
+		return prefix, nil
+	}
+}
+
+// Secrets resolves secret references in configs through a SecretProvider
+// and caches the values. It is safe for concurrent use.
+//
+// Scope restricts the secrets a config may reference. When it is nil a
+// config may reference any secret the provider can read, so rows must be
+// trusted as much as the service's own credentials.
+type Secrets struct {
+	Scope SecretScope
+
+	provider SecretProvider
+	ttl      time.Duration
+	mu       sync.Mutex
+	entries  map[string]secretEntry
+}
+
+type secretEntry struct {
+	value   string
+	expires time.Time
+}
+
+// NewSecrets returns Secrets reading from provider and caching values for
+// ttl. A ttl of zero keeps values for the life of the process.
+func NewSecrets(provider SecretProvider, ttl time.Duration) *Secrets {
+	return &Secrets{provider: provider, ttl: ttl, entries: map[string]secretEntry{}}
+}
+
+// Get returns the value of the secret name, from the cache when it has one.
+func (secrets *Secrets) Get(ctx context.Context, name string) (string, error) {
+	secrets.mu.Lock()
+	entry, ok := secrets.entries[name]
+	secrets.mu.Unlock()
+	if ok && (entry.expires.IsZero() || time.Now().Before(entry.expires)) {
+		return entry.value, nil
+	}
+
+	value, err := secrets.provider.GetSecret(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	entry = secretEntry{value: value}
+	if secrets.ttl > 0 {
+		entry.expires = time.Now().Add(secrets.ttl)
+	}
+	secrets.mu.Lock()
+	secrets.entries[name] = entry
+	secrets.mu.Unlock()
+	return value, nil
+}
+
+// Ping checks the provider of secrets is reachable when it is a Pinger.
+// Other providers are assumed to be.
+func (secrets *Secrets) Ping(ctx context.Context) error {
+	if pinger, ok := secrets.provider.(Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+// Resolve returns a copy of config with every secret reference replaced by
+// the secret's value. config itself is left untouched, so cached configs
+// keep their references. The first reference that fails is returned as a
+// *SecretError.
+func (secrets *Secrets) Resolve(ctx context.Context, config map[string]interface{}) (map[string]interface{}, error) {
+	return secrets.resolveRow(ctx, config, config)
+}
+
+// resolveRow resolves config, which is row or the fields of it a call asked
+// for, scoping its references by the whole row.
+func (secrets *Secrets) resolveRow(ctx context.Context, row, config map[string]interface{}) (map[string]interface{}, error) {
+	if config == nil {
+		return nil, nil
+	}
+	resolved, err := secrets.resolve(ctx, secrets.scopeOf(row), nil, config)
+	if err != nil {
+		return nil, err
+	}
+	return resolved.(map[string]interface{}), nil
+}
+
+// scopeOf returns the scope of the references in row, computed by Scope
+// the first time it is needed.
+func (secrets *Secrets) scopeOf(row map[string]interface{}) func() (string, error) {
+	var (
+		computed bool
+		prefix   string
+		err      error
+	)
+	return func() (string, error) {
+		if !computed && secrets.Scope != nil {
+			prefix, err = secrets.Scope(row)
+		}
+		computed = true
+		return prefix, err
+	}
+}
+
+func (secrets *Secrets) resolve(ctx context.Context, scope func() (string, error), path []string, value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if name, ok := secretName(v); ok {
+			prefix, err := scope()
+			if err != nil {
+				return nil, &SecretError{Path: strings.Join(path, "."), Name: name, Err: err}
+			}
+			if !strings.HasPrefix(name, prefix) {
+				return nil, &SecretError{Path: strings.Join(path, "."), Name: name, Err: fmt.Errorf("secret is outside %q", prefix)}
+			}
+			secret, err := secrets.Get(ctx, name)
+			if err != nil {
+				return nil, &SecretError{Path: strings.Join(path, "."), Name: name, Err: err}
+			}
+			return secret, nil
+		}
+		// walk the keys in order so the same reference fails first every time
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		copied := make(map[string]interface{}, len(v))
+		for _, key := range keys {
+			resolved, err := secrets.resolve(ctx, scope, append(append([]string{}, path...), key), v[key])
+			if err != nil {
+				return nil, err
+			}
+			copied[key] = resolved
+		}
+		return copied, nil
+	case []interface{}:
+		copied := make([]interface{}, len(v))
+		for i, element := range v {
+			resolved, err := secrets.resolve(ctx, scope, append(append([]string{}, path...), strconv.Itoa(i)), element)
+			if err != nil {
+				return nil, err
+			}
+			copied[i] = resolved
+		}
+		return copied, nil
+	default:
+		return value, nil
+	}
+}
+
+func secretName(value map[string]interface{}) (string, bool) {
+	if len(value) != 1 {
+		return "", false
+	}
+	name, ok := value[SecretReference].(string)
+	return name, ok
+}
+
+// resolveSecrets resolves the secret references of config, which is row or
+// the fields of it a call asked for, when the service has opted in with
+// Secrets, and returns config as is otherwise.
+func (service SharedDiscovery) resolveSecrets(ctx context.Context, span Span, row, config map[string]interface{}) (map[string]interface{}, error) {
+	if service.Secrets == nil || config == nil {
+		return config, nil
+	}
+	resolved, err := service.Secrets.resolveRow(ctx, row, config)
+	if err != nil {
+		span.AddField("error.message", err.Error())
+		return nil, err
+	}
+	return resolved, nil
+}
+
+// rowOptions returns the options to read the rows of a call with, and
+// whether they leave out its fields. They do when Secrets has a Scope, which
+// is computed from the whole row, so the fields are masked once the secrets
+// are resolved instead.
+func (service SharedDiscovery) rowOptions(options callOptions) (callOptions, bool) {
+	if service.Secrets == nil || service.Secrets.Scope == nil || len(options.fields) == 0 {
+		return options, false
+	}
+	options.fields = nil
+	return options, true
+}
@@ -0,0 +1,217 @@
+package shareddiscovery
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/golang/mock/gomock"
+	"github.com/pgdevelopers/shareddiscovery/v2/discoverytest"
+	"github.com/pgdevelopers/shareddiscovery/v2/mocks/mock_secretsmanageriface"
+	"github.com/pgdevelopers/shareddiscovery/v2/mocks/mock_ssmiface"
+)
+
+// newSecretsDiscovery returns a SharedDiscovery on an in-memory apps
+// workspace whose abc config references secrets.
+func newSecretsDiscovery(t *testing.T) SharedDiscovery {
+	db := discoverytest.NewDynamoDB()
+	db.Seed(t, TableDefinition{HashKey: "apiToken"}.CreateTableInput("apps"), map[string]interface{}{
+		"apiToken": "abc",
+		"name":     "app",
+		"stripe":   map[string]interface{}{"key": map[string]string{SecretReference: "oralb/qa/stripe"}},
+		"webhooks": []interface{}{map[string]string{SecretReference: "oralb/qa/webhook"}},
+	})
+	return New(db)
+}
+
+func TestGetConfig_Secrets(t *testing.T) {
+	var (
+		ctx                = context.TODO()
+		self               = newSecretsDiscovery(t)
+		query              = QueryInput{Workspace: "apps"}
+		mockSecretsManager = mock_secretsmanageriface.NewMockSecretsManagerAPI(gomock.NewController(t))
+	)
+	self.Cache = NewMemoryCache(0)
+	self.Secrets = NewSecrets(SecretsManagerProvider{Client: mockSecretsManager}, 0)
+
+	// each secret is read once and then served from the Secrets cache
+	mockSecretsManager.
+		EXPECT().
+		GetSecretValueWithContext(gomock.Any(), &secretsmanager.GetSecretValueInput{SecretId: aws.String("oralb/qa/stripe")}).
+		Return(&secretsmanager.GetSecretValueOutput{SecretString: aws.String("sk_test")}, nil)
+	mockSecretsManager.
+		EXPECT().
+		GetSecretValueWithContext(gomock.Any(), &secretsmanager.GetSecretValueInput{SecretId: aws.String("oralb/qa/webhook")}).
+		Return(&secretsmanager.GetSecretValueOutput{SecretString: aws.String("whsec")}, nil)
+
+	want := map[string]interface{}{
+		"apiToken": "abc",
+		"name":     "app",
+		"stripe":   map[string]interface{}{"key": "sk_test"},
+		"webhooks": []interface{}{"whsec"},
+	}
+	for i := 0; i < 2; i++ {
+		if config, err := self.GetConfig(ctx, "abc", query); err != nil || !reflect.DeepEqual(config, want) {
+			t.Errorf("GetConfig(ctx, %q, %q) == %v, %v, want %v", "abc", query.Workspace, config, err, want)
+		}
+	}
+	if cached, _ := self.Cache.Get(ConfigKey{Workspace: "apps", APIToken: "abc"}); cached["webhooks"].([]interface{})[0] == "whsec" {
+		t.Errorf("Cache.Get(abc) == %v, want the references kept out of the cache", cached)
+	}
+}
+
+func TestGetConfig_SecretsNotOptedIn(t *testing.T) {
+	self := newSecretsDiscovery(t)
+
+	config, err := self.GetConfig(context.TODO(), "abc", QueryInput{Workspace: "apps"})
+
+	want := map[string]interface{}{"key": map[string]interface{}{SecretReference: "oralb/qa/stripe"}}
+	if err != nil || !reflect.DeepEqual(config["stripe"], want) {
+		t.Errorf("GetConfig(ctx, %q, %q) without Secrets == %v, %v, want the references as stored", "abc", "apps", config, err)
+	}
+}
+
+func TestGetConfig_UnresolvedSecret(t *testing.T) {
+	var (
+		ctx     = context.TODO()
+		self    = newSecretsDiscovery(t)
+		mockSSM = mock_ssmiface.NewMockSSMAPI(gomock.NewController(t))
+	)
+	self.Secrets = NewSecrets(SSMProvider{Client: mockSSM, Prefix: "/discovery/"}, 0)
+
+	notFound := awserr.New(ssm.ErrCodeParameterNotFound, "parameter not found", nil)
+	mockSSM.
+		EXPECT().
+		GetParameterWithContext(gomock.Any(), &ssm.GetParameterInput{Name: aws.String("/discovery/oralb/qa/stripe"), WithDecryption: aws.Bool(true)}).
+		Return(nil, notFound).
+		Times(2)
+
+	config, err := self.GetConfig(ctx, "abc", QueryInput{Workspace: "apps"})
+
+	var secretErr *SecretError
+	if !errors.As(err, &secretErr) || secretErr.Path != "stripe.key" || secretErr.Name != "oralb/qa/stripe" || config != nil {
+		t.Errorf("GetConfig(ctx, %q, %q) == %v, %v, want a SecretError at stripe.key", "abc", "apps", config, err)
+	}
+	if class := ErrorClass(err); class != "unresolved_secret" {
+		t.Errorf("ErrorClass(%v) == %q, want %q", err, class, "unresolved_secret")
+	}
+
+	results := self.BatchGetConfig(ctx, []ConfigKey{{Workspace: "apps", APIToken: "abc"}, {Workspace: "apps", APIToken: "missing"}})
+	if !errors.As(results[0].Err, &secretErr) || results[0].Config != nil || results[1].Err != nil {
+		t.Errorf("BatchGetConfig(ctx, abc, missing) == %+v, want a SecretError for abc only", results)
+	}
+}
+
+func TestSSMProvider(t *testing.T) {
+	mockSSM := mock_ssmiface.NewMockSSMAPI(gomock.NewController(t))
+	provider := SSMProvider{Client: mockSSM}
+
+	mockSSM.
+		EXPECT().
+		GetParameterWithContext(gomock.Any(), &ssm.GetParameterInput{Name: aws.String("/oralb/qa/stripe"), WithDecryption: aws.Bool(true)}).
+		Return(&ssm.GetParameterOutput{Parameter: &ssm.Parameter{Value: aws.String("sk_test")}}, nil)
+
+	if value, err := provider.GetSecret(context.TODO(), "/oralb/qa/stripe"); err != nil || value != "sk_test" {
+		t.Errorf("GetSecret(ctx, %q) == %q, %v, want %q", "/oralb/qa/stripe", value, err, "sk_test")
+	}
+}
+
+func TestGetConfig_SecretScope(t *testing.T) {
+	var (
+		ctx                = context.TODO()
+		self               = newSecretsDiscovery(t)
+		mockSecretsManager = mock_secretsmanageriface.NewMockSecretsManagerAPI(gomock.NewController(t))
+	)
+	self.Secrets = NewSecrets(SecretsManagerProvider{Client: mockSecretsManager}, 0)
+
+	tests := []struct {
+		scope SecretScope
+		path  string
+	}{
+		// the abc row references oralb/qa secrets
+		{func(map[string]interface{}) (string, error) { return "gillette/prod/", nil }, "stripe.key"},
+		// and has no brandName to scope them by
+		{AttributeScope("brandName", "environment"), "stripe.key"},
+	}
+
+	for _, test := range tests {
+		self.Secrets.Scope = test.scope
+		config, err := self.GetConfig(ctx, "abc", QueryInput{Workspace: "apps"})
+		var secretErr *SecretError
+		if !errors.As(err, &secretErr) || secretErr.Path != test.path || config != nil {
+			t.Errorf("GetConfig(ctx, %q, %q) == %v, %v, want a SecretError at %q", "abc", "apps", config, err, test.path)
+		}
+	}
+}
+
+func TestGetConfig_SecretScopeWithFields(t *testing.T) {
+	var (
+		ctx                = context.TODO()
+		db                 = discoverytest.NewDynamoDB()
+		query              = QueryInput{Workspace: "apps"}
+		mockSecretsManager = mock_secretsmanageriface.NewMockSecretsManagerAPI(gomock.NewController(t))
+	)
+	db.Seed(t, TableDefinition{HashKey: "apiToken"}.CreateTableInput("apps"),
+		map[string]interface{}{
+			"apiToken":    "abc",
+			"brandName":   "oralb",
+			"environment": "qa",
+			"name":        "app",
+			"stripe":      map[string]interface{}{"key": map[string]string{SecretReference: "oralb/qa/stripe"}},
+		},
+		map[string]interface{}{"apiToken": "plain", "name": "plain app"},
+	)
+	self := New(db)
+	self.Secrets = NewSecrets(SecretsManagerProvider{Client: mockSecretsManager}, 0)
+	self.Secrets.Scope = AttributeScope("brandName", "environment")
+
+	mockSecretsManager.
+		EXPECT().
+		GetSecretValueWithContext(gomock.Any(), &secretsmanager.GetSecretValueInput{SecretId: aws.String("oralb/qa/stripe")}).
+		Return(&secretsmanager.GetSecretValueOutput{SecretString: aws.String("sk_test")}, nil)
+
+	// rows without references need no scope
+	if config, err := self.GetConfig(ctx, "plain", query); err != nil || config["name"] != "plain app" {
+		t.Errorf("GetConfig(ctx, %q, %q) == %v, %v, want the plain config", "plain", "apps", config, err)
+	}
+
+	// the scope comes from the whole row even when other fields are asked for
+	want := map[string]interface{}{"stripe": map[string]interface{}{"key": "sk_test"}}
+	if config, err := self.GetConfig(ctx, "abc", query, WithFields("stripe")); err != nil || !reflect.DeepEqual(config, want) {
+		t.Errorf("GetConfig(ctx, %q, %q, WithFields(stripe)) == %v, %v, want %v", "abc", "apps", config, err, want)
+	}
+	results := self.BatchGetConfig(ctx, []ConfigKey{{Workspace: "apps", APIToken: "abc"}}, WithFields("stripe"))
+	if results[0].Err != nil || !reflect.DeepEqual(results[0].Config, want) {
+		t.Errorf("BatchGetConfig(ctx, abc, WithFields(stripe)) == %+v, want %v", results, want)
+	}
+}
+
+func TestAttributeScope(t *testing.T) {
+	scope := AttributeScope("brandName", "environment")
+
+	if prefix, err := scope(map[string]interface{}{"brandName": "oralb", "environment": "qa"}); err != nil || prefix != "oralb/qa/" {
+		t.Errorf("scope(oralb, qa) == %q, %v, want %q", prefix, err, "oralb/qa/")
+	}
+	if prefix, err := scope(map[string]interface{}{"brandName": "oralb"}); err == nil {
+		t.Errorf("scope(oralb) == %q, nil, want an error", prefix)
+	}
+}
+
+func TestSSMProvider_NoValue(t *testing.T) {
+	mockSSM := mock_ssmiface.NewMockSSMAPI(gomock.NewController(t))
+	provider := SSMProvider{Client: mockSSM}
+
+	mockSSM.
+		EXPECT().
+		GetParameterWithContext(gomock.Any(), gomock.Any()).
+		Return(&ssm.GetParameterOutput{}, nil)
+
+	if value, err := provider.GetSecret(context.TODO(), "/oralb/qa/stripe"); err == nil {
+		t.Errorf("GetSecret(ctx, %q) == %q, nil, want an error", "/oralb/qa/stripe", value)
+	}
+}
@@ -17,16 +17,16 @@ import (
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
-	"errors"
 	"fmt"
 	"sort"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
 	"github.com/aws/aws-sdk-go/service/dynamodb/expression"
-	"github.com/honeycombio/beeline-go"
+	"github.com/aws/aws-sdk-go/service/dynamodbstreams/dynamodbstreamsiface"
 )
 
 // QueryInput defines the values used to query dynamo with.
@@ -42,15 +42,86 @@ type QueryInput struct {
 
 // IFace describes what is required for building a SharedDiscovery implementation.
 type IFace interface {
-	GetValidation(ctx context.Context, query QueryInput) (bool, error)
-	GetConfig(ctx context.Context, apiToken string, query QueryInput) (map[string]interface{}, error)
-	AdminGetAPIToken(ctx context.Context, secretKey string, query QueryInput) (string, error)
+	GetValidation(ctx context.Context, query QueryInput, opts ...CallOption) (bool, error)
+	GetConfig(ctx context.Context, apiToken string, query QueryInput, opts ...CallOption) (map[string]interface{}, error)
+	BatchGetConfig(ctx context.Context, keys []ConfigKey, opts ...CallOption) []ConfigResult
+	AdminGetAPIToken(ctx context.Context, secretKey string, query QueryInput, opts ...CallOption) (string, error)
 }
 
 // SharedDiscovery is a custom service object for interacting with the global config
 type SharedDiscovery struct {
 	IFace
 	DynamodbSvc dynamodbiface.DynamoDBAPI
+
+	// StreamsSvc is required for Watch and reads the workspace's DynamoDB Stream.
+	StreamsSvc dynamodbstreamsiface.DynamoDBStreamsAPI
+
+	// Workspaces is optional and lists the config workspaces HealthCheck
+	// verifies besides discovery_app.
+	Workspaces []string
+
+	// Cache is optional. When set, GetConfig reads through it and Watch
+	// invalidates it as changes arrive.
+	Cache Cache
+
+	// Checkpointer is optional and lets Watch resume from the last record
+	// it delivered. Without it, Watch starts from the latest records.
+	Checkpointer Checkpointer
+
+	// WatchPollInterval is how often Watch polls each shard. Defaults to one second.
+	WatchPollInterval time.Duration
+
+	// Resilience is optional and retries and circuit breaks DynamoDB calls.
+	Resilience *Resilience
+
+	// Failover is optional and serves reads from other regional replicas
+	// when the first one is failing or slow.
+	Failover *Failover
+
+	// Encryption is optional and encrypts marked attributes on writes and
+	// decrypts encrypted attributes on reads.
+	Encryption *Encryption
+
+	// Secrets is optional and opts in to resolving secret references in
+	// configs. Without it, references are returned as they are stored.
+	Secrets *Secrets
+
+	// Audit is optional and records every AdminGetAPIToken call. A token
+	// whose retrieval cannot be recorded is not returned.
+	Audit AuditSink
+
+	// AuditKey keys the fingerprints the audit trail records for secret
+	// keys with no KeyID. Keep it apart from the admin secrets; without it
+	// no fingerprint is recorded.
+	AuditKey []byte
+
+	// AdminKeys is optional and restricts AdminGetAPIToken to these secret
+	// keys, each limited by its policy. Without it any key that signs the
+	// query may retrieve any token.
+	AdminKeys []AdminKey
+
+	// CursorKey encrypts the cursors Search returns. Set it when cursors
+	// must be accepted by other processes; without it each process uses a
+	// random key of its own.
+	CursorKey []byte
+
+	// CountryFallbacks is optional and lets GetConfig fall back to region
+	// group rows in the workspaces it names.
+	CountryFallbacks map[string]CountryFallback
+
+	// ExtraCountries lists the countryCode values accepted besides ISO
+	// 3166-1 codes and fallback groups, such as "UK" or "EU", as rows are
+	// keyed by them. Any other country is refused with a *ValidationError.
+	ExtraCountries []string
+
+	// Tracer and Metrics receive the library's telemetry. They default to
+	// BeelineTracer and BeelineMetrics.
+	Tracer  Tracer
+	Metrics Metrics
+
+	// Redactor masks secrets before span fields reach the Tracer. It
+	// defaults to DefaultRedactor.
+	Redactor *Redactor
 }
 
 // New is a constructor that takes a preconfigured dynamodbiface and returns an implementation of SharedDiscoveryIFace
@@ -61,9 +132,22 @@ func New(dynamodb dynamodbiface.DynamoDBAPI) SharedDiscovery {
 
 // GetValidation uses the provided `AppName` and `Country` to check the item
 // exists in the specified `tableName`.
-func (service SharedDiscovery) GetValidation(ctx context.Context, query QueryInput) (bool, error) {
-	_, validationgSpan := beeline.StartSpan(ctx, "GetValidation")
+func (service SharedDiscovery) GetValidation(ctx context.Context, query QueryInput, opts ...CallOption) (valid bool, err error) {
+	ctx, validationgSpan := service.startSpan(ctx, "GetValidation")
+	defer func(start time.Time) {
+		service.observe(ctx, "GetValidation", "discovery_app", start, err)
+		finishSpan(validationgSpan, err)
+	}(time.Now())
 	validationgSpan.AddField("workspace", "discovery_app")
+	options := newCallOptions(opts)
+	ctx, cancel := options.context(ctx)
+	defer cancel()
+
+	query, err = service.normalizeQuery(query)
+	if err != nil {
+		validationgSpan.AddField("error.message", err.Error())
+		return false, err
+	}
 
 	// Set up filters
 	filter1 := expression.Name("appName").Equal(expression.Value(&query.AppName))
@@ -85,50 +169,123 @@ func (service SharedDiscovery) GetValidation(ctx context.Context, query QueryInp
 		FilterExpression:          expr.Filter(),
 		ProjectionExpression:      expr.Projection(),
 		TableName:                 aws.String("discovery_app"),
+		ConsistentRead:            options.consistent(),
+		ReturnConsumedCapacity:    options.returnCapacity(),
 	}
 
 	// Make the DynamoDB Query API call
-	result, err := service.DynamodbSvc.Scan(params)
+	output, err := service.read(ctx, validationgSpan, "GetValidation", "discovery_app", func(ctx context.Context, db dynamodbiface.DynamoDBAPI) (interface{}, error) {
+		return db.ScanWithContext(ctx, params)
+	})
 	if err != nil {
 		validationgSpan.AddField("error.message", err.Error())
 		return false, err
 	}
+	result := output.(*dynamodb.ScanOutput)
+	service.observeCapacity(ctx, "GetValidation", result.ConsumedCapacity)
+	options.addCapacity(result.ConsumedCapacity)
 
-	validationgSpan.Send()
 	return len(result.Items) > 0, nil
 }
 
 // GetConfig uses the provided `APIToken` to get the correct
 // configuration from the specified `tableName`.
-func (service SharedDiscovery) GetConfig(ctx context.Context, apiToken string, query QueryInput) (map[string]interface{}, error) {
-	_, configSpan := beeline.StartSpan(ctx, "GetConfig")
+func (service SharedDiscovery) GetConfig(ctx context.Context, apiToken string, query QueryInput, opts ...CallOption) (discovery map[string]interface{}, err error) {
+	ctx, configSpan := service.startSpan(ctx, "GetConfig")
+	defer func(start time.Time) {
+		service.observe(ctx, "GetConfig", query.Workspace, start, err)
+		finishSpan(configSpan, err)
+	}(time.Now())
 	configSpan.AddField("workspace", query.Workspace)
+	options := newCallOptions(opts)
+	ctx, cancel := options.context(ctx)
+	defer cancel()
 
-	// dynamically build attribute values
-	searchAttributes := map[string]*dynamodb.AttributeValue{
-		"apiToken": {
-			S: &apiToken,
-		},
+	query, err = service.normalizeQuery(query)
+	if err != nil {
+		configSpan.AddField("error.message", err.Error())
+		return nil, err
 	}
-	searchAttributes = addNeededSearchAttributes(searchAttributes, query)
 
-	appResult, err := service.DynamodbSvc.GetItem(&dynamodb.GetItemInput{
-		TableName: &query.Workspace,
-		Key:       searchAttributes,
-	})
+	if service.Cache != nil && options.readCache() {
+		cached, ok := service.Cache.Get(configKeyFor(apiToken, query))
+		service.observeCache(ctx, "GetConfig", query.Workspace, ok)
+		if ok {
+			configSpan.AddField("cache.hit", true)
+			options.serve(servedCountry(cached, query.Country))
+			return service.resolveSecrets(ctx, configSpan, cached, options.mask(cached))
+		}
+	}
 
+	rowOptions, masked := service.rowOptions(options)
+	projection, names, err := rowOptions.projection()
 	if err != nil {
+		configSpan.AddField("error.message", err.Error())
 		return nil, err
 	}
 
-	var discovery map[string]interface{}
-	err = dynamodbattribute.UnmarshalMap(appResult.Item, &discovery)
+	// try the requested country, then any fallbacks, until a row is found
+	var item map[string]*dynamodb.AttributeValue
+	served := query.Country
+	for _, country := range service.countryChain(query) {
+		// dynamically build attribute values
+		searchAttributes := map[string]*dynamodb.AttributeValue{
+			"apiToken": {
+				S: &apiToken,
+			},
+		}
+		searchAttributes = addNeededSearchAttributes(searchAttributes, QueryInput{Country: country})
+
+		output, err := service.read(ctx, configSpan, "GetConfig", query.Workspace, func(ctx context.Context, db dynamodbiface.DynamoDBAPI) (interface{}, error) {
+			return db.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+				TableName:                &query.Workspace,
+				Key:                      searchAttributes,
+				ConsistentRead:           options.consistent(),
+				ProjectionExpression:     projection,
+				ExpressionAttributeNames: names,
+				ReturnConsumedCapacity:   options.returnCapacity(),
+			})
+		})
+
+		if err != nil {
+			configSpan.AddField("error.message", err.Error())
+			return nil, err
+		}
+		appResult := output.(*dynamodb.GetItemOutput)
+		service.observeCapacity(ctx, "GetConfig", appResult.ConsumedCapacity)
+		options.addCapacity(appResult.ConsumedCapacity)
+		if len(appResult.Item) > 0 {
+			item, served = appResult.Item, country
+			break
+		}
+	}
+	if item != nil {
+		options.serve(served)
+		configSpan.AddField("country.served", served)
+	}
+	if item, err = service.decryptItem(ctx, ConfigKey{Workspace: query.Workspace, APIToken: apiToken, Country: served}, item); err != nil {
+		configSpan.AddField("error.message", err.Error())
+		return nil, err
+	}
+
+	err = dynamodbattribute.UnmarshalMap(item, &discovery)
 	if err != nil {
+		configSpan.AddField("error.message", fmt.Sprintf("Unable to unmarshal config: %s", err.Error()))
 		return nil, err
 	}
 
-	configSpan.Send()
-	return discovery, nil
+	if service.Cache != nil && discovery != nil && options.writeCache() {
+		key := configKeyFor(apiToken, query)
+		service.Cache.Set(key, discovery)
+		if aliases, ok := service.Cache.(AliasCache); ok && served != query.Country {
+			aliases.Alias(key, ConfigKey{Workspace: query.Workspace, APIToken: apiToken, Country: served})
+		}
+	}
+
+	if masked {
+		return service.resolveSecrets(ctx, configSpan, discovery, options.mask(discovery))
+	}
+	return service.resolveSecrets(ctx, configSpan, discovery, discovery)
 }
 
 func addNeededSearchAttributes(searchAttributes map[string]*dynamodb.AttributeValue, query QueryInput) map[string]*dynamodb.AttributeValue {
@@ -144,29 +301,69 @@ func addNeededSearchAttributes(searchAttributes map[string]*dynamodb.AttributeVa
 // a request using the GetConfig call.
 // It first validates the HMAC signature against the provided secretKey/query params
 // to verify the caller is who they say they are.
-func (service SharedDiscovery) AdminGetAPIToken(ctx context.Context, secretKey string, query QueryInput) (string, error) {
-	_, getAPIKeySpan := beeline.StartSpan(ctx, "adminGetAPIToken")
-	defer getAPIKeySpan.Send()
+func (service SharedDiscovery) AdminGetAPIToken(ctx context.Context, secretKey string, query QueryInput, opts ...CallOption) (token string, err error) {
+	ctx, getAPIKeySpan := service.startSpan(ctx, "adminGetAPIToken")
+	defer func(start time.Time) {
+		service.observe(ctx, "AdminGetAPIToken", query.Workspace, start, err)
+		finishSpan(getAPIKeySpan, err)
+	}(time.Now())
+	var app string
+	defer func(ctx context.Context) {
+		if auditErr := service.audit(ctx, getAPIKeySpan, "AdminGetAPIToken", secretKey, query, app, err); auditErr != nil && err == nil {
+			token, err = "", fmt.Errorf("recording audit event: %w", auditErr)
+		}
+	}(ctx)
+	options := newCallOptions(opts)
+	ctx, cancel := options.context(ctx)
+	defer cancel()
 
 	// validate signature
-	if !validateSignature(ctx, query, secretKey) {
+	if !validateSignature(ctx, service, query, secretKey) {
 		getAPIKeySpan.AddField("error.message", "invalid signature detected")
-		return "", errors.New("invalid signature")
+		return "", ErrInvalidSignature
+	}
+
+	// the signature covers the country as sent, so normalize it afterwards
+	query, err = service.normalizeQuery(query)
+	if err != nil {
+		getAPIKeySpan.AddField("error.message", err.Error())
+		return "", err
+	}
+
+	// refuse queries outside the admin key's policy before reading
+	if err = service.authorizeQuery(secretKey, query); err != nil {
+		getAPIKeySpan.AddField("error.message", err.Error())
+		return "", err
 	}
 
 	// run query
-	items, err := getAPITokenQuery(ctx, service, query)
+	items, err := getAPITokenQuery(ctx, service, query, options)
 	if err != nil {
 		getAPIKeySpan.AddField("error.message", err.Error())
 		return "", err
 	}
 
 	// parse token
-	return parseAPIToken(ctx, items)
+	token, row, err := parseAPIToken(ctx, service, items)
+	if err != nil {
+		getAPIKeySpan.AddField("error.message", err.Error())
+		return "", err
+	}
+
+	// the app found is what the policy protects, whatever the query named,
+	// and is audited even when the policy refuses it
+	app, _ = row["appName"].(string)
+	if err = service.authorizeApp(secretKey, row); err != nil {
+		getAPIKeySpan.AddField("error.message", err.Error())
+		return "", err
+	}
+	return token, nil
 }
 
-func validateSignature(ctx context.Context, query QueryInput, secretKey string) bool {
-	_, validateSignatureSpan := beeline.StartSpan(ctx, "validateSignature")
+func validateSignature(ctx context.Context, service SharedDiscovery, query QueryInput, secretKey string) bool {
+	_, validateSignatureSpan := service.startSpan(ctx, "validateSignature")
+	var err error
+	defer func() { finishSpan(validateSignatureSpan, err) }()
 	validateSignatureSpan.AddField("query.object", query)
 	message := messageFromQuery(query)
 	validateSignatureSpan.AddField("query.string", message)
@@ -177,10 +374,23 @@ func validateSignature(ctx context.Context, query QueryInput, secretKey string)
 	decoded, err := hex.DecodeString(query.Signature)
 	if err != nil {
 		validateSignatureSpan.AddField("error.message", fmt.Sprintf("unable to decode signature: %s", err.Error()))
+		err = ErrInvalidSignature
+		return false
+	}
+
+	if !hmac.Equal([]byte(decoded), expectedMAC) {
+		err = ErrInvalidSignature
 		return false
 	}
+	return true
+}
 
-	return hmac.Equal([]byte(decoded), expectedMAC)
+// Sign returns the hex encoded HMAC signature of the query string that
+// AdminGetAPIToken accepts for secretKey.
+func Sign(secretKey string, query QueryInput) string {
+	mac := hmac.New(sha256.New, []byte(secretKey))
+	mac.Write([]byte(messageFromQuery(query)))
+	return hex.EncodeToString(mac.Sum(nil))
 }
 
 func messageFromQuery(query QueryInput) string {
@@ -201,79 +411,93 @@ func messageFromQuery(query QueryInput) string {
 	return message
 }
 
-func getAPITokenQuery(ctx context.Context, service SharedDiscovery, query QueryInput) ([]map[string]*dynamodb.AttributeValue, error) {
-	_, getAPIKeySpan := beeline.StartSpan(ctx, "getAPITokenQuery")
-	defer getAPIKeySpan.Send()
+func getAPITokenQuery(ctx context.Context, service SharedDiscovery, query QueryInput, options callOptions) (items []map[string]*dynamodb.AttributeValue, err error) {
+	ctx, getAPIKeySpan := service.startSpan(ctx, "getAPITokenQuery")
+	defer func() { finishSpan(getAPIKeySpan, err) }()
 	if query.AppName == "" {
-		appResult, err := service.DynamodbSvc.Scan(&dynamodb.ScanInput{
-			TableName:        &query.Workspace,
-			FilterExpression: aws.String("environment = :e and countryCode = :c and brandName = :b"),
-			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
-				":e": {
-					S: aws.String(query.Environment),
-				},
-				":c": {
-					S: aws.String(query.Country),
-				},
-				":b": {
-					S: aws.String(query.Brand),
+		var output interface{}
+		output, err = service.read(ctx, getAPIKeySpan, "AdminGetAPIToken", query.Workspace, func(ctx context.Context, db dynamodbiface.DynamoDBAPI) (interface{}, error) {
+			return db.ScanWithContext(ctx, &dynamodb.ScanInput{
+				TableName:              &query.Workspace,
+				FilterExpression:       aws.String("environment = :e and countryCode = :c and brandName = :b"),
+				ConsistentRead:         options.consistent(),
+				ReturnConsumedCapacity: options.returnCapacity(),
+				ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+					":e": {
+						S: aws.String(query.Environment),
+					},
+					":c": {
+						S: aws.String(query.Country),
+					},
+					":b": {
+						S: aws.String(query.Brand),
+					},
 				},
-			},
+			})
 		})
 		if err != nil {
 			getAPIKeySpan.AddField("error.message", fmt.Sprintf("Unable to get apiToken from discovery v3 admin: %s", err.Error()))
 			getAPIKeySpan.AddField("query.values", fmt.Sprintf("%s,%s,%s", query.AppName, query.Country, query.Environment))
 			return nil, err
 		}
+		appResult := output.(*dynamodb.ScanOutput)
+		service.observeCapacity(ctx, "AdminGetAPIToken", appResult.ConsumedCapacity)
+		options.addCapacity(appResult.ConsumedCapacity)
 		return appResult.Items, nil
 	}
 
-	appResult, err := service.DynamodbSvc.Query(&dynamodb.QueryInput{
-		TableName: &query.Workspace,
-		IndexName: aws.String("appNameCountryIndex"),
-		KeyConditions: map[string]*dynamodb.Condition{
-			"appName": {
-				ComparisonOperator: aws.String("EQ"),
-				AttributeValueList: []*dynamodb.AttributeValue{
-					{S: aws.String(query.AppName)},
+	output, err := service.read(ctx, getAPIKeySpan, "AdminGetAPIToken", query.Workspace, func(ctx context.Context, db dynamodbiface.DynamoDBAPI) (interface{}, error) {
+		return db.QueryWithContext(ctx, &dynamodb.QueryInput{
+			TableName:              &query.Workspace,
+			IndexName:              aws.String("appNameCountryIndex"),
+			ReturnConsumedCapacity: options.returnCapacity(),
+			KeyConditions: map[string]*dynamodb.Condition{
+				"appName": {
+					ComparisonOperator: aws.String("EQ"),
+					AttributeValueList: []*dynamodb.AttributeValue{
+						{S: aws.String(query.AppName)},
+					},
 				},
-			},
-			"countryCode": {
-				ComparisonOperator: aws.String("EQ"),
-				AttributeValueList: []*dynamodb.AttributeValue{
-					{S: aws.String(query.Country)},
+				"countryCode": {
+					ComparisonOperator: aws.String("EQ"),
+					AttributeValueList: []*dynamodb.AttributeValue{
+						{S: aws.String(query.Country)},
+					},
 				},
 			},
-		},
-		FilterExpression: aws.String("environment = :e"),
-		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
-			":e": {
-				S: aws.String(query.Environment),
+			FilterExpression: aws.String("environment = :e"),
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+				":e": {
+					S: aws.String(query.Environment),
+				},
 			},
-		},
+		})
 	})
 	if err != nil {
 		getAPIKeySpan.AddField("error.message", fmt.Sprintf("Unable to getApiToken from discovery v3 admin: %s", err.Error()))
 		getAPIKeySpan.AddField("query.values", fmt.Sprintf("%s,%s,%s", query.AppName, query.Country, query.Environment))
 		return nil, err
 	}
+	appResult := output.(*dynamodb.QueryOutput)
+	service.observeCapacity(ctx, "AdminGetAPIToken", appResult.ConsumedCapacity)
+	options.addCapacity(appResult.ConsumedCapacity)
 	return appResult.Items, nil
 }
 
-func parseAPIToken(ctx context.Context, result []map[string]*dynamodb.AttributeValue) (string, error) {
-	_, getQueryAPIKeySpan := beeline.StartSpan(ctx, "parseAPIToken")
-	defer getQueryAPIKeySpan.Send()
+func parseAPIToken(ctx context.Context, service SharedDiscovery, result []map[string]*dynamodb.AttributeValue) (token string, app map[string]interface{}, err error) {
+	_, getQueryAPIKeySpan := service.startSpan(ctx, "parseAPIToken")
+	defer func() { finishSpan(getQueryAPIKeySpan, err) }()
 	var discovery map[string]interface{}
 
 	if len(result) > 0 {
-		err := dynamodbattribute.UnmarshalMap(result[0], &discovery)
+		err = dynamodbattribute.UnmarshalMap(result[0], &discovery)
 		if err != nil {
 			getQueryAPIKeySpan.AddField("error.message", fmt.Sprintf("Unable to unmarshal results: %s", err.Error()))
-			return "", err
+			return "", nil, err
 		}
 		getQueryAPIKeySpan.AddField("success.message", "successfully retrieved ApiToken")
-		getQueryAPIKeySpan.Send()
-		return fmt.Sprintf("%v", discovery["apiToken"]), nil
+		return fmt.Sprintf("%v", discovery["apiToken"]), discovery, nil
 	}
-	return "", errors.New("No results found")
+	getQueryAPIKeySpan.AddField("error.message", ErrNoResults.Error())
+	return "", nil, ErrNoResults
 }
@@ -4,11 +4,13 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/golang/mock/gomock"
-	"github.com/pgdevelopers/shareddiscovery/mocks/mock_dynamodbiface"
+	"github.com/pgdevelopers/shareddiscovery/v2/discoverytest"
+	"github.com/pgdevelopers/shareddiscovery/v2/mocks/mock_dynamodbiface"
 )
 
 func TestGetConfig_Success(t *testing.T) {
@@ -24,7 +26,7 @@ func TestGetConfig_Success(t *testing.T) {
 
 	mockDynamoDB.
 		EXPECT().
-		GetItem(&dynamodb.GetItemInput{
+		GetItemWithContext(gomock.Any(), &dynamodb.GetItemInput{
 			TableName: &query.Workspace,
 			Key: map[string]*dynamodb.AttributeValue{
 				"apiToken": {
@@ -55,7 +57,7 @@ func TestGetConfig_Err(t *testing.T) {
 
 	mockDynamoDB.
 		EXPECT().
-		GetItem(&dynamodb.GetItemInput{
+		GetItemWithContext(gomock.Any(), &dynamodb.GetItemInput{
 			TableName: &query.Workspace,
 			Key: map[string]*dynamodb.AttributeValue{
 				"apiToken": {
@@ -82,7 +84,7 @@ func TestGetConfig_WithCountry(t *testing.T) {
 
 	mockDynamoDB.
 		EXPECT().
-		GetItem(&dynamodb.GetItemInput{
+		GetItemWithContext(gomock.Any(), &dynamodb.GetItemInput{
 			TableName: &query.Workspace,
 			Key: map[string]*dynamodb.AttributeValue{
 				"apiToken": {
@@ -101,6 +103,34 @@ func TestGetConfig_WithCountry(t *testing.T) {
 	}
 }
 
+func TestGetConfig_Cached(t *testing.T) {
+	var (
+		ctx          = context.TODO()
+		ctrl         = gomock.NewController(t)
+		mockDynamoDB = mock_dynamodbiface.NewMockDynamoDBAPI(ctrl)
+		self         = New(mockDynamoDB)
+		token        = "apiToken"
+		value        = "value"
+		query        = QueryInput{Workspace: "apps", Country: "US"}
+	)
+	self.Cache = NewMemoryCache(time.Minute)
+
+	mockDynamoDB.
+		EXPECT().
+		GetItemWithContext(gomock.Any(), gomock.Any()).
+		Return(&dynamodb.GetItemOutput{Item: map[string]*dynamodb.AttributeValue{
+			"field": {S: &value},
+		}}, nil).
+		Times(1)
+
+	for i := 0; i < 2; i++ {
+		config, err := self.GetConfig(ctx, token, query)
+		if err != nil || config["field"] != value {
+			t.Errorf("GetConfig(ctx, %q, %q) == %v, %q, want field=%q", token, query.Workspace, config, err, value)
+		}
+	}
+}
+
 func TestAdminGetAPIToken_NoAppName_Success(t *testing.T) {
 	var (
 		ctx          = context.TODO()
@@ -113,7 +143,7 @@ func TestAdminGetAPIToken_NoAppName_Success(t *testing.T) {
 
 	mockDynamoDB.
 		EXPECT().
-		Scan(gomock.Any()).
+		ScanWithContext(gomock.Any(), gomock.Any()).
 		Return(&dynamodb.ScanOutput{
 			Items: []map[string]*dynamodb.AttributeValue{
 				{"field1": &dynamodb.AttributeValue{S: &value}},
@@ -137,7 +167,7 @@ func TestAdminGetAPIToken_NoAppName_EmptyResult(t *testing.T) {
 
 	mockDynamoDB.
 		EXPECT().
-		Scan(gomock.Any()).
+		ScanWithContext(gomock.Any(), gomock.Any()).
 		Return(&dynamodb.ScanOutput{
 			Items: []map[string]*dynamodb.AttributeValue{},
 		}, nil)
@@ -159,7 +189,7 @@ func TestAdminGetAPIToken_NoAppName_Error(t *testing.T) {
 
 	mockDynamoDB.
 		EXPECT().
-		Scan(gomock.Any()).
+		ScanWithContext(gomock.Any(), gomock.Any()).
 		Return(nil, errors.New("something bad happened"))
 
 		// if err doesn't exist fail
@@ -180,7 +210,7 @@ func TestAdminGetAPIToken_WithAppName_Success(t *testing.T) {
 
 	mockDynamoDB.
 		EXPECT().
-		Query(gomock.Any()).
+		QueryWithContext(gomock.Any(), gomock.Any()).
 		Return(&dynamodb.QueryOutput{
 			Items: []map[string]*dynamodb.AttributeValue{
 				{"field1": &dynamodb.AttributeValue{S: &value}},
@@ -204,7 +234,7 @@ func TestAdminGetAPIToken_WithAppName_Empty(t *testing.T) {
 
 	mockDynamoDB.
 		EXPECT().
-		Query(gomock.Any()).
+		QueryWithContext(gomock.Any(), gomock.Any()).
 		Return(&dynamodb.QueryOutput{
 			Items: []map[string]*dynamodb.AttributeValue{},
 		}, nil)
@@ -225,7 +255,7 @@ func TestAdminGetAPIToken_WithAppName_Err(t *testing.T) {
 
 	mockDynamoDB.
 		EXPECT().
-		Query(gomock.Any()).
+		QueryWithContext(gomock.Any(), gomock.Any()).
 		Return(nil, errors.New("something bad"))
 
 	if _, err := self.AdminGetAPIToken(ctx, secretKey, query); err == nil {
@@ -247,6 +277,17 @@ func TestAdminGetAPIToken_InvalidSignature(t *testing.T) {
 	}
 }
 
+func TestSign(t *testing.T) {
+	var (
+		query     = generateQueryWithAppName()
+		secretKey = "secretKey"
+	)
+
+	if got := Sign(secretKey, query); got != query.Signature {
+		t.Errorf("Sign(%q, %q) == %q, want %q", secretKey, query, got, query.Signature)
+	}
+}
+
 ////////////////////////////////////////////////////////////////
 // EXAMPLES
 ///////////////////////////////////////////////////////////////
@@ -371,3 +412,36 @@ func generateQueryWithAppName() QueryInput {
 		},
 	}
 }
+
+func TestSharedDiscovery_InMemoryDynamoDB(t *testing.T) {
+	var (
+		ctx        = context.TODO()
+		db         = discoverytest.NewDynamoDB()
+		self       = New(db)
+		withApp    = generateQueryWithAppName()
+		withoutApp = generateQueryWithoutAppName()
+	)
+	withoutApp.Signature = Sign("secretKey", withoutApp)
+
+	db.Seed(t, DiscoveryAppTable.CreateTableInput("discovery_app"),
+		map[string]string{"apiToken": "qa-token", "appName": "sonos", "countryCode": "US", "brandName": "oralb", "environment": "qa", "api": "https://qa"},
+		map[string]string{"apiToken": "prod-token", "appName": "sonos", "countryCode": "US", "brandName": "oralb", "environment": "prod", "api": "https://prod"},
+	)
+
+	if token, err := self.AdminGetAPIToken(ctx, "secretKey", withApp); token != "qa-token" || err != nil {
+		t.Errorf("AdminGetAPIToken(ctx, %q, withApp) == %q, %v, want qa-token", "secretKey", token, err)
+	}
+	if token, err := self.AdminGetAPIToken(ctx, "secretKey", withoutApp); token != "qa-token" || err != nil {
+		t.Errorf("AdminGetAPIToken(ctx, %q, withoutApp) == %q, %v, want qa-token", "secretKey", token, err)
+	}
+	if valid, err := self.GetValidation(ctx, QueryInput{AppName: "sonos", Country: "CA"}); valid || err != nil {
+		t.Errorf("GetValidation(ctx, sonos CA) == %v, %v, want false", valid, err)
+	}
+	if valid, err := self.GetValidation(ctx, withApp); !valid || err != nil {
+		t.Errorf("GetValidation(ctx, sonos US) == %v, %v, want true", valid, err)
+	}
+	config, err := self.GetConfig(ctx, "prod-token", withApp)
+	if err != nil || config["api"] != "https://prod" {
+		t.Errorf("GetConfig(ctx, %q, withApp) == %v, %v, want the prod config", "prod-token", config, err)
+	}
+}
@@ -0,0 +1,296 @@
+package shareddiscovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// ErrSchemaMismatch is returned by EnsureTables when a table or index exists
+// with keys other than its definition's, which DynamoDB cannot change.
+var ErrSchemaMismatch = errors.New("table schema mismatch")
+
+// TableDefinition is the canonical key schema and global secondary indexes
+// of a discovery table. Every key is a string attribute.
+type TableDefinition struct {
+	HashKey  string
+	RangeKey string
+	Indexes  []IndexDefinition
+}
+
+// IndexDefinition is a global secondary index projecting every attribute.
+type IndexDefinition struct {
+	Name     string
+	HashKey  string
+	RangeKey string
+}
+
+var (
+	// DiscoveryAppTable defines discovery_app, whose apps AdminGetAPIToken
+	// looks up by appNameCountryIndex.
+	DiscoveryAppTable = TableDefinition{
+		HashKey:  "apiToken",
+		RangeKey: "countryCode",
+		Indexes:  []IndexDefinition{{Name: "appNameCountryIndex", HashKey: "appName", RangeKey: "countryCode"}},
+	}
+
+	// WorkspaceTable defines a config workspace read by GetConfig.
+	WorkspaceTable = TableDefinition{HashKey: "apiToken", RangeKey: "countryCode"}
+)
+
+// CreateTableInput returns the input creating table name from the
+// definition, billed on demand. Use it to bootstrap local stand-ins and the
+// discoverytest fake.
+func (definition TableDefinition) CreateTableInput(name string) *dynamodb.CreateTableInput {
+	input := &dynamodb.CreateTableInput{
+		TableName:   aws.String(name),
+		BillingMode: aws.String(dynamodb.BillingModePayPerRequest),
+		KeySchema:   keySchemaOf(definition.HashKey, definition.RangeKey),
+	}
+	attributes := []string{definition.HashKey, definition.RangeKey}
+	for _, index := range definition.Indexes {
+		input.GlobalSecondaryIndexes = append(input.GlobalSecondaryIndexes, &dynamodb.GlobalSecondaryIndex{
+			IndexName:  aws.String(index.Name),
+			KeySchema:  keySchemaOf(index.HashKey, index.RangeKey),
+			Projection: &dynamodb.Projection{ProjectionType: aws.String(dynamodb.ProjectionTypeAll)},
+		})
+		attributes = append(attributes, index.HashKey, index.RangeKey)
+	}
+	input.AttributeDefinitions = attributeDefinitions(attributes...)
+	return input
+}
+
+func keySchemaOf(hash, rng string) []*dynamodb.KeySchemaElement {
+	keys := []*dynamodb.KeySchemaElement{{AttributeName: aws.String(hash), KeyType: aws.String(dynamodb.KeyTypeHash)}}
+	if rng != "" {
+		keys = append(keys, &dynamodb.KeySchemaElement{AttributeName: aws.String(rng), KeyType: aws.String(dynamodb.KeyTypeRange)})
+	}
+	return keys
+}
+
+// attributeDefinitions defines each distinct, non-empty name as a string.
+func attributeDefinitions(names ...string) []*dynamodb.AttributeDefinition {
+	var definitions []*dynamodb.AttributeDefinition
+	seen := map[string]bool{}
+	for _, name := range names {
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		definitions = append(definitions, &dynamodb.AttributeDefinition{
+			AttributeName: aws.String(name),
+			AttributeType: aws.String(dynamodb.ScalarAttributeTypeS),
+		})
+	}
+	return definitions
+}
+
+// compareKeys describes how keys differ from hash and rng. When
+// optionalRange is set a missing range key is accepted, as config
+// workspaces created before countryCode was added only have a hash key.
+func compareKeys(what, hash, rng string, optionalRange bool, keys []*dynamodb.KeySchemaElement) []string {
+	var gotHash, gotRange string
+	for _, key := range keys {
+		switch aws.StringValue(key.KeyType) {
+		case dynamodb.KeyTypeHash:
+			gotHash = aws.StringValue(key.AttributeName)
+		case dynamodb.KeyTypeRange:
+			gotRange = aws.StringValue(key.AttributeName)
+		}
+	}
+	var problems []string
+	if gotHash != hash {
+		problems = append(problems, fmt.Sprintf("%s hash key is %q, want %q", what, gotHash, hash))
+	}
+	if gotRange != rng && !(optionalRange && gotRange == "") {
+		problems = append(problems, fmt.Sprintf("%s range key is %q, want %q", what, gotRange, rng))
+	}
+	return problems
+}
+
+// tables returns discovery_app and the Workspaces with their definitions.
+func (service SharedDiscovery) tables() []namedTable {
+	tables := []namedTable{{"discovery_app", DiscoveryAppTable}}
+	for _, workspace := range service.Workspaces {
+		if workspace != "discovery_app" {
+			tables = append(tables, namedTable{workspace, WorkspaceTable})
+		}
+	}
+	return tables
+}
+
+type namedTable struct {
+	name       string
+	definition TableDefinition
+}
+
+// TableAction is what EnsureTables does, or would do, to a table.
+type TableAction string
+
+// The actions EnsureTables takes.
+const (
+	TableCreate      TableAction = "create"
+	TableCreateIndex TableAction = "create_index"
+	TableUnchanged   TableAction = "unchanged"
+)
+
+// TableChange describes what EnsureTables did to a table. Index names the
+// index a TableCreateIndex created.
+type TableChange struct {
+	Table  string      `json:"table"`
+	Action TableAction `json:"action"`
+	Index  string      `json:"index,omitempty"`
+}
+
+// EnsureOptions configure EnsureTables.
+type EnsureOptions struct {
+	// DryRun reports the changes EnsureTables would make without making them.
+	DryRun bool
+}
+
+// tablePollInterval is how often EnsureTables checks whether a table or
+// index it created is active.
+var tablePollInterval = 2 * time.Second
+
+// EnsureTables creates discovery_app and the Workspaces from their
+// definitions, or the indexes they lack, and waits for them to be active.
+// Tables that already match are left alone, so it is safe to run on every
+// deploy. A table whose keys differ from its definition cannot be fixed in
+// place and stops EnsureTables with ErrSchemaMismatch.
+func (service SharedDiscovery) EnsureTables(ctx context.Context, opts EnsureOptions) (changes []TableChange, err error) {
+	ctx, ensureSpan := service.startSpan(ctx, "EnsureTables")
+	defer func(start time.Time) {
+		service.observe(ctx, "EnsureTables", "", start, err)
+		finishSpan(ensureSpan, err)
+	}(time.Now())
+	ensureSpan.AddField("dry_run", opts.DryRun)
+
+	for _, table := range service.tables() {
+		tableChanges, err := service.EnsureTable(ctx, table.name, table.definition, opts)
+		changes = append(changes, tableChanges...)
+		if err != nil {
+			ensureSpan.AddField("error.message", err.Error())
+			return changes, err
+		}
+	}
+	ensureSpan.AddField("changes.count", len(changes))
+	return changes, nil
+}
+
+// EnsureTable makes table name match definition like EnsureTables.
+func (service SharedDiscovery) EnsureTable(ctx context.Context, name string, definition TableDefinition, opts EnsureOptions) ([]TableChange, error) {
+	output, err := service.DynamodbSvc.DescribeTableWithContext(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(name)})
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) && awsErr.Code() == dynamodb.ErrCodeResourceNotFoundException {
+		changes := []TableChange{{Table: name, Action: TableCreate}}
+		if opts.DryRun {
+			return changes, nil
+		}
+		if _, err := service.DynamodbSvc.CreateTableWithContext(ctx, definition.CreateTableInput(name)); err != nil {
+			return nil, err
+		}
+		return changes, service.waitForTable(ctx, name, "")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	description := output.Table
+	problems := compareKeys("table", definition.HashKey, definition.RangeKey, true, description.KeySchema)
+	existing := map[string]*dynamodb.GlobalSecondaryIndexDescription{}
+	for _, index := range description.GlobalSecondaryIndexes {
+		existing[aws.StringValue(index.IndexName)] = index
+	}
+	var missing []IndexDefinition
+	for _, index := range definition.Indexes {
+		if found, ok := existing[index.Name]; ok {
+			problems = append(problems, compareKeys("index "+index.Name, index.HashKey, index.RangeKey, false, found.KeySchema)...)
+		} else {
+			missing = append(missing, index)
+		}
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("%w: %s: %s", ErrSchemaMismatch, name, strings.Join(problems, "; "))
+	}
+	if len(missing) == 0 {
+		return []TableChange{{Table: name, Action: TableUnchanged}}, nil
+	}
+
+	var changes []TableChange
+	for _, index := range missing {
+		changes = append(changes, TableChange{Table: name, Action: TableCreateIndex, Index: index.Name})
+		if opts.DryRun {
+			continue
+		}
+		// DynamoDB creates one index per update
+		_, err := service.DynamodbSvc.UpdateTableWithContext(ctx, &dynamodb.UpdateTableInput{
+			TableName:            aws.String(name),
+			AttributeDefinitions: attributeDefinitions(index.HashKey, index.RangeKey),
+			GlobalSecondaryIndexUpdates: []*dynamodb.GlobalSecondaryIndexUpdate{{Create: &dynamodb.CreateGlobalSecondaryIndexAction{
+				IndexName:             aws.String(index.Name),
+				KeySchema:             keySchemaOf(index.HashKey, index.RangeKey),
+				Projection:            &dynamodb.Projection{ProjectionType: aws.String(dynamodb.ProjectionTypeAll)},
+				ProvisionedThroughput: indexThroughput(description),
+			}}},
+		})
+		if err != nil {
+			return changes[:len(changes)-1], err
+		}
+		if err := service.waitForTable(ctx, name, index.Name); err != nil {
+			return changes, err
+		}
+	}
+	return changes, nil
+}
+
+// indexThroughput returns the capacity of a provisioned table for a new
+// index to match, and nil for a table billed on demand.
+func indexThroughput(description *dynamodb.TableDescription) *dynamodb.ProvisionedThroughput {
+	if description.BillingModeSummary != nil && aws.StringValue(description.BillingModeSummary.BillingMode) == dynamodb.BillingModePayPerRequest {
+		return nil
+	}
+	provisioned := description.ProvisionedThroughput
+	if provisioned == nil || aws.Int64Value(provisioned.ReadCapacityUnits) == 0 {
+		return nil
+	}
+	return &dynamodb.ProvisionedThroughput{
+		ReadCapacityUnits:  provisioned.ReadCapacityUnits,
+		WriteCapacityUnits: provisioned.WriteCapacityUnits,
+	}
+}
+
+// waitForTable polls until table, or its index when one is named, is active.
+func (service SharedDiscovery) waitForTable(ctx context.Context, table, index string) error {
+	for {
+		output, err := service.DynamodbSvc.DescribeTableWithContext(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(table)})
+		if err != nil {
+			return err
+		}
+		status := aws.StringValue(output.Table.TableStatus)
+		if index != "" {
+			status = ""
+			for _, description := range output.Table.GlobalSecondaryIndexes {
+				if aws.StringValue(description.IndexName) == index {
+					status = aws.StringValue(description.IndexStatus)
+				}
+			}
+		}
+		if status == dynamodb.TableStatusActive {
+			return nil
+		}
+
+		timer := time.NewTimer(tablePollInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
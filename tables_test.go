@@ -0,0 +1,149 @@
+package shareddiscovery
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/golang/mock/gomock"
+	"github.com/pgdevelopers/shareddiscovery/v2/discoverytest"
+	"github.com/pgdevelopers/shareddiscovery/v2/mocks/mock_dynamodbiface"
+)
+
+func setTablePollInterval(interval time.Duration) func() {
+	previous := tablePollInterval
+	tablePollInterval = interval
+	return func() { tablePollInterval = previous }
+}
+
+func TestEnsureTables(t *testing.T) {
+	var (
+		ctx  = context.TODO()
+		db   = discoverytest.NewDynamoDB()
+		self = New(db)
+	)
+	self.Workspaces = []string{"apps"}
+
+	dryRun, err := self.EnsureTables(ctx, EnsureOptions{DryRun: true})
+	want := []TableChange{{Table: "discovery_app", Action: TableCreate}, {Table: "apps", Action: TableCreate}}
+	if err != nil || !reflect.DeepEqual(dryRun, want) {
+		t.Errorf("EnsureTables(ctx, DryRun) == %v, %v, want %v", dryRun, err, want)
+	}
+	if _, err := db.DescribeTable(&dynamodb.DescribeTableInput{TableName: aws.String("apps")}); err == nil {
+		t.Errorf("EnsureTables(ctx, DryRun) created apps")
+	}
+
+	if changes, err := self.EnsureTables(ctx, EnsureOptions{}); err != nil || !reflect.DeepEqual(changes, want) {
+		t.Errorf("EnsureTables(ctx) == %v, %v, want %v", changes, err, want)
+	}
+	if report, err := self.HealthCheck(ctx); err != nil {
+		t.Errorf("HealthCheck(ctx) after EnsureTables == %+v, %v, want healthy", report, err)
+	}
+
+	// running again changes nothing
+	changes, err := self.EnsureTables(ctx, EnsureOptions{})
+	want = []TableChange{{Table: "discovery_app", Action: TableUnchanged}, {Table: "apps", Action: TableUnchanged}}
+	if err != nil || !reflect.DeepEqual(changes, want) {
+		t.Errorf("EnsureTables(ctx) again == %v, %v, want %v", changes, err, want)
+	}
+}
+
+func TestEnsureTables_SchemaMismatch(t *testing.T) {
+	var (
+		ctx  = context.TODO()
+		db   = discoverytest.NewDynamoDB()
+		self = New(db)
+	)
+	db.Seed(t, &dynamodb.CreateTableInput{TableName: aws.String("discovery_app"), KeySchema: keySchemaOf("token", "")})
+
+	_, err := self.EnsureTables(ctx, EnsureOptions{})
+	if !errors.Is(err, ErrSchemaMismatch) {
+		t.Fatalf("EnsureTables(ctx) == %v, want ErrSchemaMismatch", err)
+	}
+	if class := ErrorClass(err); class != "schema_mismatch" {
+		t.Errorf("ErrorClass(%v) == %q, want %q", err, class, "schema_mismatch")
+	}
+}
+
+func TestEnsureTable_CreatesMissingIndex(t *testing.T) {
+	var (
+		ctx          = context.TODO()
+		mockDynamoDB = mock_dynamodbiface.NewMockDynamoDBAPI(gomock.NewController(t))
+		self         = New(mockDynamoDB)
+		throughput   = &dynamodb.ProvisionedThroughputDescription{ReadCapacityUnits: aws.Int64(5), WriteCapacityUnits: aws.Int64(2)}
+	)
+	defer setTablePollInterval(time.Millisecond)()
+	describe := func(indexStatus string) *dynamodb.DescribeTableOutput {
+		table := &dynamodb.TableDescription{
+			TableStatus:           aws.String(dynamodb.TableStatusActive),
+			KeySchema:             keySchemaOf("apiToken", "countryCode"),
+			ProvisionedThroughput: throughput,
+		}
+		if indexStatus != "" {
+			table.GlobalSecondaryIndexes = []*dynamodb.GlobalSecondaryIndexDescription{{
+				IndexName:   aws.String("appNameCountryIndex"),
+				IndexStatus: aws.String(indexStatus),
+				KeySchema:   keySchemaOf("appName", "countryCode"),
+			}}
+		}
+		return &dynamodb.DescribeTableOutput{Table: table}
+	}
+
+	gomock.InOrder(
+		mockDynamoDB.
+			EXPECT().
+			DescribeTableWithContext(gomock.Any(), gomock.Any()).
+			Return(describe(""), nil),
+		mockDynamoDB.
+			EXPECT().
+			UpdateTableWithContext(gomock.Any(), &dynamodb.UpdateTableInput{
+				TableName:            aws.String("discovery_app"),
+				AttributeDefinitions: attributeDefinitions("appName", "countryCode"),
+				GlobalSecondaryIndexUpdates: []*dynamodb.GlobalSecondaryIndexUpdate{{Create: &dynamodb.CreateGlobalSecondaryIndexAction{
+					IndexName:  aws.String("appNameCountryIndex"),
+					KeySchema:  keySchemaOf("appName", "countryCode"),
+					Projection: &dynamodb.Projection{ProjectionType: aws.String(dynamodb.ProjectionTypeAll)},
+					// a provisioned table's index gets the same capacity
+					ProvisionedThroughput: &dynamodb.ProvisionedThroughput{ReadCapacityUnits: aws.Int64(5), WriteCapacityUnits: aws.Int64(2)},
+				}}},
+			}).
+			Return(&dynamodb.UpdateTableOutput{}, nil),
+		mockDynamoDB.
+			EXPECT().
+			DescribeTableWithContext(gomock.Any(), gomock.Any()).
+			Return(describe(dynamodb.IndexStatusCreating), nil),
+		mockDynamoDB.
+			EXPECT().
+			DescribeTableWithContext(gomock.Any(), gomock.Any()).
+			Return(describe(dynamodb.IndexStatusActive), nil),
+	)
+
+	changes, err := self.EnsureTable(ctx, "discovery_app", DiscoveryAppTable, EnsureOptions{})
+	want := []TableChange{{Table: "discovery_app", Action: TableCreateIndex, Index: "appNameCountryIndex"}}
+	if err != nil || !reflect.DeepEqual(changes, want) {
+		t.Errorf("EnsureTable(ctx, discovery_app) == %v, %v, want %v", changes, err, want)
+	}
+}
+
+func TestEnsureTable_Error(t *testing.T) {
+	var (
+		ctx          = context.TODO()
+		mockDynamoDB = mock_dynamodbiface.NewMockDynamoDBAPI(gomock.NewController(t))
+		self         = New(mockDynamoDB)
+		denied       = awserr.New("AccessDeniedException", "not allowed", nil)
+	)
+
+	mockDynamoDB.
+		EXPECT().
+		DescribeTableWithContext(gomock.Any(), gomock.Any()).
+		Return(nil, denied)
+
+	if _, err := self.EnsureTable(ctx, "apps", WorkspaceTable, EnsureOptions{}); !errors.Is(err, denied) {
+		t.Errorf("EnsureTable(ctx, apps) == %v, want %v", err, denied)
+	}
+}
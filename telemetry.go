@@ -0,0 +1,241 @@
+package shareddiscovery
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/honeycombio/beeline-go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/instrument/syncfloat64"
+	"go.opentelemetry.io/otel/metric/instrument/syncint64"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Metric names emitted by SharedDiscovery. Every metric is tagged with the
+// operation and workspace it belongs to.
+const (
+	// MetricRequests counts calls, additionally tagged with their outcome.
+	MetricRequests = "discovery.requests"
+	// MetricLatency records the duration of calls in milliseconds.
+	MetricLatency = "discovery.latency_ms"
+	// MetricCache counts cache lookups, tagged with a result of hit or miss.
+	MetricCache = "discovery.cache"
+	// MetricConsumedCapacity records the DynamoDB capacity units a call consumed.
+	MetricConsumedCapacity = "discovery.consumed_capacity"
+	// MetricRegion counts reads served by each Failover region, tagged with the region.
+	MetricRegion = "discovery.region"
+)
+
+// Span is a single unit of work started by a Tracer. Fields become
+// attributes on the span and Send finishes it.
+type Span interface {
+	AddField(key string, val interface{})
+	Send()
+}
+
+// Tracer starts spans. The returned context carries the new span so spans
+// started from it are its children.
+type Tracer interface {
+	StartSpan(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Metrics records measurements about discovery calls.
+type Metrics interface {
+	Count(ctx context.Context, name string, value int64, tags map[string]string)
+	Histogram(ctx context.Context, name string, value float64, tags map[string]string)
+}
+
+// BeelineTracer sends spans to Honeycomb through beeline. It is the default
+// Tracer when none is configured.
+type BeelineTracer struct{}
+
+// StartSpan starts a beeline span.
+func (BeelineTracer) StartSpan(ctx context.Context, name string) (context.Context, Span) {
+	return beeline.StartSpan(ctx, name)
+}
+
+// BeelineMetrics records measurements as fields on the beeline span in the
+// context, which is how Honeycomb expects them. It is the default Metrics
+// when none is configured.
+type BeelineMetrics struct{}
+
+// Count adds value as the name field of the current span.
+func (BeelineMetrics) Count(ctx context.Context, name string, value int64, tags map[string]string) {
+	beeline.AddField(ctx, metricField(name, tags), value)
+}
+
+// Histogram adds value as the name field of the current span.
+func (BeelineMetrics) Histogram(ctx context.Context, name string, value float64, tags map[string]string) {
+	beeline.AddField(ctx, metricField(name, tags), value)
+}
+
+// cache lookups and outcomes are kept apart so one span can carry both
+func metricField(name string, tags map[string]string) string {
+	if result, ok := tags["result"]; ok {
+		return name + "." + result
+	}
+	return name
+}
+
+// OTelTracer starts OpenTelemetry spans.
+type OTelTracer struct {
+	Tracer trace.Tracer
+}
+
+// NewOTelTracer returns an OTelTracer using a tracer from provider.
+func NewOTelTracer(provider trace.TracerProvider) OTelTracer {
+	return OTelTracer{Tracer: provider.Tracer("github.com/pgdevelopers/shareddiscovery/v2")}
+}
+
+// StartSpan starts an OpenTelemetry span.
+func (tracer OTelTracer) StartSpan(ctx context.Context, name string) (context.Context, Span) {
+	ctx, span := tracer.Tracer.Start(ctx, name)
+	return ctx, otelSpan{span}
+}
+
+type otelSpan struct {
+	span trace.Span
+}
+
+func (span otelSpan) AddField(key string, val interface{}) {
+	if key == "error.message" {
+		span.span.SetStatus(codes.Error, fmt.Sprint(val))
+	}
+	span.span.SetAttributes(otelAttribute(key, val))
+}
+
+func (span otelSpan) Send() {
+	span.span.End()
+}
+
+func otelAttribute(key string, val interface{}) attribute.KeyValue {
+	switch v := val.(type) {
+	case string:
+		return attribute.String(key, v)
+	case bool:
+		return attribute.Bool(key, v)
+	case int:
+		return attribute.Int(key, v)
+	case int64:
+		return attribute.Int64(key, v)
+	case float64:
+		return attribute.Float64(key, v)
+	case fmt.Stringer:
+		return attribute.Stringer(key, v)
+	default:
+		return attribute.String(key, fmt.Sprintf("%+v", v))
+	}
+}
+
+// OTelMetrics records measurements with OpenTelemetry instruments, which
+// are created on first use.
+type OTelMetrics struct {
+	meter metric.Meter
+
+	mu         sync.Mutex
+	counters   map[string]syncint64.Counter
+	histograms map[string]syncfloat64.Histogram
+}
+
+// NewOTelMetrics returns an OTelMetrics using a meter from provider.
+func NewOTelMetrics(provider metric.MeterProvider) *OTelMetrics {
+	return &OTelMetrics{
+		meter:      provider.Meter("github.com/pgdevelopers/shareddiscovery/v2"),
+		counters:   map[string]syncint64.Counter{},
+		histograms: map[string]syncfloat64.Histogram{},
+	}
+}
+
+// Count adds value to the counter called name.
+func (metrics *OTelMetrics) Count(ctx context.Context, name string, value int64, tags map[string]string) {
+	metrics.mu.Lock()
+	counter, ok := metrics.counters[name]
+	if !ok {
+		var err error
+		if counter, err = metrics.meter.SyncInt64().Counter(name); err != nil {
+			metrics.mu.Unlock()
+			return
+		}
+		metrics.counters[name] = counter
+	}
+	metrics.mu.Unlock()
+	counter.Add(ctx, value, otelTags(tags)...)
+}
+
+// Histogram records value in the histogram called name.
+func (metrics *OTelMetrics) Histogram(ctx context.Context, name string, value float64, tags map[string]string) {
+	metrics.mu.Lock()
+	histogram, ok := metrics.histograms[name]
+	if !ok {
+		var err error
+		if histogram, err = metrics.meter.SyncFloat64().Histogram(name); err != nil {
+			metrics.mu.Unlock()
+			return
+		}
+		metrics.histograms[name] = histogram
+	}
+	metrics.mu.Unlock()
+	histogram.Record(ctx, value, otelTags(tags)...)
+}
+
+func otelTags(tags map[string]string) []attribute.KeyValue {
+	attributes := make([]attribute.KeyValue, 0, len(tags))
+	for key, value := range tags {
+		attributes = append(attributes, attribute.String(key, value))
+	}
+	return attributes
+}
+
+// startSpan starts a span on the configured Tracer whose fields are redacted.
+func (service SharedDiscovery) startSpan(ctx context.Context, name string) (context.Context, Span) {
+	var tracer Tracer = BeelineTracer{}
+	if service.Tracer != nil {
+		tracer = service.Tracer
+	}
+	ctx, span := tracer.StartSpan(ctx, name)
+	return ctx, redactingSpan{Span: span, redactor: service.redactor()}
+}
+
+func (service SharedDiscovery) metrics() Metrics {
+	if service.Metrics == nil {
+		return BeelineMetrics{}
+	}
+	return service.Metrics
+}
+
+// observe records the request count and latency of an operation.
+func (service SharedDiscovery) observe(ctx context.Context, operation, workspace string, start time.Time, err error) {
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+	}
+	tags := map[string]string{"operation": operation, "workspace": workspace}
+	service.metrics().Histogram(ctx, MetricLatency, float64(time.Since(start))/float64(time.Millisecond), tags)
+	service.metrics().Count(ctx, MetricRequests, 1, map[string]string{"operation": operation, "workspace": workspace, "outcome": outcome})
+}
+
+func (service SharedDiscovery) observeCache(ctx context.Context, operation, workspace string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	service.metrics().Count(ctx, MetricCache, 1, map[string]string{"operation": operation, "workspace": workspace, "result": result})
+}
+
+func (service SharedDiscovery) observeCapacity(ctx context.Context, operation string, capacity ...*dynamodb.ConsumedCapacity) {
+	for _, consumed := range capacity {
+		if consumed == nil || consumed.CapacityUnits == nil {
+			continue
+		}
+		service.metrics().Histogram(ctx, MetricConsumedCapacity, aws.Float64Value(consumed.CapacityUnits), map[string]string{
+			"operation": operation,
+			"workspace": aws.StringValue(consumed.TableName),
+		})
+	}
+}
@@ -0,0 +1,347 @@
+package shareddiscovery
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/golang/mock/gomock"
+	"github.com/pgdevelopers/shareddiscovery/v2/mocks/mock_dynamodbiface"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric/nonrecording"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestMetrics_GetConfig(t *testing.T) {
+	var (
+		ctx          = context.TODO()
+		mockDynamoDB = mock_dynamodbiface.NewMockDynamoDBAPI(gomock.NewController(t))
+		metrics      = &recordingMetrics{}
+		self         = New(mockDynamoDB)
+		query        = QueryInput{Workspace: "apps"}
+	)
+	self.Metrics = metrics
+	self.Cache = NewMemoryCache(0)
+
+	mockDynamoDB.
+		EXPECT().
+		GetItemWithContext(gomock.Any(), gomock.Any()).
+		Return(&dynamodb.GetItemOutput{
+			Item:             map[string]*dynamodb.AttributeValue{"field": {S: aws.String("value")}},
+			ConsumedCapacity: &dynamodb.ConsumedCapacity{TableName: aws.String("apps"), CapacityUnits: aws.Float64(0.5)},
+		}, nil)
+
+	for i := 0; i < 2; i++ {
+		if _, err := self.GetConfig(ctx, "apiToken", query); err != nil {
+			t.Fatalf("GetConfig(ctx, %q, %q) == %q, want nil", "apiToken", query.Workspace, err)
+		}
+	}
+
+	if got := metrics.sum(MetricRequests, map[string]string{"operation": "GetConfig", "workspace": "apps", "outcome": "success"}); got != 2 {
+		t.Errorf("%s == %v, want 2", MetricRequests, got)
+	}
+	if got := metrics.sum(MetricCache, map[string]string{"operation": "GetConfig", "workspace": "apps", "result": "miss"}); got != 1 {
+		t.Errorf("%s misses == %v, want 1", MetricCache, got)
+	}
+	if got := metrics.sum(MetricCache, map[string]string{"operation": "GetConfig", "workspace": "apps", "result": "hit"}); got != 1 {
+		t.Errorf("%s hits == %v, want 1", MetricCache, got)
+	}
+	if got := metrics.sum(MetricConsumedCapacity, map[string]string{"operation": "GetConfig", "workspace": "apps"}); got != 0.5 {
+		t.Errorf("%s == %v, want 0.5", MetricConsumedCapacity, got)
+	}
+	if got := metrics.count(MetricLatency); got != 2 {
+		t.Errorf("%s recorded %d times, want 2", MetricLatency, got)
+	}
+}
+
+func TestMetrics_ErrorOutcome(t *testing.T) {
+	var (
+		ctx          = context.TODO()
+		mockDynamoDB = mock_dynamodbiface.NewMockDynamoDBAPI(gomock.NewController(t))
+		metrics      = &recordingMetrics{}
+		self         = New(mockDynamoDB)
+		query        = generateQueryWithAppName()
+	)
+	self.Metrics = metrics
+
+	mockDynamoDB.EXPECT().QueryWithContext(gomock.Any(), gomock.Any()).Return(nil, errors.New("something bad"))
+
+	_, _ = self.AdminGetAPIToken(ctx, "secretKey", query)
+	if got := metrics.sum(MetricRequests, map[string]string{"operation": "AdminGetAPIToken", "workspace": query.Workspace, "outcome": "error"}); got != 1 {
+		t.Errorf("%s errors == %v, want 1", MetricRequests, got)
+	}
+}
+
+func TestOTelTracer(t *testing.T) {
+	tracer := NewOTelTracer(trace.NewNoopTracerProvider())
+	ctx, span := tracer.StartSpan(context.TODO(), "GetConfig")
+	span.AddField("workspace", "apps")
+	span.AddField("error.message", "something bad")
+	span.Send()
+
+	if ctx == nil {
+		t.Errorf("StartSpan(ctx, %q) returned a nil context", "GetConfig")
+	}
+}
+
+func TestOTelMetrics(t *testing.T) {
+	metrics := NewOTelMetrics(nonrecording.NewNoopMeterProvider())
+	metrics.Count(context.TODO(), MetricRequests, 1, map[string]string{"operation": "GetConfig"})
+	metrics.Histogram(context.TODO(), MetricLatency, 1.5, map[string]string{"operation": "GetConfig"})
+
+	if len(metrics.counters) != 1 || len(metrics.histograms) != 1 {
+		t.Errorf("NewOTelMetrics() created %d counters and %d histograms, want 1 of each", len(metrics.counters), len(metrics.histograms))
+	}
+}
+
+func TestOTelAttribute(t *testing.T) {
+	tests := []struct {
+		val  interface{}
+		want attribute.Value
+	}{
+		{"apps", attribute.StringValue("apps")},
+		{true, attribute.BoolValue(true)},
+		{3, attribute.IntValue(3)},
+		{int64(3), attribute.Int64Value(3)},
+		{0.5, attribute.Float64Value(0.5)},
+		{ConfigKey{Workspace: "apps"}, attribute.StringValue("{Workspace:apps APIToken: Country:}")},
+	}
+
+	for _, test := range tests {
+		if got := otelAttribute("key", test.val); got.Value != test.want {
+			t.Errorf("otelAttribute(%q, %v) == %v, want %v", "key", test.val, got.Value.Emit(), test.want.Emit())
+		}
+	}
+}
+
+type measurement struct {
+	name  string
+	value float64
+	tags  map[string]string
+}
+
+// recordingMetrics keeps every measurement in memory.
+type recordingMetrics struct {
+	mu           sync.Mutex
+	measurements []measurement
+}
+
+func (metrics *recordingMetrics) Count(_ context.Context, name string, value int64, tags map[string]string) {
+	metrics.record(name, float64(value), tags)
+}
+
+func (metrics *recordingMetrics) Histogram(_ context.Context, name string, value float64, tags map[string]string) {
+	metrics.record(name, value, tags)
+}
+
+func (metrics *recordingMetrics) record(name string, value float64, tags map[string]string) {
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+	metrics.measurements = append(metrics.measurements, measurement{name, value, tags})
+}
+
+// sum adds up the measurements called name that carry all of tags.
+func (metrics *recordingMetrics) sum(name string, tags map[string]string) float64 {
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+	var total float64
+	for _, m := range metrics.measurements {
+		if m.name == name && hasTags(m.tags, tags) {
+			total += m.value
+		}
+	}
+	return total
+}
+
+func (metrics *recordingMetrics) count(name string) int {
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+	n := 0
+	for _, m := range metrics.measurements {
+		if m.name == name {
+			n++
+		}
+	}
+	return n
+}
+
+func hasTags(got, want map[string]string) bool {
+	for key, value := range want {
+		if got[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSpans_GetConfigError(t *testing.T) {
+	var (
+		ctx          = context.TODO()
+		mockDynamoDB = mock_dynamodbiface.NewMockDynamoDBAPI(gomock.NewController(t))
+		tracer       = &recordingTracer{}
+		self         = New(mockDynamoDB)
+		query        = QueryInput{Workspace: "apps"}
+	)
+	self.Tracer = tracer
+
+	mockDynamoDB.EXPECT().GetItemWithContext(gomock.Any(), gomock.Any()).Return(nil, errors.New("something bad"))
+
+	_, _ = self.GetConfig(ctx, "apiToken", query)
+	tracer.assertAllSentOnce(t)
+	span := tracer.find("GetConfig")
+	if span.fields["outcome"] != "error" || span.fields["error.class"] != "internal" || span.fields["error.message"] != "something bad" {
+		t.Errorf("GetConfig span fields == %v, want an internal error", span.fields)
+	}
+}
+
+func TestSpans_GetValidationError(t *testing.T) {
+	var (
+		ctx          = context.TODO()
+		mockDynamoDB = mock_dynamodbiface.NewMockDynamoDBAPI(gomock.NewController(t))
+		tracer       = &recordingTracer{}
+		self         = New(mockDynamoDB)
+	)
+	self.Tracer = tracer
+
+	mockDynamoDB.EXPECT().ScanWithContext(gomock.Any(), gomock.Any()).Return(nil, awserr.New(dynamodb.ErrCodeResourceNotFoundException, "no table", nil))
+
+	_, _ = self.GetValidation(ctx, QueryInput{AppName: "sonos", Country: "US"})
+	tracer.assertAllSentOnce(t)
+	if class := tracer.find("GetValidation").fields["error.class"]; class != "aws.ResourceNotFoundException" {
+		t.Errorf("GetValidation span error.class == %v, want aws.ResourceNotFoundException", class)
+	}
+}
+
+func TestSpans_AdminGetAPITokenNesting(t *testing.T) {
+	var (
+		ctx          = context.TODO()
+		mockDynamoDB = mock_dynamodbiface.NewMockDynamoDBAPI(gomock.NewController(t))
+		tracer       = &recordingTracer{}
+		self         = New(mockDynamoDB)
+		query        = generateQueryWithAppName()
+	)
+	self.Tracer = tracer
+
+	mockDynamoDB.
+		EXPECT().
+		QueryWithContext(gomock.Any(), gomock.Any()).
+		Return(&dynamodb.QueryOutput{Items: []map[string]*dynamodb.AttributeValue{
+			{"apiToken": {S: aws.String("token")}},
+		}}, nil)
+
+	if _, err := self.AdminGetAPIToken(ctx, "secretKey", query); err != nil {
+		t.Fatalf("AdminGetAPIToken(ctx, %q, %q) == %q, want nil", "secretKey", query, err)
+	}
+	tracer.assertAllSentOnce(t)
+
+	root := tracer.find("adminGetAPIToken")
+	if root.parent != nil || root.fields["outcome"] != "success" {
+		t.Errorf("adminGetAPIToken span == %+v, want a successful root span", root)
+	}
+	for _, name := range []string{"validateSignature", "getAPITokenQuery", "parseAPIToken"} {
+		if span := tracer.find(name); span.parent != root {
+			t.Errorf("%s span parent == %v, want adminGetAPIToken", name, span.parent)
+		}
+	}
+}
+
+func TestSpans_AdminGetAPITokenErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		secretKey string
+		items     []map[string]*dynamodb.AttributeValue
+		class     string
+	}{
+		{"invalid signature", "badSecret", nil, "invalid_signature"},
+		{"no results", "secretKey", []map[string]*dynamodb.AttributeValue{}, "not_found"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var (
+				mockDynamoDB = mock_dynamodbiface.NewMockDynamoDBAPI(gomock.NewController(t))
+				tracer       = &recordingTracer{}
+				self         = New(mockDynamoDB)
+				query        = generateQueryWithAppName()
+			)
+			self.Tracer = tracer
+			if test.items != nil {
+				mockDynamoDB.EXPECT().QueryWithContext(gomock.Any(), gomock.Any()).Return(&dynamodb.QueryOutput{Items: test.items}, nil)
+			}
+
+			_, _ = self.AdminGetAPIToken(context.TODO(), test.secretKey, query)
+			tracer.assertAllSentOnce(t)
+			if class := tracer.find("adminGetAPIToken").fields["error.class"]; class != test.class {
+				t.Errorf("adminGetAPIToken span error.class == %v, want %s", class, test.class)
+			}
+		})
+	}
+}
+
+type spanKey struct{}
+
+// recordedSpan is a span kept in memory by recordingTracer.
+type recordedSpan struct {
+	mu     sync.Mutex
+	name   string
+	parent *recordedSpan
+	fields map[string]interface{}
+	sent   int
+}
+
+func (span *recordedSpan) AddField(key string, val interface{}) {
+	span.mu.Lock()
+	defer span.mu.Unlock()
+	span.fields[key] = val
+}
+
+func (span *recordedSpan) Send() {
+	span.mu.Lock()
+	defer span.mu.Unlock()
+	span.sent++
+}
+
+// recordingTracer keeps every span it starts in memory.
+type recordingTracer struct {
+	mu    sync.Mutex
+	spans []*recordedSpan
+}
+
+func (tracer *recordingTracer) StartSpan(ctx context.Context, name string) (context.Context, Span) {
+	span := &recordedSpan{name: name, fields: map[string]interface{}{}}
+	span.parent, _ = ctx.Value(spanKey{}).(*recordedSpan)
+
+	tracer.mu.Lock()
+	tracer.spans = append(tracer.spans, span)
+	tracer.mu.Unlock()
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+func (tracer *recordingTracer) find(name string) *recordedSpan {
+	tracer.mu.Lock()
+	defer tracer.mu.Unlock()
+	for _, span := range tracer.spans {
+		if span.name == name {
+			return span
+		}
+	}
+	return &recordedSpan{name: name, fields: map[string]interface{}{}}
+}
+
+func (tracer *recordingTracer) assertAllSentOnce(t *testing.T) {
+	t.Helper()
+	tracer.mu.Lock()
+	defer tracer.mu.Unlock()
+	if len(tracer.spans) == 0 {
+		t.Errorf("no spans were started")
+	}
+	for _, span := range tracer.spans {
+		if span.sent != 1 {
+			t.Errorf("%s span was sent %d times, want 1", span.name, span.sent)
+		}
+	}
+}
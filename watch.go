@@ -0,0 +1,347 @@
+package shareddiscovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodbstreams"
+)
+
+const (
+	defaultWatchPollInterval = time.Second
+	// shards are re-described every this many polls to pick up splits
+	shardRefreshPolls = 30
+)
+
+// ChangeType is the kind of modification a ChangeEvent describes.
+type ChangeType string
+
+// The change types reported by DynamoDB Streams.
+const (
+	ChangeInsert ChangeType = dynamodbstreams.OperationTypeInsert
+	ChangeModify ChangeType = dynamodbstreams.OperationTypeModify
+	ChangeRemove ChangeType = dynamodbstreams.OperationTypeRemove
+)
+
+// ChangeEvent is delivered by Watch for every change to a watched row. With
+// Encryption the configs are decrypted, as GetConfig returns them. When Err
+// is set the watch has stopped and the channel will be closed.
+type ChangeEvent struct {
+	Type           ChangeType
+	Key            ConfigKey
+	OldConfig      map[string]interface{}
+	NewConfig      map[string]interface{}
+	SequenceNumber string
+	Time           time.Time
+	Err            error
+}
+
+// Checkpointer stores the last sequence number Watch delivered for each shard
+// so that a restarted watch can resume where the previous one stopped.
+type Checkpointer interface {
+	Checkpoint(ctx context.Context, streamArn, shardID, sequenceNumber string) error
+	LastSequenceNumber(ctx context.Context, streamArn, shardID string) (string, error)
+}
+
+// MemoryCheckpointer is an in-process Checkpointer. It is useful for
+// resuming a watch within the same process and for tests.
+type MemoryCheckpointer struct {
+	mu        sync.Mutex
+	sequences map[string]string
+}
+
+// NewMemoryCheckpointer returns an empty MemoryCheckpointer.
+func NewMemoryCheckpointer() *MemoryCheckpointer {
+	return &MemoryCheckpointer{sequences: map[string]string{}}
+}
+
+// Checkpoint records sequenceNumber as the last one delivered for the shard.
+func (checkpointer *MemoryCheckpointer) Checkpoint(_ context.Context, streamArn, shardID, sequenceNumber string) error {
+	checkpointer.mu.Lock()
+	defer checkpointer.mu.Unlock()
+	checkpointer.sequences[streamArn+"/"+shardID] = sequenceNumber
+	return nil
+}
+
+// LastSequenceNumber returns the last checkpointed sequence number for the
+// shard, or an empty string when there is none.
+func (checkpointer *MemoryCheckpointer) LastSequenceNumber(_ context.Context, streamArn, shardID string) (string, error) {
+	checkpointer.mu.Lock()
+	defer checkpointer.mu.Unlock()
+	return checkpointer.sequences[streamArn+"/"+shardID], nil
+}
+
+// Watch consumes the DynamoDB Stream of the given workspace and delivers a
+// ChangeEvent for every change to a row matching one of keys. An empty keys
+// slice watches every row. A key without a Country matches all countries.
+//
+// The returned channel is closed when ctx is cancelled or the watch fails,
+// in which case the final event carries the error. When a Cache is configured
+// the entry of every changed row is invalidated, whether or not it matches
+// keys, before any event is delivered.
+func (service SharedDiscovery) Watch(ctx context.Context, workspace string, keys []ConfigKey) (events <-chan ChangeEvent, err error) {
+	// the watch outlives this span, so its context is not handed to the watcher
+	_, watchSpan := service.startSpan(ctx, "Watch")
+	defer func() { finishSpan(watchSpan, err) }()
+	watchSpan.AddField("workspace", workspace)
+
+	if service.StreamsSvc == nil {
+		err = errors.New("shareddiscovery: Watch requires StreamsSvc")
+		watchSpan.AddField("error.message", err.Error())
+		return nil, err
+	}
+
+	table, err := service.DynamodbSvc.DescribeTableWithContext(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(workspace)})
+	if err != nil {
+		watchSpan.AddField("error.message", err.Error())
+		return nil, err
+	}
+	if table.Table == nil || table.Table.LatestStreamArn == nil {
+		err = fmt.Errorf("shareddiscovery: no stream enabled on %s", workspace)
+		watchSpan.AddField("error.message", err.Error())
+		return nil, err
+	}
+
+	w := &watcher{
+		service:   service,
+		workspace: workspace,
+		keys:      keys,
+		streamArn: aws.StringValue(table.Table.LatestStreamArn),
+		iterators: map[string]*string{},
+		events:    make(chan ChangeEvent),
+	}
+	if w.service.Checkpointer == nil {
+		w.service.Checkpointer = NewMemoryCheckpointer()
+	}
+	if w.service.WatchPollInterval <= 0 {
+		w.service.WatchPollInterval = defaultWatchPollInterval
+	}
+	watchSpan.AddField("stream.arn", w.streamArn)
+
+	go w.run(ctx)
+	return w.events, nil
+}
+
+type watcher struct {
+	service   SharedDiscovery
+	workspace string
+	keys      []ConfigKey
+	streamArn string
+	// open shard iterators keyed by shard id
+	iterators map[string]*string
+	// shards that have been fully read
+	finished map[string]bool
+	started  bool
+	events   chan ChangeEvent
+}
+
+func (w *watcher) run(ctx context.Context) {
+	defer close(w.events)
+	w.finished = map[string]bool{}
+
+	ticker := time.NewTicker(w.service.WatchPollInterval)
+	defer ticker.Stop()
+
+	refresh := true
+	for polls := 1; ; polls++ {
+		if refresh || polls%shardRefreshPolls == 0 {
+			if err := w.refreshShards(ctx); err != nil {
+				w.fail(ctx, err)
+				return
+			}
+			refresh = false
+		}
+
+		for shardID, iterator := range w.iterators {
+			next, err := w.readShard(ctx, shardID, iterator)
+			if err != nil {
+				w.fail(ctx, err)
+				return
+			}
+			if next == nil {
+				// the shard was closed, its children show up on the next refresh
+				delete(w.iterators, shardID)
+				w.finished[shardID] = true
+				refresh = true
+				continue
+			}
+			w.iterators[shardID] = next
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// refreshShards opens an iterator for every shard that is not already being
+// read. Shards that existed when the watch started begin at the latest record
+// unless a checkpoint exists; shards discovered later are read from the start.
+func (w *watcher) refreshShards(ctx context.Context) error {
+	var lastShardID *string
+	for {
+		stream, err := w.service.StreamsSvc.DescribeStreamWithContext(ctx, &dynamodbstreams.DescribeStreamInput{
+			StreamArn:             aws.String(w.streamArn),
+			ExclusiveStartShardId: lastShardID,
+		})
+		if err != nil {
+			return err
+		}
+		if stream.StreamDescription == nil {
+			return nil
+		}
+
+		for _, shard := range stream.StreamDescription.Shards {
+			shardID := aws.StringValue(shard.ShardId)
+			if _, open := w.iterators[shardID]; open || w.finished[shardID] {
+				continue
+			}
+			iterator, err := w.shardIterator(ctx, shardID)
+			if err != nil {
+				return err
+			}
+			if iterator != nil {
+				w.iterators[shardID] = iterator
+			}
+		}
+
+		lastShardID = stream.StreamDescription.LastEvaluatedShardId
+		if lastShardID == nil {
+			w.started = true
+			return nil
+		}
+	}
+}
+
+func (w *watcher) shardIterator(ctx context.Context, shardID string) (*string, error) {
+	input := &dynamodbstreams.GetShardIteratorInput{
+		StreamArn:         aws.String(w.streamArn),
+		ShardId:           aws.String(shardID),
+		ShardIteratorType: aws.String(dynamodbstreams.ShardIteratorTypeTrimHorizon),
+	}
+	if !w.started {
+		input.ShardIteratorType = aws.String(dynamodbstreams.ShardIteratorTypeLatest)
+	}
+
+	sequenceNumber, err := w.service.Checkpointer.LastSequenceNumber(ctx, w.streamArn, shardID)
+	if err != nil {
+		return nil, err
+	}
+	if sequenceNumber != "" {
+		input.ShardIteratorType = aws.String(dynamodbstreams.ShardIteratorTypeAfterSequenceNumber)
+		input.SequenceNumber = aws.String(sequenceNumber)
+	}
+
+	output, err := w.service.StreamsSvc.GetShardIteratorWithContext(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	return output.ShardIterator, nil
+}
+
+func (w *watcher) readShard(ctx context.Context, shardID string, iterator *string) (*string, error) {
+	output, err := w.service.StreamsSvc.GetRecordsWithContext(ctx, &dynamodbstreams.GetRecordsInput{ShardIterator: iterator})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, record := range output.Records {
+		event, err := w.eventFromRecord(ctx, record)
+		if err != nil {
+			return nil, err
+		}
+		if w.service.Cache != nil {
+			w.service.Cache.Invalidate(event.Key)
+		}
+		if w.matches(event.Key) {
+			select {
+			case w.events <- event:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		if event.SequenceNumber == "" {
+			continue
+		}
+		if err := w.service.Checkpointer.Checkpoint(ctx, w.streamArn, shardID, event.SequenceNumber); err != nil {
+			return nil, err
+		}
+	}
+	return output.NextShardIterator, nil
+}
+
+// eventFromRecord returns the event of record, with encrypted attributes
+// decrypted as GetConfig would return them.
+func (w *watcher) eventFromRecord(ctx context.Context, record *dynamodbstreams.Record) (ChangeEvent, error) {
+	event := ChangeEvent{Type: ChangeType(aws.StringValue(record.EventName))}
+	if record.Dynamodb == nil {
+		return event, nil
+	}
+
+	event.SequenceNumber = aws.StringValue(record.Dynamodb.SequenceNumber)
+	event.Time = aws.TimeValue(record.Dynamodb.ApproximateCreationDateTime)
+	event.Key = ConfigKey{Workspace: w.workspace}
+	if token, ok := record.Dynamodb.Keys["apiToken"]; ok {
+		event.Key.APIToken = aws.StringValue(token.S)
+	}
+	if country, ok := record.Dynamodb.Keys["countryCode"]; ok {
+		event.Key.Country = aws.StringValue(country.S)
+	}
+
+	var err error
+	if event.OldConfig, err = w.config(ctx, event.Key, record.Dynamodb.OldImage); err != nil {
+		return event, err
+	}
+	if event.NewConfig, err = w.config(ctx, event.Key, record.Dynamodb.NewImage); err != nil {
+		return event, err
+	}
+	return event, nil
+}
+
+func (w *watcher) config(ctx context.Context, key ConfigKey, image map[string]*dynamodb.AttributeValue) (map[string]interface{}, error) {
+	if len(image) == 0 {
+		return nil, nil
+	}
+	image, err := w.service.decryptItem(ctx, key, image)
+	if err != nil {
+		return nil, err
+	}
+	var config map[string]interface{}
+	if err := dynamodbattribute.UnmarshalMap(image, &config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func (w *watcher) matches(key ConfigKey) bool {
+	if len(w.keys) == 0 {
+		return true
+	}
+	for _, want := range w.keys {
+		if want.APIToken != key.APIToken {
+			continue
+		}
+		if want.Country == "" || want.Country == key.Country {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *watcher) fail(ctx context.Context, err error) {
+	if ctx.Err() != nil {
+		return
+	}
+	select {
+	case w.events <- ChangeEvent{Err: err}:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,235 @@
+package shareddiscovery
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodbstreams"
+	"github.com/golang/mock/gomock"
+	"github.com/pgdevelopers/shareddiscovery/v2/mocks/mock_dynamodbiface"
+	"github.com/pgdevelopers/shareddiscovery/v2/mocks/mock_dynamodbstreamsiface"
+)
+
+const testStreamArn = "arn:aws:dynamodb:us-east-1:123456789012:table/apps/stream/2021-10-01T00:00:00.000"
+
+func TestWatch_DeliversMatchingChanges(t *testing.T) {
+	var (
+		ctx, cancel  = context.WithCancel(context.TODO())
+		ctrl         = gomock.NewController(t)
+		mockDynamoDB = mock_dynamodbiface.NewMockDynamoDBAPI(ctrl)
+		mockStreams  = mock_dynamodbstreamsiface.NewMockDynamoDBStreamsAPI(ctrl)
+		cache        = NewMemoryCache(0)
+		checkpoints  = NewMemoryCheckpointer()
+		self         = New(mockDynamoDB)
+		watched      = ConfigKey{Workspace: "apps", APIToken: "token1", Country: "US"}
+		unwatched    = ConfigKey{Workspace: "apps", APIToken: "token2", Country: "US"}
+	)
+	defer cancel()
+	self.StreamsSvc = mockStreams
+	self.Cache = cache
+	self.Checkpointer = checkpoints
+	self.WatchPollInterval = time.Millisecond
+	cache.Set(watched, map[string]interface{}{"field": "old"})
+	cache.Set(unwatched, map[string]interface{}{"field": "old"})
+
+	expectStream(mockDynamoDB, mockStreams)
+	mockStreams.
+		EXPECT().
+		GetRecordsWithContext(gomock.Any(), &dynamodbstreams.GetRecordsInput{ShardIterator: aws.String("iterator-1")}).
+		Return(&dynamodbstreams.GetRecordsOutput{
+			Records: []*dynamodbstreams.Record{
+				streamRecord("MODIFY", "100000000000000000001", "token2", "US"),
+				streamRecord("MODIFY", "100000000000000000002", "token1", "US"),
+			},
+		}, nil)
+
+	events, err := self.Watch(ctx, "apps", []ConfigKey{watched})
+	if err != nil {
+		t.Fatalf("Watch(ctx, %q, %v) == %q, want nil", "apps", watched, err)
+	}
+
+	event := <-events
+	if event.Err != nil {
+		t.Fatalf("Watch(ctx, %q, %v) event error == %q, want nil", "apps", watched, event.Err)
+	}
+	if event.Key != watched || event.Type != ChangeModify {
+		t.Errorf("Watch(ctx, %q, %v) event == %+v, want MODIFY of %v", "apps", watched, event, watched)
+	}
+	if event.NewConfig["field"] != "new" {
+		t.Errorf("Watch(ctx, %q, %v) new config == %v, want field=new", "apps", watched, event.NewConfig)
+	}
+	if _, ok := cache.Get(watched); ok {
+		t.Errorf("cache.Get(%v) found an entry, want it invalidated", watched)
+	}
+	if _, ok := cache.Get(unwatched); ok {
+		t.Errorf("cache.Get(%v) found an entry, want it invalidated", unwatched)
+	}
+
+	cancel()
+	for range events {
+	}
+	if seq, _ := checkpoints.LastSequenceNumber(ctx, testStreamArn, "shardId-00000001"); seq != "100000000000000000002" {
+		t.Errorf("LastSequenceNumber() == %q, want %q", seq, "100000000000000000002")
+	}
+}
+
+func TestWatch_ResumesFromCheckpoint(t *testing.T) {
+	var (
+		ctx, cancel  = context.WithCancel(context.TODO())
+		ctrl         = gomock.NewController(t)
+		mockDynamoDB = mock_dynamodbiface.NewMockDynamoDBAPI(ctrl)
+		mockStreams  = mock_dynamodbstreamsiface.NewMockDynamoDBStreamsAPI(ctrl)
+		checkpoints  = NewMemoryCheckpointer()
+		self         = New(mockDynamoDB)
+	)
+	defer cancel()
+	self.StreamsSvc = mockStreams
+	self.Checkpointer = checkpoints
+	self.WatchPollInterval = time.Millisecond
+	_ = checkpoints.Checkpoint(ctx, testStreamArn, "shardId-00000001", "100000000000000000009")
+
+	mockDynamoDB.
+		EXPECT().
+		DescribeTableWithContext(gomock.Any(), gomock.Any()).
+		Return(&dynamodb.DescribeTableOutput{Table: &dynamodb.TableDescription{LatestStreamArn: aws.String(testStreamArn)}}, nil)
+	mockStreams.
+		EXPECT().
+		DescribeStreamWithContext(gomock.Any(), gomock.Any()).
+		Return(&dynamodbstreams.DescribeStreamOutput{StreamDescription: &dynamodbstreams.StreamDescription{
+			Shards: []*dynamodbstreams.Shard{{ShardId: aws.String("shardId-00000001")}},
+		}}, nil).
+		AnyTimes()
+	mockStreams.
+		EXPECT().
+		GetShardIteratorWithContext(gomock.Any(), &dynamodbstreams.GetShardIteratorInput{
+			StreamArn:         aws.String(testStreamArn),
+			ShardId:           aws.String("shardId-00000001"),
+			ShardIteratorType: aws.String(dynamodbstreams.ShardIteratorTypeAfterSequenceNumber),
+			SequenceNumber:    aws.String("100000000000000000009"),
+		}).
+		Return(&dynamodbstreams.GetShardIteratorOutput{ShardIterator: aws.String("iterator-1")}, nil)
+	mockStreams.
+		EXPECT().
+		GetRecordsWithContext(gomock.Any(), gomock.Any()).
+		Return(&dynamodbstreams.GetRecordsOutput{
+			Records: []*dynamodbstreams.Record{streamRecord("REMOVE", "100000000000000000010", "token1", "US")},
+		}, nil)
+
+	events, err := self.Watch(ctx, "apps", nil)
+	if err != nil {
+		t.Fatalf("Watch(ctx, %q, nil) == %q, want nil", "apps", err)
+	}
+	if event := <-events; event.Type != ChangeRemove || event.SequenceNumber != "100000000000000000010" {
+		t.Errorf("Watch(ctx, %q, nil) event == %+v, want REMOVE 100000000000000000010", "apps", event)
+	}
+}
+
+func TestWatch_StreamNotEnabled(t *testing.T) {
+	var (
+		ctx          = context.TODO()
+		ctrl         = gomock.NewController(t)
+		mockDynamoDB = mock_dynamodbiface.NewMockDynamoDBAPI(ctrl)
+		self         = New(mockDynamoDB)
+	)
+	self.StreamsSvc = mock_dynamodbstreamsiface.NewMockDynamoDBStreamsAPI(ctrl)
+
+	mockDynamoDB.
+		EXPECT().
+		DescribeTableWithContext(gomock.Any(), gomock.Any()).
+		Return(&dynamodb.DescribeTableOutput{Table: &dynamodb.TableDescription{}}, nil)
+
+	if _, err := self.Watch(ctx, "apps", nil); err == nil {
+		t.Errorf("Watch(ctx, %q, nil) == nil, want an error", "apps")
+	}
+}
+
+func TestWatch_NoStreamsClient(t *testing.T) {
+	self := New(mock_dynamodbiface.NewMockDynamoDBAPI(gomock.NewController(t)))
+
+	if _, err := self.Watch(context.TODO(), "apps", nil); err == nil {
+		t.Errorf("Watch(ctx, %q, nil) == nil, want an error", "apps")
+	}
+}
+
+func expectStream(mockDynamoDB *mock_dynamodbiface.MockDynamoDBAPI, mockStreams *mock_dynamodbstreamsiface.MockDynamoDBStreamsAPI) {
+	mockDynamoDB.
+		EXPECT().
+		DescribeTableWithContext(gomock.Any(), &dynamodb.DescribeTableInput{TableName: aws.String("apps")}).
+		Return(&dynamodb.DescribeTableOutput{Table: &dynamodb.TableDescription{LatestStreamArn: aws.String(testStreamArn)}}, nil)
+	mockStreams.
+		EXPECT().
+		DescribeStreamWithContext(gomock.Any(), &dynamodbstreams.DescribeStreamInput{StreamArn: aws.String(testStreamArn)}).
+		Return(&dynamodbstreams.DescribeStreamOutput{StreamDescription: &dynamodbstreams.StreamDescription{
+			Shards: []*dynamodbstreams.Shard{{ShardId: aws.String("shardId-00000001")}},
+		}}, nil).
+		AnyTimes()
+	mockStreams.
+		EXPECT().
+		GetShardIteratorWithContext(gomock.Any(), &dynamodbstreams.GetShardIteratorInput{
+			StreamArn:         aws.String(testStreamArn),
+			ShardId:           aws.String("shardId-00000001"),
+			ShardIteratorType: aws.String(dynamodbstreams.ShardIteratorTypeLatest),
+		}).
+		Return(&dynamodbstreams.GetShardIteratorOutput{ShardIterator: aws.String("iterator-1")}, nil)
+}
+
+func streamRecord(eventName, sequenceNumber, token, country string) *dynamodbstreams.Record {
+	return &dynamodbstreams.Record{
+		EventName: aws.String(eventName),
+		Dynamodb: &dynamodbstreams.StreamRecord{
+			SequenceNumber: aws.String(sequenceNumber),
+			Keys: map[string]*dynamodb.AttributeValue{
+				"apiToken":    {S: aws.String(token)},
+				"countryCode": {S: aws.String(country)},
+			},
+			NewImage: map[string]*dynamodb.AttributeValue{
+				"apiToken":    {S: aws.String(token)},
+				"countryCode": {S: aws.String(country)},
+				"field":       {S: aws.String("new")},
+			},
+		},
+	}
+}
+
+func TestWatch_DecryptsChanges(t *testing.T) {
+	var (
+		ctx, cancel  = context.WithCancel(context.TODO())
+		ctrl         = gomock.NewController(t)
+		mockDynamoDB = mock_dynamodbiface.NewMockDynamoDBAPI(ctrl)
+		mockStreams  = mock_dynamodbstreamsiface.NewMockDynamoDBStreamsAPI(ctrl)
+		self         = New(mockDynamoDB)
+	)
+	defer cancel()
+	self.StreamsSvc = mockStreams
+	self.WatchPollInterval = time.Millisecond
+	self.Encryption = NewEncryption(localKeys, time.Minute)
+	self.Encryption.Attributes = map[string][]string{"apps": {"field"}}
+
+	record := streamRecord("MODIFY", "100000000000000000001", "token1", "US")
+	encrypted, err := self.Encryption.EncryptItem(ctx, ConfigKey{Workspace: "apps", APIToken: "token1", Country: "US"}, record.Dynamodb.NewImage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record.Dynamodb.NewImage = encrypted
+
+	expectStream(mockDynamoDB, mockStreams)
+	mockStreams.
+		EXPECT().
+		GetRecordsWithContext(gomock.Any(), &dynamodbstreams.GetRecordsInput{ShardIterator: aws.String("iterator-1")}).
+		Return(&dynamodbstreams.GetRecordsOutput{Records: []*dynamodbstreams.Record{record}}, nil)
+
+	events, err := self.Watch(ctx, "apps", nil)
+	if err != nil {
+		t.Fatalf("Watch(ctx, %q, nil) == %q, want nil", "apps", err)
+	}
+
+	if event := <-events; event.Err != nil || event.NewConfig["field"] != "new" {
+		t.Errorf("Watch(ctx, %q, nil) event == %+v, want field=new decrypted", "apps", event)
+	}
+	cancel()
+	for range events {
+	}
+}